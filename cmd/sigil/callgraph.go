@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/callgraph"
+	"sigil/internal/compiler"
+)
+
+// callgraphCommand prints the static call graph of a program, as lines of
+// "caller -> callee" or as a Graphviz graph.
+func callgraphCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("callgraph", stderr)
+	expr := fs.String("e", "", "read the given source instead of a file")
+	format := fs.String("format", "text", "how to print the graph: text, dot")
+	diagnostics := diagnosticsFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *format != "text" && *format != "dot" {
+		fmt.Fprintf(stderr, "sigil callgraph: unknown format %q, expected text or dot\n", *format)
+		return exitUsage
+	}
+
+	name, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil callgraph: %s\n", err)
+		return exitUsage
+	}
+
+	emitter, err := newEmitter(*diagnostics, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil callgraph: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	// Calls are matched to functions through the checker's symbols, which
+	// are not to be trusted in a program with type errors.
+	result := compiler.Compile(source)
+	if code := reportErrors(emitter, result); code != exitOK {
+		return code
+	}
+
+	graph := callgraph.Build(result.Program, result.Checked)
+	if *format == "dot" {
+		fmt.Fprint(stdout, graph.Graphviz())
+	} else {
+		fmt.Fprint(stdout, graph)
+	}
+	return exitOK
+}
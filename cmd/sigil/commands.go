@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	_ "sigil/internal/backends/interpreter" // registers the evaluator and interpreter backends
+	"sigil/internal/compiler"
+	"sigil/internal/coverage"
+	"sigil/internal/cst"
+	"sigil/internal/diagnostic"
+	"sigil/internal/graphviz"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"slices"
+	"strings"
+)
+
+const defaultBackend = "evaluator"
+
+// astFormats lists the values "sigil ast --format" accepts.
+var astFormats = []string{"tree", "json", "dot"}
+
+// coverFormats lists the values "sigil run --cover-format" accepts.
+var coverFormats = []string{"text", "html", "lcov"}
+
+// dumps lists the intermediate results --dump can print.
+var dumps = []string{"tokens", "ast", "types"}
+
+// pipeline runs source through the lexer, parser and type checker, printing
+// the intermediate results that were asked for.
+type pipeline struct {
+	dump   map[string]bool
+	quiet  bool
+	stdout io.Writer
+	stderr io.Writer
+
+	diagnostics diagnostic.Emitter
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: sigil %s [flags] [file.sgl | -]\n\nFlags:\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseDumps(value string) (map[string]bool, error) {
+	selected := map[string]bool{}
+	if value == "" {
+		return selected, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, d := range dumps {
+			known = known || d == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown dump %q, expected one of %s", name, strings.Join(dumps, ", "))
+		}
+		selected[name] = true
+	}
+	return selected, nil
+}
+
+func (p *pipeline) header(title string) {
+	if !p.quiet {
+		fmt.Fprintf(p.stdout, "== %s ==\n", title)
+	}
+}
+
+// compile parses and type checks source. It returns the program and the exit
+// code to report if compilation failed.
+func (p *pipeline) compile(source string) (*ast.Program, int) {
+	if p.dump["tokens"] {
+		p.header("tokens")
+		printTokens(p.stdout, lexer.New(source))
+	}
+
+	result := compiler.Compile(source)
+	if p.dump["ast"] && len(result.ParseErrors) == 0 {
+		p.header("ast")
+		fmt.Fprint(p.stdout, result.Program.TreeString("", false))
+	}
+	if code := reportErrors(p.diagnostics, result); code != exitOK {
+		return nil, code
+	}
+
+	if p.dump["types"] {
+		p.header("types")
+		for _, stmt := range result.Program.Statements {
+			fmt.Fprintf(p.stdout, "%s : %s\n", stmt.String(), result.Checked.Types[stmt])
+		}
+	}
+
+	return result.Program, exitOK
+}
+
+func printTokens(w io.Writer, l *lexer.Lexer) {
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(w, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		if tok.Type == lexer.EOF {
+			break
+		}
+	}
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("run", stderr)
+	backendName := fs.String("backend", defaultBackend, "execution backend: "+strings.Join(backends.Names(), ", "))
+	dump := fs.String("dump", "", "comma separated stages to print before running: "+strings.Join(dumps, ", "))
+	quiet := fs.Bool("quiet", false, "only print program output and errors")
+	expr := fs.String("e", "", "run the given source instead of a file")
+	coverprofile := fs.String("coverprofile", "", "write the statements and branches that ran to this file")
+	coverFormat := fs.String("cover-format", "text", "format of the coverage profile: "+strings.Join(coverFormats, ", "))
+	format := diagnosticsFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	factory, ok := backends.Lookup(*backendName)
+	if !ok {
+		fmt.Fprintf(stderr, "sigil run: unknown backend %q, expected one of %s\n", *backendName, strings.Join(backends.Names(), ", "))
+		return exitUsage
+	}
+
+	if !slices.Contains(coverFormats, *coverFormat) {
+		fmt.Fprintf(stderr, "sigil run: unknown coverage format %q, expected one of %s\n", *coverFormat, strings.Join(coverFormats, ", "))
+		return exitUsage
+	}
+
+	selected, err := parseDumps(*dump)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil run: %s\n", err)
+		return exitUsage
+	}
+
+	name, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil run: %s\n", err)
+		return exitUsage
+	}
+
+	emitter, err := newEmitter(*format, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil run: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	p := &pipeline{dump: selected, quiet: *quiet, stdout: stdout, stderr: stderr, diagnostics: emitter}
+	program, code := p.compile(source)
+	if code != exitOK {
+		return code
+	}
+
+	if len(selected) > 0 {
+		p.header("output")
+	}
+
+	backend := factory(&backends.IOConfig{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+	var profile *coverage.Profile
+	if *coverprofile != "" {
+		covering, ok := backend.(backends.CoverageBackend)
+		if !ok {
+			fmt.Fprintf(stderr, "sigil run: the %s backend cannot record coverage\n", *backendName)
+			return exitUsage
+		}
+		profile = coverage.New(name, source, program)
+		covering.Cover(profile)
+	}
+
+	if err := backend.Execute(program, false); err != nil {
+		report(p.diagnostics, err)
+		code = exitRuntimeError
+	}
+
+	// The profile is written even if the program failed, to show how far
+	// it got.
+	if profile != nil {
+		if err := writeCoverage(profile, *coverprofile, *coverFormat); err != nil {
+			fmt.Fprintf(stderr, "sigil run: %s\n", err)
+			return max(code, exitUsage)
+		}
+		if !*quiet {
+			fmt.Fprintf(stderr, "coverage: %s\n", profile.Summary())
+		}
+	}
+	return code
+}
+
+// writeCoverage writes profile to the file path in the given format.
+func writeCoverage(profile *coverage.Profile, path, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "html":
+		err = profile.WriteHTML(f)
+	case "lcov":
+		err = profile.WriteLCOV(f)
+	default:
+		err = profile.WriteText(f)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func checkCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("check", stderr)
+	dump := fs.String("dump", "", "comma separated stages to print: "+strings.Join(dumps, ", "))
+	quiet := fs.Bool("quiet", false, "do not report success")
+	expr := fs.String("e", "", "check the given source instead of a file")
+	format := diagnosticsFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	selected, err := parseDumps(*dump)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil check: %s\n", err)
+		return exitUsage
+	}
+
+	name, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil check: %s\n", err)
+		return exitUsage
+	}
+
+	emitter, err := newEmitter(*format, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil check: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	p := &pipeline{dump: selected, quiet: *quiet, stdout: stdout, stderr: stderr, diagnostics: emitter}
+	if _, code := p.compile(source); code != exitOK {
+		return code
+	}
+
+	if !*quiet {
+		fmt.Fprintln(stdout, "ok")
+	}
+	return exitOK
+}
+
+func tokensCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("tokens", stderr)
+	expr := fs.String("e", "", "tokenize the given source instead of a file")
+	trivia := fs.Bool("trivia", false, "include whitespace and comments")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	_, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil tokens: %s\n", err)
+		return exitUsage
+	}
+
+	l := lexer.New(source)
+	if *trivia {
+		l = lexer.NewWithTrivia(source)
+	}
+	printTokens(stdout, l)
+	return exitOK
+}
+
+func astCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("ast", stderr)
+	expr := fs.String("e", "", "parse the given source instead of a file")
+	trace := fs.Bool("trace-parser", false, "write the parser's call trace to stderr")
+	concrete := fs.Bool("cst", false, "print the concrete syntax tree, with every token, whitespace and comment")
+	format := fs.String("format", "tree", "how to print the tree: "+strings.Join(astFormats, ", "))
+	diagnostics := diagnosticsFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if !slices.Contains(astFormats, *format) {
+		fmt.Fprintf(stderr, "sigil ast: unknown format %q, expected one of %s\n", *format, strings.Join(astFormats, ", "))
+		return exitUsage
+	}
+	if *concrete && *format != "tree" {
+		fmt.Fprintln(stderr, "sigil ast: --cst only prints a tree")
+		return exitUsage
+	}
+
+	name, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil ast: %s\n", err)
+		return exitUsage
+	}
+
+	emitter, err := newEmitter(*diagnostics, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil ast: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	var opts []compiler.Option
+	if *trace {
+		opts = append(opts, compiler.WithParserOptions(parser.WithTraceWriter(stderr)))
+	}
+	if *concrete {
+		opts = append(opts, compiler.WithTrivia())
+	}
+
+	// The types label the nodes of the dot graph where the checker could
+	// work them out; type errors do not keep the tree from being drawn.
+	compile := compiler.Parse
+	if *format == "dot" {
+		compile = compiler.Compile
+	}
+	result := compile(source, opts...)
+	if len(result.ParseErrors) > 0 {
+		return reportErrors(emitter, result)
+	}
+	program := result.Program
+
+	switch {
+	case *concrete:
+		fmt.Fprint(stdout, cst.Build(program, source).TreeString())
+	case *format == "json":
+		var out bytes.Buffer
+		data, err := ast.EncodeJSON(program)
+		if err == nil {
+			err = json.Indent(&out, data, "", "  ")
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "sigil ast: %s\n", err)
+			return exitRuntimeError
+		}
+		fmt.Fprintln(stdout, out.String())
+	case *format == "dot":
+		fmt.Fprint(stdout, graphviz.AST(program, result.Checked))
+	default:
+		fmt.Fprint(stdout, program.TreeString("", false))
+	}
+	return exitOK
+}
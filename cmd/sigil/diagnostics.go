@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sigil/internal/compiler"
+	"sigil/internal/diagnostic"
+	"strings"
+)
+
+// diagnosticFormats lists the values --diagnostics-format accepts.
+var diagnosticFormats = []string{"text", "json", "sarif"}
+
+func diagnosticsFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("diagnostics-format", "text",
+		"how to write errors to stderr: "+strings.Join(diagnosticFormats, ", "))
+}
+
+// newEmitter returns where the diagnostics for source are sent. Text is
+// colored when w is a terminal and NO_COLOR is not set; JSON and SARIF are
+// written as one document once the command is done.
+func newEmitter(format string, w io.Writer, name, source string) (diagnostic.Emitter, error) {
+	file := diagnostic.NewFile(name, source)
+	switch format {
+	case "text":
+		return diagnostic.NewPrinter(w, file, isTerminal(w) && os.Getenv("NO_COLOR") == ""), nil
+	case "json":
+		return diagnostic.NewJSONEmitter(w, file), nil
+	case "sarif":
+		return diagnostic.NewSARIFEmitter(w, file, version), nil
+	default:
+		return nil, fmt.Errorf("unknown diagnostics format %q, expected one of %s", format, strings.Join(diagnosticFormats, ", "))
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// report emits err as a diagnostic. Errors from the compiler stages carry
+// their own code and location; anything else is reported without them.
+func report(emitter diagnostic.Emitter, err error) {
+	var d diagnostic.Diagnoser
+	if errors.As(err, &d) {
+		emitter.Emit(d.Diagnostic())
+		return
+	}
+	emitter.Emit(&diagnostic.Diagnostic{Severity: diagnostic.Error, Message: err.Error()})
+}
+
+// reportErrors emits the errors of a compilation and returns the exit code
+// for them, or exitOK if there were none.
+func reportErrors(emitter diagnostic.Emitter, result *compiler.Result) int {
+	for _, err := range result.Errors() {
+		report(emitter, err)
+	}
+	switch {
+	case len(result.ParseErrors) > 0:
+		return exitParseError
+	case result.Checked != nil && len(result.Checked.Errors) > 0:
+		return exitTypeError
+	}
+	return exitOK
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/diagnostic"
+	"strings"
+)
+
+// explainCommand prints the long form explanation of an error code, or the
+// list of every code when none is given.
+func explainCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("explain", stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: sigil explain [CODE]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Without a code, lists every error code Sigil reports.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	switch fs.NArg() {
+	case 0:
+		for _, code := range diagnostic.Codes() {
+			e, _ := diagnostic.Explain(code)
+			fmt.Fprintf(stdout, "%s  %s\n", e.Code, e.Title)
+		}
+		return exitOK
+	case 1:
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	e, ok := diagnostic.Explain(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(stderr, "sigil explain: unknown error code %q, run \"sigil explain\" for the list\n", fs.Arg(0))
+		return exitUsage
+	}
+
+	fmt.Fprintf(stdout, "%s: %s\n\n%s\n", e.Code, e.Title, e.Text)
+	if e.Failing != "" {
+		fmt.Fprintf(stdout, "\nErroneous example:\n\n%s\n", indent(e.Failing))
+		fmt.Fprintf(stdout, "\nFixed example:\n\n%s\n", indent(e.Fixed))
+	}
+	return exitOK
+}
+
+func indent(source string) string {
+	return "    " + strings.ReplaceAll(source, "\n", "\n    ")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sigil/internal/compiler"
+	"sigil/internal/format"
+)
+
+// fmtCommand prints programs in the canonical style. With -w it rewrites
+// the files instead, and with --check it lists the files that are not
+// formatted without changing them. Directories are searched for .sgl files.
+func fmtCommand(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("fmt", stderr)
+	write := flags.Bool("w", false, "write the result to the files instead of standard output")
+	check := flags.Bool("check", false, "list the files that are not formatted and exit with status 6 if there are any")
+	expr := flags.String("e", "", "format the given source instead of a file")
+	diagnostics := diagnosticsFormatFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *write && *check {
+		fmt.Fprintln(stderr, "sigil fmt: cannot combine -w with --check")
+		return exitUsage
+	}
+
+	if *expr != "" || flags.Arg(0) == "-" {
+		if *write {
+			fmt.Fprintln(stderr, "sigil fmt: -w needs files to write to")
+			return exitUsage
+		}
+		name, source, err := readSource(flags, *expr)
+		if err != nil {
+			fmt.Fprintf(stderr, "sigil fmt: %s\n", err)
+			return exitUsage
+		}
+		return formatSource(name, source, *check, *diagnostics, stdout, stderr)
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "sigil fmt: %s\n", errNoInput)
+		return exitUsage
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil fmt: %s\n", err)
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "sigil fmt: %s\n", err)
+			code = max(code, exitUsage)
+			continue
+		}
+
+		if !*write {
+			code = max(code, formatSource(file, string(data), *check, *diagnostics, stdout, stderr))
+			continue
+		}
+
+		formatted, status := formatFile(file, string(data), *diagnostics, stderr)
+		if status != exitOK {
+			code = max(code, status)
+			continue
+		}
+		if formatted == string(data) {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, []byte(formatted), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "sigil fmt: %s\n", err)
+			code = max(code, exitRuntimeError)
+		}
+	}
+	return code
+}
+
+// formatSource prints source formatted, or with check only its name if
+// formatting would change it.
+func formatSource(name, source string, check bool, diagnostics string, stdout, stderr io.Writer) int {
+	formatted, code := formatFile(name, source, diagnostics, stderr)
+	switch {
+	case code != exitOK:
+		return code
+	case !check:
+		fmt.Fprint(stdout, formatted)
+	case formatted != source:
+		fmt.Fprintln(stdout, name)
+		return exitUnformatted
+	}
+	return exitOK
+}
+
+// formatFile formats one program, reporting its parse errors.
+func formatFile(name, source, diagnostics string, stderr io.Writer) (string, int) {
+	emitter, err := newEmitter(diagnostics, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil fmt: %s\n", err)
+		return "", exitUsage
+	}
+	defer emitter.Close()
+
+	result := compiler.Parse(source)
+	if code := reportErrors(emitter, result); code != exitOK {
+		return "", code
+	}
+	return format.Program(result.Program, source), exitOK
+}
+
+// sourceFiles expands the directories among paths into the .sgl files they
+// hold, in lexical order.
+func sourceFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Ext(file) == ".sgl" {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no .sgl files found")
+	}
+	return files, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/compiler"
+	"sigil/internal/typechecker"
+	"strings"
+)
+
+// lintCommand type checks a program and reports the warnings of the enabled
+// lint rules along with any errors.
+func lintCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("lint", stderr)
+	enable := fs.String("enable", "", "comma separated rules to run instead of all of them")
+	disable := fs.String("disable", "", "comma separated rules to skip")
+	list := fs.Bool("list", false, "list the rules and exit")
+	expr := fs.String("e", "", "lint the given source instead of a file")
+	format := diagnosticsFormatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *list {
+		for _, rule := range typechecker.Rules {
+			fmt.Fprintf(stdout, "%s  %-20s %s\n", rule.Code, rule.Name, rule.Summary)
+		}
+		return exitOK
+	}
+
+	enabled, err := selectRules(*enable, *disable)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil lint: %s\n", err)
+		return exitUsage
+	}
+
+	name, source, err := readSource(fs, *expr)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil lint: %s\n", err)
+		return exitUsage
+	}
+
+	emitter, err := newEmitter(*format, stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil lint: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	result := compiler.Compile(source)
+	code := reportErrors(emitter, result)
+	if code == exitParseError {
+		return code
+	}
+
+	warned := false
+	for _, w := range result.Checked.Warnings {
+		if enabled[w.Rule] {
+			emitter.Emit(w.Diagnostic())
+			warned = true
+		}
+	}
+
+	if code == exitOK && warned {
+		return exitWarnings
+	}
+	return code
+}
+
+// selectRules returns the names of the rules to report. Rules may be given
+// by name or by code.
+func selectRules(enable, disable string) (map[string]bool, error) {
+	enabled := map[string]bool{}
+	if enable == "" {
+		for _, rule := range typechecker.Rules {
+			enabled[rule.Name] = true
+		}
+	}
+
+	for _, setting := range []struct {
+		value string
+		on    bool
+	}{{enable, true}, {disable, false}} {
+		if setting.value == "" {
+			continue
+		}
+		for _, name := range strings.Split(setting.value, ",") {
+			rule, ok := typechecker.LookupRule(strings.TrimSpace(name))
+			if !ok {
+				return nil, fmt.Errorf("unknown rule %q, run \"sigil lint --list\" for the rules", name)
+			}
+			enabled[rule.Name] = setting.on
+		}
+	}
+	return enabled, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/lsp"
+)
+
+// lspCommand serves the Language Server Protocol over standard input and
+// output until the editor exits.
+func lspCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("lsp", stderr)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if err := lsp.NewServer(stdin, stdout, stderr, version).Serve(); err != nil {
+		fmt.Fprintf(stderr, "sigil lsp: %s\n", err)
+		return exitRuntimeError
+	}
+	return exitOK
+}
@@ -2,94 +2,96 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"sigil/internal/backends/interpreter"
-	"sigil/internal/lexer"
-	"sigil/internal/parser"
-	"sigil/internal/typechecker"
+	"strings"
 )
 
-const DEBUG_MODE = true
-
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Sigil Language Compiler")
-		fmt.Println("Usage: sigil <file.sgl>")
-		return
-	}
-
-	filename := os.Args[1]
-
-	// Read the source file
-	source, err := os.ReadFile(filename)
-	if err != nil {
-		fmt.Printf("Error reading file %s: %v\n", filename, err)
-		return
-	}
-
-	if DEBUG_MODE {
-
-		fmt.Printf("Compiling: %s\n", filename)
-		fmt.Printf("Source code:\n%s\n", string(source))
-		fmt.Println("\nTokens:")
-
-		// Tokenize the source
-		l := lexer.New(string(source))
-		for {
-			tok := l.NextToken()
-			fmt.Printf("Type: %v, Literal: '%s', Line: %d, Column: %d\n",
-				tok.Type, tok.Literal, tok.Line, tok.Column)
-			if tok.Type == lexer.EOF {
-				break
-			}
-		}
+const version = "0.1.0"
+
+// Exit codes reported by every command.
+const (
+	exitOK           = 0
+	exitRuntimeError = 1
+	exitUsage        = 2
+	exitParseError   = 3
+	exitTypeError    = 4
+	exitWarnings     = 5 // lint found problems
+	exitUnformatted  = 6 // fmt --check found files to format
+)
 
-		fmt.Println("\nParsing:")
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
 
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "type check and execute a program", runCommand},
+		{"check", "parse and type check a program without running it", checkCommand},
+		{"test", "run the tests declared in _test.sgl files", testCommand},
+		{"lint", "report suspicious code such as unused variables", lintCommand},
+		{"fmt", "format programs in the canonical style", fmtCommand},
+		{"tokens", "print the token stream of a program", tokensCommand},
+		{"ast", "print the syntax tree of a program", astCommand},
+		{"callgraph", "print which functions of a program call which", callgraphCommand},
+		{"repl", "start an interactive prompt", replCommand},
+		{"lsp", "start a language server on standard input and output", lspCommand},
+		{"explain", "describe an error code such as E0201", explainCommand},
+		{"version", "print the Sigil version", versionCommand},
+		{"help", "show this message", helpCommand},
 	}
+}
 
-	// Reset lexer for parsing
-	l := lexer.New(string(source))
-	p := parser.New(l)
-	program := p.ParseProgram()
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	// Check for parser errors
-	if errors := p.Errors(); len(errors) > 0 {
-		fmt.Println("Parser errors:")
-		for _, err := range errors {
-			fmt.Printf(" %s\n", err)
-		}
-		return // Don't continue if there are parse errors
+func dispatch(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
 	}
 
-	if DEBUG_MODE {
-		fmt.Printf("AST Tree:\n%s", program.TreeString("", false))
-		fmt.Println("\nType Checking:")
+	// `sigil file.sgl` is shorthand for `sigil run file.sgl`.
+	if strings.HasSuffix(args[0], ".sgl") {
+		return runCommand(args, stdout, stderr)
 	}
 
-	// Type check the program
-	tc := typechecker.New()
-	tc.CheckProgram(program)
-
-	if tc.HasErrors() {
-		fmt.Println("Type errors:")
-		for _, err := range tc.Errors() {
-			fmt.Printf(" %s\n", err.Error())
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
 		}
-		return // Don't continue if there are type errors
 	}
 
-	if DEBUG_MODE {
-		fmt.Println("✓ Type checking passed")
-		fmt.Println("\nExecution:")
+	fmt.Fprintf(stderr, "sigil: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Sigil Language Compiler")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: sigil <command> [flags] [file.sgl | -]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Use \"-\" to read the program from standard input.")
+	fmt.Fprintln(w, "Run \"sigil <command> -h\" for the flags of a command.")
+}
 
-	// Execute the program with the interpreter backend
-	backend := interpreter.NewEvaluator()
-	err = backend.Execute(program, DEBUG_MODE)
+func helpCommand(args []string, stdout, stderr io.Writer) int {
+	usage(stdout)
+	return exitOK
+}
 
-	if err != nil {
-		fmt.Printf("Runtime error: %s\n", err)
-		return
-	}
+func versionCommand(args []string, stdout, stderr io.Writer) int {
+	fmt.Fprintf(stdout, "sigil %s\n", version)
+	return exitOK
 }
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"strings"
+	"testing"
+)
+
+func runSigil(t *testing.T, input string, args ...string) (string, string, int) {
+	t.Helper()
+
+	stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	code := dispatch(args, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestCommandExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hello.sgl")
+	if err := os.WriteFile(file, []byte(`println("Hello, world!");`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args       []string
+		input      string
+		wantCode   int
+		wantStdout string
+	}{
+		{[]string{"run", file}, "", exitOK, "Hello, world!\n"},
+		{[]string{file}, "", exitOK, "Hello, world!\n"},
+		{[]string{"run", "--backend=interpreter", file}, "", exitOK, "Hello, world!\n"},
+		{[]string{"run", "-"}, `println("from stdin")`, exitOK, "from stdin\n"},
+		{[]string{"run", "-e", `print("expr")`}, "", exitOK, "expr"},
+		{[]string{"run", "-e", "1 +"}, "", exitParseError, ""},
+		{[]string{"run", "-e", "1 + true"}, "", exitTypeError, ""},
+		{[]string{"run", "--backend=interpreter", "-e", "1 / 0"}, "", exitRuntimeError, ""},
+		{[]string{"run", "--backend=llvm", file}, "", exitUsage, ""},
+		{[]string{"run", "--dump=bytecode", file}, "", exitUsage, ""},
+		{[]string{"run"}, "", exitUsage, ""},
+		{[]string{"run", filepath.Join(dir, "missing.sgl")}, "", exitUsage, ""},
+		{[]string{"check", file}, "", exitOK, "ok\n"},
+		{[]string{"check", "--quiet", file}, "", exitOK, ""},
+		{[]string{"check", "-e", "let x: String = 1;"}, "", exitTypeError, ""},
+		{[]string{"version"}, "", exitOK, "sigil " + version + "\n"},
+		{[]string{"frobnicate"}, "", exitUsage, ""},
+		{[]string{}, "", exitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		stdout, stderr, code := runSigil(t, tt.input, tt.args...)
+		if code != tt.wantCode {
+			t.Errorf("sigil %v: got exit code %d, want %d (stderr %q)", tt.args, code, tt.wantCode, stderr)
+		}
+		if stdout != tt.wantStdout {
+			t.Errorf("sigil %v: got stdout %q, want %q", tt.args, stdout, tt.wantStdout)
+		}
+	}
+}
+
+func TestDumps(t *testing.T) {
+	stdout, _, code := runSigil(t, "", "run", "--quiet", "--dump=tokens,ast,types", "-e", "1 + 2;")
+	if code != exitOK {
+		t.Fatalf("got exit code %d", code)
+	}
+
+	want := `1:1	NUMBER	"1"
+1:3	PLUS	"+"
+1:5	NUMBER	"2"
+1:6	SEMICOLON	";"
+1:7	EOF	""
+Program
+└── ExpressionStatement
+    └── InfixExpression: +
+        ├── NumberLiteral: 1
+        └── NumberLiteral: 2
+(1 + 2) : Void
+`
+	if stdout != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestTokensAndAstCommands(t *testing.T) {
+	stdout, _, code := runSigil(t, "", "tokens", "-e", "x")
+	if code != exitOK || stdout != "1:1\tIDENT\t\"x\"\n1:2\tEOF\t\"\"\n" {
+		t.Errorf("tokens: got %q (exit %d)", stdout, code)
+	}
+
+	stdout, _, code = runSigil(t, "", "tokens", "--trivia", "-e", "x // y")
+	if code != exitOK || stdout != "1:1\tIDENT\t\"x\"\n1:2\tWHITESPACE\t\" \"\n1:3\tCOMMENT\t\"// y\"\n1:7\tEOF\t\"\"\n" {
+		t.Errorf("tokens --trivia: got %q (exit %d)", stdout, code)
+	}
+
+	stdout, _, code = runSigil(t, "", "ast", "-e", "x")
+	if code != exitOK || stdout != "Program\n└── ExpressionStatement\n    └── Identifier: x\n" {
+		t.Errorf("ast: got %q (exit %d)", stdout, code)
+	}
+
+	stdout, _, code = runSigil(t, "", "ast", "--cst", "-e", "x;")
+	if code != exitOK || stdout != "Program\n  ExpressionStatement\n    Identifier\n      IDENT \"x\"\n    SEMICOLON \";\"\n" {
+		t.Errorf("ast --cst: got %q (exit %d)", stdout, code)
+	}
+
+	stdout, _, code = runSigil(t, "", "ast", "--format=json", "-e", "x")
+	if code != exitOK || !strings.HasPrefix(stdout, "{\n  \"kind\": \"Program\",") {
+		t.Errorf("ast --format=json: got %q (exit %d)", stdout, code)
+	}
+	if node, err := ast.DecodeJSON([]byte(stdout)); err != nil || node.String() != "x" {
+		t.Errorf("ast --format=json: decoding the output gave %v, %v", node, err)
+	}
+	if _, _, code = runSigil(t, "", "ast", "--format=xml", "-e", "x"); code != exitUsage {
+		t.Errorf("ast --format=xml: got exit %d", code)
+	}
+
+	stdout, _, code = runSigil(t, "", "ast", "--format=dot", "-e", "x")
+	if code != exitOK || !strings.HasPrefix(stdout, "digraph \"ast\" {") || !strings.Contains(stdout, `label="Identifier\nx"`) {
+		t.Errorf("ast --format=dot: got %q (exit %d)", stdout, code)
+	}
+
+	_, _, code = runSigil(t, "", "ast", "-e", "(")
+	if code != exitParseError {
+		t.Errorf("ast: got exit %d for a syntax error", code)
+	}
+
+	_, stderr, _ := runSigil(t, "", "ast", "-e", "x")
+	if stderr != "" {
+		t.Errorf("ast: expected no trace without --trace-parser, got %q", stderr)
+	}
+
+	_, stderr, code = runSigil(t, "", "ast", "--trace-parser", "-e", "x")
+	if code != exitOK || !strings.Contains(stderr, "enter parseIdentifier at 1:1 IDENT \"x\"") {
+		t.Errorf("ast --trace-parser: got %q (exit %d)", stderr, code)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantCode int
+		want     string
+	}{
+		{[]string{"check", "-e", "let x = 1;\nlet y: String = x;"}, exitTypeError, `error[E0207]: type mismatch: declared String but got Number
+ --> <expr>:2:17
+  |
+2 | let y: String = x;
+  |                 ^
+  |        ------ expected because of this annotation
+  |
+
+`},
+		{[]string{"check", "-e", "let total = 1;\ntotl"}, exitTypeError, `error[E0201]: undefined variable: totl
+ --> <expr>:2:1
+  |
+2 | totl
+  | ^^^^
+  |
+  = note: did you mean ` + "`total`" + `?
+
+`},
+		{[]string{"check", "-e", `"abc`}, exitParseError, `error[E0002]: unterminated string literal
+ --> <expr>:1:1
+  |
+1 | "abc
+  | ^^^^
+  |
+
+`},
+		{[]string{"ast", "-e", "1 @ 2"}, exitParseError, `error[E0001]: unexpected character "@"
+ --> <expr>:1:3
+  |
+1 | 1 @ 2
+  |   ^
+  |
+
+`},
+		{[]string{"run", "--backend=interpreter", "-e", "let a = 1;\na / 0"}, exitRuntimeError, `error[E0301]: division by zero
+ --> <expr>:2:1
+  |
+2 | a / 0
+  | ^^^^^
+  |
+
+`},
+	}
+
+	for _, tt := range tests {
+		_, stderr, code := runSigil(t, "", tt.args...)
+		if code != tt.wantCode {
+			t.Errorf("sigil %v: got exit code %d, want %d", tt.args, code, tt.wantCode)
+		}
+		if stderr != tt.want {
+			t.Errorf("sigil %v: wrong diagnostics.\nexpected:\n%s\ngot:\n%s", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestDiagnosticsFormat(t *testing.T) {
+	_, stderr, code := runSigil(t, "", "check", "--diagnostics-format=json", "-e", "1 + true")
+	if code != exitTypeError {
+		t.Errorf("got exit code %d, want %d", code, exitTypeError)
+	}
+	if !strings.Contains(stderr, `"code": "E0203"`) || !strings.Contains(stderr, `"file": "<expr>"`) {
+		t.Errorf("expected a JSON diagnostic, got %s", stderr)
+	}
+
+	_, stderr, code = runSigil(t, "", "run", "--diagnostics-format=sarif", "-e", `println("fine")`)
+	if code != exitOK || !strings.Contains(stderr, `"version": "2.1.0"`) || !strings.Contains(stderr, `"results": []`) {
+		t.Errorf("expected an empty SARIF log, got %s (exit %d)", stderr, code)
+	}
+
+	_, _, code = runSigil(t, "", "check", "--diagnostics-format=xml", "-e", "1")
+	if code != exitUsage {
+		t.Errorf("got exit code %d for an unknown format", code)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	stdout, _, code := runSigil(t, "", "explain", "e0103")
+	if code != exitOK || !strings.HasPrefix(stdout, "E0103: unclosed block\n") ||
+		!strings.Contains(stdout, "Erroneous example:\n\n    let one = fun(): Number {\n        1\n") {
+		t.Errorf("unexpected explanation (exit %d):\n%s", code, stdout)
+	}
+
+	stdout, _, code = runSigil(t, "", "explain")
+	if code != exitOK || strings.Count(stdout, "\n") != len(diagnostic.Codes()) {
+		t.Errorf("expected one line per code (exit %d):\n%s", code, stdout)
+	}
+
+	if _, _, code := runSigil(t, "", "explain", "E9999"); code != exitUsage {
+		t.Errorf("got exit code %d for an unknown code", code)
+	}
+}
+
+// TestExplainExamples makes sure each failing example reports its code and
+// each fixed example compiles, so the explanations cannot drift from what
+// the compiler does.
+func TestExplainExamples(t *testing.T) {
+	for _, code := range diagnostic.Codes() {
+		e, _ := diagnostic.Explain(code)
+		switch {
+		case code == diagnostic.CodeInternal:
+			continue // no program should be able to report it
+		case code == diagnostic.CodeHostFunction:
+			continue // needs functions registered by a host
+		case strings.HasPrefix(code, "E03"):
+			checkRuntimeExample(t, e)
+		case strings.HasPrefix(code, "W"):
+			if got := reportedCodes(t, "lint", e.Failing); len(got) != 1 || got[0] != code {
+				t.Errorf("%s: failing example reported %v", code, got)
+			}
+			if got := reportedCodes(t, "lint", e.Fixed); len(got) != 0 {
+				t.Errorf("%s: fixed example reported %v", code, got)
+			}
+		default:
+			if got := reportedCodes(t, "check", e.Failing); len(got) == 0 || got[0] != code {
+				t.Errorf("%s: failing example reported %v", code, got)
+			}
+		}
+
+		for _, backend := range backends.Names() {
+			if _, stderr, exit := runSigil(t, "", "run", "--backend="+backend, "-e", e.Fixed); exit != exitOK {
+				t.Errorf("%s: fixed example failed on %s:\n%s", code, backend, stderr)
+			}
+		}
+	}
+}
+
+// reportedCodes returns the codes command reports for source.
+func reportedCodes(t *testing.T, command, source string) []string {
+	t.Helper()
+
+	_, stderr, _ := runSigil(t, "", command, "--diagnostics-format=json", "-e", source)
+	var out struct {
+		Diagnostics []struct{ Code string }
+	}
+	if err := json.Unmarshal([]byte(stderr), &out); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, stderr)
+	}
+
+	codes := []string{}
+	for _, d := range out.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+// checkRuntimeExample runs the failing example of a runtime code on every
+// backend without type checking it first, since the checker rejects most of
+// them. Backends that fail must report the code, and at least one must.
+func checkRuntimeExample(t *testing.T, e *diagnostic.Explanation) {
+	t.Helper()
+
+	p := parser.New(lexer.New(e.Failing))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s: failing example does not parse: %v", e.Code, p.Errors())
+	}
+
+	failed := false
+	for _, name := range backends.Names() {
+		factory, _ := backends.Lookup(name)
+		err := factory(&backends.IOConfig{Stdin: strings.NewReader(""), Stdout: io.Discard, Stderr: io.Discard}).Execute(program, false)
+		if err == nil {
+			continue
+		}
+		failed = true
+
+		var runtimeErr *backends.RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Code != e.Code {
+			t.Errorf("%s: %s reported %v", e.Code, name, err)
+		}
+	}
+	if !failed {
+		t.Errorf("%s: failing example ran on every backend", e.Code)
+	}
+}
+
+func TestLint(t *testing.T) {
+	source := "let f = fun(a: Number, b: Number): Number {\n    a + 1;\n    a\n};"
+
+	tests := []struct {
+		args     []string
+		wantCode int
+		want     []string
+	}{
+		{[]string{"lint", "-e", source}, exitWarnings, []string{"W0001", "W0004"}},
+		{[]string{"lint", "--disable=unused-variable", "-e", source}, exitWarnings, []string{"W0004"}},
+		{[]string{"lint", "--disable=W0001,discarded-value", "-e", source}, exitOK, []string{}},
+		{[]string{"lint", "--enable=unused-variable", "-e", source}, exitWarnings, []string{"W0001"}},
+		{[]string{"lint", "--enable=shadowing", "-e", source}, exitOK, []string{}},
+		{[]string{"lint", "-e", "let x: Strin = 1;"}, exitTypeError, []string{"E0202", "E0207"}},
+		{[]string{"lint", "-e", "let = 1;"}, exitParseError, []string{"E0101"}},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{tt.args[0], "--diagnostics-format=json"}, tt.args[1:]...)
+		_, stderr, code := runSigil(t, "", args...)
+		if code != tt.wantCode {
+			t.Errorf("sigil %v: got exit code %d, want %d", tt.args, code, tt.wantCode)
+		}
+
+		var out struct {
+			Diagnostics []struct{ Code string }
+		}
+		if err := json.Unmarshal([]byte(stderr), &out); err != nil {
+			t.Fatalf("sigil %v: invalid JSON: %s\n%s", tt.args, err, stderr)
+		}
+		got := []string{}
+		for _, d := range out.Diagnostics {
+			got = append(got, d.Code)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("sigil %v: got codes %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	stdout, _, code := runSigil(t, "", "lint", "--list")
+	if code != exitOK || !strings.Contains(stdout, "W0002  shadowing") {
+		t.Errorf("lint --list: got %q (exit %d)", stdout, code)
+	}
+	if _, _, code := runSigil(t, "", "lint", "--disable=nonsense", "-e", "1"); code != exitUsage {
+		t.Errorf("got exit code %d for an unknown rule", code)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.sgl")
+	broken := filepath.Join(dir, "broken.txt")
+	files := map[string]string{
+		messy:                          "let x=1+2 // three\nprintln(string(x))",
+		filepath.Join(dir, "tidy.sgl"): "let y = 2;\n",
+		broken:                         "let = 1;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "let x = 1 + 2; // three\nprintln(string(x))\n"
+
+	stdout, _, code := runSigil(t, "", "fmt", messy)
+	if code != exitOK || stdout != want {
+		t.Errorf("fmt: got %q (exit %d), want %q", stdout, code, want)
+	}
+	stdout, _, code = runSigil(t, "let  a=1", "fmt", "-")
+	if code != exitOK || stdout != "let a = 1;\n" {
+		t.Errorf("fmt -: got %q (exit %d)", stdout, code)
+	}
+
+	// Only .sgl files of a directory are formatted.
+	stdout, _, code = runSigil(t, "", "fmt", "--check", dir)
+	if code != exitUnformatted || stdout != messy+"\n" {
+		t.Errorf("fmt --check: got %q (exit %d)", stdout, code)
+	}
+
+	if _, _, code := runSigil(t, "", "fmt", "-w", dir); code != exitOK {
+		t.Fatalf("fmt -w: got exit code %d", code)
+	}
+	data, err := os.ReadFile(messy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("fmt -w wrote %q, want %q", data, want)
+	}
+	if _, _, code := runSigil(t, "", "fmt", "--check", dir); code != exitOK {
+		t.Errorf("fmt --check after -w: got exit code %d", code)
+	}
+
+	if _, stderr, code := runSigil(t, "", "fmt", broken); code != exitParseError || !strings.Contains(stderr, "E0101") {
+		t.Errorf("fmt of a broken file: got %q (exit %d)", stderr, code)
+	}
+	if _, _, code := runSigil(t, "", "fmt", "-w", "--check", messy); code != exitUsage {
+		t.Errorf("fmt -w --check: got exit code %d", code)
+	}
+}
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	math := filepath.Join(dir, "math_test.sgl")
+	files := map[string]string{
+		math: `let double = fun(n: Number): Number { n * 2 };
+
+test "doubles" {
+    assert_eq(4, double(2));
+}
+
+test "doubles zero" {
+    assert(double(0) == 0);
+}
+
+test "is wrong" {
+    println("running");
+    assert_eq(5, double(2));
+}
+`,
+		filepath.Join(dir, "lib.sgl"):              "undefined()",
+		filepath.Join(dir, "nested", "a_test.sgl"): `test "passes" { assert(true); }`,
+	}
+	os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// lib.sgl is not a test file, so its type error does not count.
+	for _, backend := range backends.Names() {
+		stdout, stderr, code := runSigil(t, "", "test", "--backend="+backend, dir)
+		want := "running\n--- FAIL: is wrong (" + math + ":11)\nFAIL: 3 passed, 1 failed\n"
+		if code != exitRuntimeError || stdout != want {
+			t.Errorf("%s: got %q (exit %d), want %q", backend, stdout, code, want)
+		}
+		if !strings.Contains(stderr, "error[E0309]: assertion failed: expected 5, got 4") || !strings.Contains(stderr, math+":13:5") {
+			t.Errorf("%s: unexpected diagnostics:\n%s", backend, stderr)
+		}
+	}
+
+	stdout, _, code := runSigil(t, "", "test", "-run", "^doubles", "-v", math)
+	want := "=== RUN   doubles (" + math + ":3)\n--- PASS: doubles\n" +
+		"=== RUN   doubles zero (" + math + ":7)\n--- PASS: doubles zero\n" +
+		"PASS: 2 passed, 0 failed\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("test -run -v: got %q (exit %d), want %q", stdout, code, want)
+	}
+
+	// Tests are passed over when the program runs.
+	if stdout, _, code := runSigil(t, "", "run", math); code != exitOK || stdout != "" {
+		t.Errorf("run of a test file: got %q (exit %d)", stdout, code)
+	}
+
+	// test is only a keyword where a test declaration starts.
+	if stdout, stderr, code := runSigil(t, "", "run", "-e", `let test = 1; println(string(test + 1)); test "uses it" { test }`); code != exitOK || stdout != "2\n" {
+		t.Errorf("run with test as a name: got %q (exit %d)\n%s", stdout, code, stderr)
+	}
+
+	if _, _, code := runSigil(t, "", "test", filepath.Join(dir, "lib.sgl")); code != exitTypeError {
+		t.Errorf("test of a file with type errors: got exit %d", code)
+	}
+	if _, _, code := runSigil(t, "", "test", "-run", "(", dir); code != exitUsage {
+		t.Errorf("test with an invalid -run: got exit %d", code)
+	}
+	if _, _, code := runSigil(t, "", "test", filepath.Join(dir, "nested", "missing")); code != exitUsage {
+		t.Errorf("test of a missing path: got exit %d", code)
+	}
+}
+
+func TestRunCoverprofile(t *testing.T) {
+	dir := t.TempDir()
+	source := "let sign = fun(n: Number): String {\n    if (n < 0) { \"negative\" } else { \"positive\" }\n};\nprintln(sign(2));\n"
+	profile := filepath.Join(dir, "cover.txt")
+
+	stdout, stderr, code := runSigil(t, "", "run", "--coverprofile="+profile, "-e", source)
+	if code != exitOK || stdout != "positive\n" {
+		t.Fatalf("got %q, %q (exit %d)", stdout, stderr, code)
+	}
+	if want := "coverage: 4 of 5 statements (80.0%), 1 of 2 branches (50.0%)\n"; stderr != want {
+		t.Errorf("got stderr %q, want %q", stderr, want)
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2  1~ |     if (n < 0) { \"negative\" } else { \"positive\" }  [then 0, else 1]\n"; !strings.Contains(string(data), want) {
+		t.Errorf("the text profile does not contain %q:\n%s", want, data)
+	}
+
+	for format, want := range map[string]string{"html": "<!DOCTYPE html>", "lcov": "SF:<expr>\nBRDA:2,0,0,0\n"} {
+		profile := filepath.Join(dir, "cover."+format)
+		_, stderr, code := runSigil(t, "", "run", "--quiet", "--coverprofile="+profile, "--cover-format="+format, "-e", source)
+		if code != exitOK || stderr != "" {
+			t.Errorf("%s: got %q (exit %d)", format, stderr, code)
+		}
+		if data, err := os.ReadFile(profile); err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s: the profile does not contain %q: %s%v", format, want, data, err)
+		}
+	}
+
+	// A program that fails still leaves its profile.
+	_, stderr, code = runSigil(t, "", "run", "--quiet", "--coverprofile="+profile, "-e", "assert(false);\nprintln(\"unreached\");")
+	if code != exitRuntimeError || !strings.Contains(stderr, "E0309") {
+		t.Errorf("got %q (exit %d)", stderr, code)
+	}
+	if data, err := os.ReadFile(profile); err != nil || !strings.Contains(string(data), "1 of 2 statements") {
+		t.Errorf("got profile %s%v", data, err)
+	}
+
+	if _, _, code := runSigil(t, "", "run", "--coverprofile="+profile, "--cover-format=xml", "-e", "1"); code != exitUsage {
+		t.Errorf("--cover-format=xml: got exit %d", code)
+	}
+	if _, stderr, code := runSigil(t, "", "run", "--backend=interpreter", "--coverprofile="+profile, "-e", "1"); code != exitUsage || !strings.Contains(stderr, "cannot record coverage") {
+		t.Errorf("coverage on the interpreter: got %q (exit %d)", stderr, code)
+	}
+	if _, _, code := runSigil(t, "", "run", "--coverprofile="+filepath.Join(dir, "missing", "cover.txt"), "-e", "1"); code != exitUsage {
+		t.Errorf("unwritable profile: got exit %d", code)
+	}
+}
+
+func TestCallgraph(t *testing.T) {
+	source := "let fact = fun(n: Number): Number { if (n < 2) { 1 } else { n * fact(n - 1) } };\nprintln(string(fact(5)));"
+
+	stdout, _, code := runSigil(t, "", "callgraph", "-e", source)
+	if want := "<program> -> println\n<program> -> string\n<program> -> fact\nfact -> fact\n"; code != exitOK || stdout != want {
+		t.Errorf("callgraph: got %q (exit %d), want %q", stdout, code, want)
+	}
+
+	stdout, _, code = runSigil(t, "", "callgraph", "--format=dot", "-e", source)
+	if code != exitOK || !strings.HasPrefix(stdout, "digraph \"calls\" {") || !strings.Contains(stdout, `"f1" -> "f1";`) {
+		t.Errorf("callgraph --format=dot: got %q (exit %d)", stdout, code)
+	}
+
+	if _, _, code := runSigil(t, "", "callgraph", "-e", "undefined()"); code != exitTypeError {
+		t.Errorf("callgraph of a program with type errors: got exit %d", code)
+	}
+	if _, _, code := runSigil(t, "", "callgraph", "--format=png", "-e", "1"); code != exitUsage {
+		t.Errorf("callgraph --format=png: got exit %d", code)
+	}
+}
+
+func TestLSP(t *testing.T) {
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	session := frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`) +
+		frame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.sgl","languageId":"sigil","version":1,"text":"let x = y;"}}}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`) +
+		frame(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	stdout, stderr, code := runSigil(t, session, "lsp")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+	for _, want := range []string{`"hoverProvider":true`, `"code":"E0201"`, `"id":2,"result":null`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("expected %s in the output, got %s", want, stdout)
+		}
+	}
+
+	_, _, code = runSigil(t, frame(`{"jsonrpc":"2.0","method":"exit"}`), "lsp")
+	if code != exitRuntimeError {
+		t.Errorf("expected exit without shutdown to fail, got %d", code)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sigil/internal/repl"
+)
+
+// replCommand starts the interactive prompt, loading the entries of earlier
+// sessions from the history file in the user's home directory and appending
+// the new ones to it.
+func replCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("repl", stderr)
+	historyPath := fs.String("history", defaultHistoryPath(), "file to keep entered lines in, empty to disable")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *historyPath != "" {
+		history, err := os.OpenFile(*historyPath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+		if err == nil {
+			defer history.Close()
+			r := repl.New(stdin, stdout, history)
+			if err := r.LoadHistory(history); err != nil {
+				fmt.Fprintf(stderr, "sigil repl: reading history: %s\n", err)
+			}
+			r.Start()
+			return exitOK
+		}
+	}
+
+	repl.New(stdin, stdout, nil).Start()
+	return exitOK
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sigil_history")
+}
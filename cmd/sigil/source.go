@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+)
+
+// stdin is where "-" reads programs from.
+var stdin io.Reader = os.Stdin
+
+var errNoInput = errors.New("expected a file name, \"-\" or -e")
+
+// readSource returns the program a command should work on: the -e
+// expression if one was given, otherwise the single file argument, where
+// "-" means standard input. The name identifies the program in diagnostics.
+func readSource(fs *flag.FlagSet, expr string) (name string, source string, err error) {
+	if expr != "" {
+		if fs.NArg() != 0 {
+			return "", "", errors.New("cannot combine -e with a file argument")
+		}
+		return "<expr>", expr, nil
+	}
+
+	if fs.NArg() != 1 {
+		return "", "", errNoInput
+	}
+
+	if fs.Arg(0) == "-" {
+		data, err := io.ReadAll(stdin)
+		return "<stdin>", string(data), err
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	return fs.Arg(0), string(data), err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigil/internal/backends"
+	"sigil/internal/compiler"
+	"sigil/internal/testrunner"
+	"strings"
+)
+
+// testCommand runs the tests declared in *_test.sgl files. Each test runs on
+// a backend of its own after the top-level statements of its file. Failures
+// are listed with the error that ended the test, followed by a summary.
+func testCommand(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("test", stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: sigil test [flags] [file.sgl | directory ...]\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	backendName := flags.String("backend", defaultBackend, "execution backend: "+strings.Join(backends.Names(), ", "))
+	run := flags.String("run", "", "only run the tests whose names match this regular expression")
+	verbose := flags.Bool("v", false, "list every test as it runs, not only the failures")
+	diagnostics := diagnosticsFormatFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	factory, ok := backends.Lookup(*backendName)
+	if !ok {
+		fmt.Fprintf(stderr, "sigil test: unknown backend %q, expected one of %s\n", *backendName, strings.Join(backends.Names(), ", "))
+		return exitUsage
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(stderr, "sigil test: invalid -run: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testFiles(paths)
+	if err != nil {
+		fmt.Fprintf(stderr, "sigil test: %s\n", err)
+		return exitUsage
+	}
+
+	t := &tester{
+		factory: factory,
+		filter:  filter,
+		verbose: *verbose,
+		format:  *diagnostics,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+	code := exitOK
+	for _, file := range files {
+		code = max(code, t.file(file))
+	}
+
+	if t.failed > 0 {
+		code = max(code, exitRuntimeError)
+	}
+	status := "PASS"
+	if code != exitOK {
+		status = "FAIL"
+	}
+	fmt.Fprintf(stdout, "%s: %d passed, %d failed\n", status, t.passed, t.failed)
+	return code
+}
+
+// tester runs the tests of one file after another and counts the results.
+type tester struct {
+	factory backends.Factory
+	filter  *regexp.Regexp
+	verbose bool
+	format  string
+	stdout  io.Writer
+	stderr  io.Writer
+
+	passed, failed int
+}
+
+// file runs the tests of one file. A file that does not parse or type check
+// runs none and reports why.
+func (t *tester) file(name string) int {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(t.stderr, "sigil test: %s\n", err)
+		return exitUsage
+	}
+	source := string(data)
+
+	emitter, err := newEmitter(t.format, t.stderr, name, source)
+	if err != nil {
+		fmt.Fprintf(t.stderr, "sigil test: %s\n", err)
+		return exitUsage
+	}
+	defer emitter.Close()
+
+	result := compiler.Compile(source)
+	if code := reportErrors(emitter, result); code != exitOK {
+		return code
+	}
+	program := result.Program
+
+	cfg := &backends.IOConfig{Stdin: stdin, Stdout: t.stdout, Stderr: t.stderr}
+	for _, test := range testrunner.Tests(program, t.filter) {
+		where := fmt.Sprintf("%s:%d", name, test.Token.Line)
+		if t.verbose {
+			fmt.Fprintf(t.stdout, "=== RUN   %s (%s)\n", test.Name.Value, where)
+		}
+
+		if err := testrunner.Run(program, test, t.factory, cfg); err != nil {
+			t.failed++
+			fmt.Fprintf(t.stdout, "--- FAIL: %s (%s)\n", test.Name.Value, where)
+			report(emitter, err)
+			continue
+		}
+		t.passed++
+		if t.verbose {
+			fmt.Fprintf(t.stdout, "--- PASS: %s\n", test.Name.Value)
+		}
+	}
+	return exitOK
+}
+
+// testFiles expands the directories among paths into the *_test.sgl files
+// they hold, in lexical order. Files named on their own are taken whatever
+// their name.
+func testFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(file, "_test.sgl") {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no _test.sgl files found")
+	}
+	return files, nil
+}
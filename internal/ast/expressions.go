@@ -9,9 +9,11 @@ type AssignmentExpression struct {
 	Token lexer.Token // the '=' token
 	Name  *Identifier
 	Value Expression
+	Range lexer.Span
 }
 
 func (ae *AssignmentExpression) expr()                {}
+func (ae *AssignmentExpression) Span() lexer.Span     { return ae.Range }
 func (ae *AssignmentExpression) TokenLiteral() string { return ae.Token.Literal }
 func (ae *AssignmentExpression) String() string {
 	var out strings.Builder
@@ -61,9 +63,11 @@ type CallExpression struct {
 	Token     lexer.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Range     lexer.Span
 }
 
 func (ce *CallExpression) expr()                {}
+func (ce *CallExpression) Span() lexer.Span     { return ce.Range }
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 func (ce *CallExpression) String() string {
 	var out strings.Builder
@@ -117,9 +121,11 @@ type IfExpression struct {
 	Condition   Expression      // The condition to evaluate to decide which branch to take
 	Consequence *BlockStatement // Taken if condition is true
 	Alternative *BlockStatement // Taken if condition is false
+	Range       lexer.Span
 }
 
-func (ie *IfExpression) expr() {}
+func (ie *IfExpression) expr()            {}
+func (ie *IfExpression) Span() lexer.Span { return ie.Range }
 func (ie *IfExpression) String() string {
 	var out strings.Builder
 
@@ -175,9 +181,11 @@ type PrefixExpression struct {
 	Token    lexer.Token // The prefix token, e.g. !
 	Operator string
 	Right    Expression
+	Range    lexer.Span
 }
 
-func (pe *PrefixExpression) expr() {}
+func (pe *PrefixExpression) expr()            {}
+func (pe *PrefixExpression) Span() lexer.Span { return pe.Range }
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal + " " + pe.Right.TokenLiteral()
 }
@@ -211,9 +219,11 @@ type InfixExpression struct {
 	Left     Expression
 	Operator string
 	Right    Expression
+	Range    lexer.Span
 }
 
-func (ie *InfixExpression) expr() {}
+func (ie *InfixExpression) expr()            {}
+func (ie *InfixExpression) Span() lexer.Span { return ie.Range }
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Left.TokenLiteral() + " " + ie.Token.Literal + " " + ie.Right.TokenLiteral()
 }
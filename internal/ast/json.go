@@ -0,0 +1,488 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sigil/internal/lexer"
+)
+
+// EncodeJSON encodes node and every node below it as JSON. A node is an
+// object holding its kind, the name of its type such as "LetStatement", its
+// span, its token if it has one, and its fields under their Go names in
+// lower camel case:
+//
+//	{"kind": "Identifier", "span": {...}, "token": {...}, "value": "x"}
+//
+// A missing optional child, such as the type hint of a let without one, is
+// left out. DecodeJSON turns the result back into an equal tree.
+func EncodeJSON(node Node) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(encodeNode(node)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonToken struct {
+	Type    lexer.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+	Span    jsonSpan        `json:"span"`
+}
+
+// jsonNode holds the fields of every kind of node. Each kind sets only its
+// own, and the fields left nil are not written.
+type jsonNode struct {
+	Kind         string     `json:"kind"`
+	Span         jsonSpan   `json:"span"`
+	Token        *jsonToken `json:"token,omitempty"`
+	Doc          any        `json:"doc,omitempty"`
+	Name         any        `json:"name,omitempty"`
+	TypeHint     any        `json:"typeHint,omitempty"`
+	Operator     any        `json:"operator,omitempty"`
+	Left         any        `json:"left,omitempty"`
+	Right        any        `json:"right,omitempty"`
+	Value        any        `json:"value,omitempty"`
+	ReturnValue  any        `json:"returnValue,omitempty"`
+	Expression   any        `json:"expression,omitempty"`
+	HasSemicolon any        `json:"hasSemicolon,omitempty"`
+	Function     any        `json:"function,omitempty"`
+	Arguments    any        `json:"arguments,omitempty"`
+	Condition    any        `json:"condition,omitempty"`
+	Consequence  any        `json:"consequence,omitempty"`
+	Alternative  any        `json:"alternative,omitempty"`
+	Parameters   any        `json:"parameters,omitempty"`
+	ParamTypes   any        `json:"paramTypes,omitempty"`
+	ReturnType   any        `json:"returnType,omitempty"`
+	Body         any        `json:"body,omitempty"`
+	Statements   any        `json:"statements,omitempty"`
+}
+
+func encodeSpan(span lexer.Span) jsonSpan {
+	return jsonSpan{
+		Start: jsonPosition(span.Start),
+		End:   jsonPosition(span.End),
+	}
+}
+
+func encodeToken(tok lexer.Token) *jsonToken {
+	return &jsonToken{tok.Type, tok.Literal, tok.Line, tok.Column, encodeSpan(tok.Span)}
+}
+
+// encodeNode returns what node is encoded as, nil for a missing node.
+func encodeNode(node Node) any {
+	if isNil(node) {
+		return nil
+	}
+
+	out := &jsonNode{Kind: reflect.TypeOf(node).Elem().Name(), Span: encodeSpan(node.Span())}
+	switch n := node.(type) {
+	case *Program:
+		out.Statements = encodeList(n.Statements)
+	case *ExpressionStatement:
+		out.Token = encodeToken(n.Token)
+		out.Expression = encodeNode(n.Expression)
+		out.HasSemicolon = n.HasSemicolon
+	case *BlockStatement:
+		out.Token = encodeToken(n.Token)
+		out.Statements = encodeList(n.Statements)
+	case *LetStatement:
+		out.Token = encodeToken(n.Token)
+		out.Name = encodeNode(n.Name)
+		out.TypeHint = encodeNode(n.TypeHint)
+		out.Value = encodeNode(n.Value)
+		if n.Doc != "" {
+			out.Doc = n.Doc
+		}
+	case *ReturnStatement:
+		out.Token = encodeToken(n.Token)
+		out.ReturnValue = encodeNode(n.ReturnValue)
+	case *TestStatement:
+		out.Token = encodeToken(n.Token)
+		out.Name = encodeNode(n.Name)
+		out.Body = encodeNode(n.Body)
+	case *Identifier:
+		out.Token = encodeToken(n.Token)
+		out.Value = n.Value
+	case *NumberLiteral:
+		out.Token = encodeToken(n.Token)
+		out.Value = n.Value
+	case *StringLiteral:
+		out.Token = encodeToken(n.Token)
+		out.Value = n.Value
+	case *BooleanLiteral:
+		out.Token = encodeToken(n.Token)
+		out.Value = n.Value
+	case *FunctionParameter:
+		out.Name = encodeNode(n.Name)
+		out.TypeHint = encodeNode(n.TypeHint)
+	case *FunctionLiteral:
+		out.Token = encodeToken(n.Token)
+		if n.Name != "" {
+			out.Name = n.Name
+		}
+		out.Parameters = encodeList(n.Parameters)
+		out.ReturnType = encodeNode(n.ReturnType)
+		out.Body = encodeNode(n.Body)
+	case *SimpleType:
+		out.Token = encodeToken(n.Token)
+		out.Name = n.Name
+	case *FunctionType:
+		out.ParamTypes = encodeList(n.ParamTypes)
+		out.ReturnType = encodeNode(n.ReturnType)
+	case *AssignmentExpression:
+		out.Token = encodeToken(n.Token)
+		out.Name = encodeNode(n.Name)
+		out.Value = encodeNode(n.Value)
+	case *CallExpression:
+		out.Token = encodeToken(n.Token)
+		out.Function = encodeNode(n.Function)
+		out.Arguments = encodeList(n.Arguments)
+	case *IfExpression:
+		out.Token = encodeToken(n.Token)
+		out.Condition = encodeNode(n.Condition)
+		out.Consequence = encodeNode(n.Consequence)
+		out.Alternative = encodeNode(n.Alternative)
+	case *PrefixExpression:
+		out.Token = encodeToken(n.Token)
+		out.Operator = n.Operator
+		out.Right = encodeNode(n.Right)
+	case *InfixExpression:
+		out.Token = encodeToken(n.Token)
+		out.Left = encodeNode(n.Left)
+		out.Operator = n.Operator
+		out.Right = encodeNode(n.Right)
+	}
+	return out
+}
+
+// encodeList encodes a list of nodes. A nil list is encoded as null and an
+// empty one as [], so that decoding gives back the same.
+func encodeList[T Node](nodes []T) []any {
+	if nodes == nil {
+		return nil
+	}
+	out := make([]any, len(nodes))
+	for i, node := range nodes {
+		out[i] = encodeNode(node)
+	}
+	return out
+}
+
+// isNil reports whether node is nil or a nil pointer, as a missing child
+// held in an interface field is.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// DecodeJSON decodes a node encoded by EncodeJSON.
+func DecodeJSON(data []byte) (Node, error) {
+	d := &decoder{}
+	node := d.node(data, "")
+	if d.err != nil {
+		return nil, d.err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("decoding AST: no node")
+	}
+	return node, nil
+}
+
+// decoder decodes nodes, keeping the first error it meets so that each
+// field need not be checked.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(path, format string, args ...any) {
+	if d.err == nil {
+		if path == "" {
+			path = "root"
+		}
+		d.err = fmt.Errorf("decoding AST at %s: %s", path, fmt.Sprintf(format, args...))
+	}
+}
+
+// value decodes the field of a node into v, leaving v alone if the field is
+// missing.
+func (d *decoder) value(fields map[string]json.RawMessage, name, path string, v any) {
+	raw, ok := fields[name]
+	if !ok || d.err != nil {
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail(path+"."+name, "%s", err)
+	}
+}
+
+func (d *decoder) span(fields map[string]json.RawMessage, path string) lexer.Span {
+	var span jsonSpan
+	d.value(fields, "span", path, &span)
+	return lexer.Span{Start: lexer.Position(span.Start), End: lexer.Position(span.End)}
+}
+
+func (d *decoder) token(fields map[string]json.RawMessage, path string) lexer.Token {
+	var tok jsonToken
+	d.value(fields, "token", path, &tok)
+	return lexer.Token{
+		Type:    tok.Type,
+		Literal: tok.Literal,
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Span:    lexer.Span{Start: lexer.Position(tok.Span.Start), End: lexer.Position(tok.Span.End)},
+	}
+}
+
+// node decodes a node, or returns nil for null. The path names where the
+// node is for errors, such as ".statements[0].value".
+func (d *decoder) node(raw json.RawMessage, path string) Node {
+	if d.err != nil || raw == nil || string(raw) == "null" {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		d.fail(path, "%s", err)
+		return nil
+	}
+	var kind string
+	d.value(fields, "kind", path, &kind)
+	span := d.span(fields, path)
+	for _, name := range required[kind] {
+		if raw, ok := fields[name]; !ok || string(raw) == "null" {
+			d.fail(path, "%s has no %s", kind, name)
+			return nil
+		}
+	}
+
+	switch kind {
+	case "Program":
+		return &Program{
+			Statements: list(d, fields, "statements", path, d.statement),
+			Range:      span,
+		}
+	case "ExpressionStatement":
+		n := &ExpressionStatement{Token: d.token(fields, path), Range: span}
+		n.Expression = d.expression(fields["expression"], path+".expression")
+		d.value(fields, "hasSemicolon", path, &n.HasSemicolon)
+		return n
+	case "BlockStatement":
+		return &BlockStatement{
+			Token:      d.token(fields, path),
+			Statements: list(d, fields, "statements", path, d.statement),
+			Range:      span,
+		}
+	case "LetStatement":
+		n := &LetStatement{Token: d.token(fields, path), Range: span}
+		n.Name = d.identifier(fields["name"], path+".name")
+		n.TypeHint = d.typ(fields["typeHint"], path+".typeHint")
+		n.Value = d.expression(fields["value"], path+".value")
+		d.value(fields, "doc", path, &n.Doc)
+		return n
+	case "ReturnStatement":
+		return &ReturnStatement{
+			Token:       d.token(fields, path),
+			ReturnValue: d.expression(fields["returnValue"], path+".returnValue"),
+			Range:       span,
+		}
+	case "TestStatement":
+		return &TestStatement{
+			Token: d.token(fields, path),
+			Name:  d.stringLiteral(fields["name"], path+".name"),
+			Body:  d.block(fields["body"], path+".body"),
+			Range: span,
+		}
+	case "Identifier":
+		n := &Identifier{Token: d.token(fields, path), Range: span}
+		d.value(fields, "value", path, &n.Value)
+		return n
+	case "NumberLiteral":
+		n := &NumberLiteral{Token: d.token(fields, path), Range: span}
+		d.value(fields, "value", path, &n.Value)
+		return n
+	case "StringLiteral":
+		n := &StringLiteral{Token: d.token(fields, path), Range: span}
+		d.value(fields, "value", path, &n.Value)
+		return n
+	case "BooleanLiteral":
+		n := &BooleanLiteral{Token: d.token(fields, path), Range: span}
+		d.value(fields, "value", path, &n.Value)
+		return n
+	case "FunctionParameter":
+		return &FunctionParameter{
+			Name:     d.identifier(fields["name"], path+".name"),
+			TypeHint: d.typ(fields["typeHint"], path+".typeHint"),
+			Range:    span,
+		}
+	case "FunctionLiteral":
+		n := &FunctionLiteral{Token: d.token(fields, path), Range: span}
+		d.value(fields, "name", path, &n.Name)
+		n.Parameters = list(d, fields, "parameters", path, d.parameter)
+		n.ReturnType = d.typ(fields["returnType"], path+".returnType")
+		n.Body = d.block(fields["body"], path+".body")
+		return n
+	case "SimpleType":
+		n := &SimpleType{Token: d.token(fields, path), Range: span}
+		d.value(fields, "name", path, &n.Name)
+		return n
+	case "FunctionType":
+		return &FunctionType{
+			ParamTypes: list(d, fields, "paramTypes", path, d.typ),
+			ReturnType: d.typ(fields["returnType"], path+".returnType"),
+			Range:      span,
+		}
+	case "AssignmentExpression":
+		return &AssignmentExpression{
+			Token: d.token(fields, path),
+			Name:  d.identifier(fields["name"], path+".name"),
+			Value: d.expression(fields["value"], path+".value"),
+			Range: span,
+		}
+	case "CallExpression":
+		return &CallExpression{
+			Token:     d.token(fields, path),
+			Function:  d.expression(fields["function"], path+".function"),
+			Arguments: list(d, fields, "arguments", path, d.expression),
+			Range:     span,
+		}
+	case "IfExpression":
+		return &IfExpression{
+			Token:       d.token(fields, path),
+			Condition:   d.expression(fields["condition"], path+".condition"),
+			Consequence: d.block(fields["consequence"], path+".consequence"),
+			Alternative: d.block(fields["alternative"], path+".alternative"),
+			Range:       span,
+		}
+	case "PrefixExpression":
+		n := &PrefixExpression{Token: d.token(fields, path), Range: span}
+		d.value(fields, "operator", path, &n.Operator)
+		n.Right = d.expression(fields["right"], path+".right")
+		return n
+	case "InfixExpression":
+		n := &InfixExpression{Token: d.token(fields, path), Range: span}
+		n.Left = d.expression(fields["left"], path+".left")
+		d.value(fields, "operator", path, &n.Operator)
+		n.Right = d.expression(fields["right"], path+".right")
+		return n
+	}
+
+	d.fail(path, "unknown node kind %q", kind)
+	return nil
+}
+
+// required lists the fields a node cannot do without, by kind.
+var required = map[string][]string{
+	"ExpressionStatement":  {"expression"},
+	"LetStatement":         {"name", "value"},
+	"TestStatement":        {"name", "body"},
+	"FunctionParameter":    {"name"},
+	"FunctionLiteral":      {"body"},
+	"FunctionType":         {"returnType"},
+	"AssignmentExpression": {"name", "value"},
+	"CallExpression":       {"function"},
+	"IfExpression":         {"condition", "consequence"},
+	"PrefixExpression":     {"right"},
+	"InfixExpression":      {"left", "right"},
+}
+
+// list decodes a list field with decode, which decodes one element.
+func list[T any](d *decoder, fields map[string]json.RawMessage, name, path string, decode func(json.RawMessage, string) T) []T {
+	var raws []json.RawMessage
+	d.value(fields, name, path, &raws)
+	if raws == nil {
+		return nil
+	}
+	out := make([]T, len(raws))
+	for i, raw := range raws {
+		elem := fmt.Sprintf("%s.%s[%d]", path, name, i)
+		if string(raw) == "null" {
+			d.fail(elem, "missing node")
+			return nil
+		}
+		out[i] = decode(raw, elem)
+	}
+	return out
+}
+
+func (d *decoder) statement(raw json.RawMessage, path string) Statement {
+	node := d.node(raw, path)
+	stmt, ok := node.(Statement)
+	if !ok && node != nil {
+		d.fail(path, "expected a statement, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return stmt
+}
+
+func (d *decoder) expression(raw json.RawMessage, path string) Expression {
+	node := d.node(raw, path)
+	expr, ok := node.(Expression)
+	if !ok && node != nil {
+		d.fail(path, "expected an expression, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return expr
+}
+
+func (d *decoder) typ(raw json.RawMessage, path string) Type {
+	node := d.node(raw, path)
+	t, ok := node.(Type)
+	if !ok && node != nil {
+		d.fail(path, "expected a type, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return t
+}
+
+func (d *decoder) identifier(raw json.RawMessage, path string) *Identifier {
+	node := d.node(raw, path)
+	ident, ok := node.(*Identifier)
+	if !ok && node != nil {
+		d.fail(path, "expected an identifier, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return ident
+}
+
+func (d *decoder) stringLiteral(raw json.RawMessage, path string) *StringLiteral {
+	node := d.node(raw, path)
+	lit, ok := node.(*StringLiteral)
+	if !ok && node != nil {
+		d.fail(path, "expected a string, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return lit
+}
+
+func (d *decoder) block(raw json.RawMessage, path string) *BlockStatement {
+	node := d.node(raw, path)
+	block, ok := node.(*BlockStatement)
+	if !ok && node != nil {
+		d.fail(path, "expected a block, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return block
+}
+
+func (d *decoder) parameter(raw json.RawMessage, path string) *FunctionParameter {
+	node := d.node(raw, path)
+	param, ok := node.(*FunctionParameter)
+	if !ok && node != nil {
+		d.fail(path, "expected a function parameter, got %s", reflect.TypeOf(node).Elem().Name())
+	}
+	return param
+}
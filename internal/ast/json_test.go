@@ -0,0 +1,193 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	_ "sigil/internal/backends/interpreter"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return program
+}
+
+// roundTrip encodes and decodes program, checking that the result equals it.
+func roundTrip(t *testing.T, program *ast.Program) *ast.Program {
+	t.Helper()
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatalf("EncodeJSON failed: %v", err)
+	}
+	node, err := ast.DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON failed: %v\n%s", err, data)
+	}
+	decoded, ok := node.(*ast.Program)
+	if !ok {
+		t.Fatalf("decoded a %T, want *ast.Program", node)
+	}
+	if !reflect.DeepEqual(decoded, program) {
+		t.Fatalf("the decoded tree differs:\n%s\nwant\n%s", decoded.TreeString("", true), program.TreeString("", true))
+	}
+
+	again, err := ast.EncodeJSON(decoded)
+	if err != nil || !bytes.Equal(again, data) {
+		t.Fatalf("encoding the decoded tree gave different JSON (%v)", err)
+	}
+	return decoded
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"let x: Number = 1.50; x = x * -2;",
+		`/// Greets.
+let greet = fun(name: String): String { "hi " + name };
+println(greet("<you>"))`,
+		"let f = fun(g: (Number, Bool) -> Number): (Number) -> Bool { fun(n: Number): Bool { !(n > 0) } };",
+		"if (1 < 2) { return 1; } else { false }",
+		"fun(): Number { 1 }();",
+		`test "adds" { assert_eq(2, 1 + 1); }`,
+	}
+	for _, input := range inputs {
+		roundTrip(t, parse(t, input))
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, err := ast.EncodeJSON(parse(t, "let x = 1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Kind       string
+		Statements []struct {
+			Kind  string
+			Name  struct{ Kind, Value string }
+			Value struct {
+				Kind  string
+				Value float64
+				Token struct{ Type, Literal string }
+			}
+			TypeHint any
+			Span     struct {
+				Start, End struct{ Offset, Line, Column int }
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	let := got.Statements[0]
+	if got.Kind != "Program" || let.Kind != "LetStatement" || let.Name.Kind != "Identifier" || let.Name.Value != "x" {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+	if let.Value.Kind != "NumberLiteral" || let.Value.Value != 1 || let.Value.Token.Type != "NUMBER" {
+		t.Errorf("unexpected encoding of the value: %s", data)
+	}
+	if let.TypeHint != nil {
+		t.Errorf("expected no typeHint, got %v", let.TypeHint)
+	}
+	if let.Span.Start.Column != 1 || let.Span.End.Offset != 10 {
+		t.Errorf("unexpected span %+v", let.Span)
+	}
+}
+
+// TestJSONExamples checks that the decoded tree of every example type checks
+// and runs the same as the parsed one.
+func TestJSONExamples(t *testing.T) {
+	err := filepath.WalkDir("../../examples", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".sgl" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		p := parser.New(lexer.New(string(data)))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return nil
+		}
+
+		t.Run(path, func(t *testing.T) {
+			decoded := roundTrip(t, program)
+			if got, want := check(decoded), check(program); got != want {
+				t.Errorf("type checking the decoded tree gave\n%s\nwant\n%s", got, want)
+			}
+			if got, want := run(decoded), run(program); got != want {
+				t.Errorf("running the decoded tree gave\n%s\nwant\n%s", got, want)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func check(program *ast.Program) string {
+	result := typechecker.New().CheckProgram(program)
+	out := fmt.Sprint(result.Type)
+	for _, err := range result.Errors {
+		out += "\n" + err.Error()
+	}
+	return out
+}
+
+func run(program *ast.Program) string {
+	factory, _ := backends.Lookup("evaluator")
+	var out bytes.Buffer
+	backend := factory(&backends.IOConfig{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out})
+	if err := backend.Execute(program, false); err != nil {
+		out.WriteString("error: " + err.Error())
+	}
+	return out.String()
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`[]`, "decoding AST at root: json: cannot unmarshal array"},
+		{`null`, "decoding AST: no node"},
+		{`{"kind": "Nonsense"}`, `decoding AST at root: unknown node kind "Nonsense"`},
+		{`{"kind": "Program", "statements": [null]}`, "decoding AST at .statements[0]: missing node"},
+		{`{"kind": "Program", "statements": [{"kind": "LetStatement", "value": {"kind": "Identifier"}}]}`,
+			"decoding AST at .statements[0]: LetStatement has no name"},
+		{`{"kind": "Program", "statements": [{"kind": "Identifier"}]}`,
+			"decoding AST at .statements[0]: expected a statement, got Identifier"},
+		{`{"kind": "TestStatement", "name": {"kind": "Identifier"}, "body": {"kind": "BlockStatement"}}`,
+			"decoding AST at .name: expected a string, got Identifier"},
+		{`{"kind": "Identifier", "value": 3}`, "decoding AST at .value: json: cannot unmarshal number"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("DecodeJSON(%s): got error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
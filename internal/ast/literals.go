@@ -10,6 +10,9 @@ import (
 // Node represents any node in the AST
 type Node interface {
 	TokenLiteral() string
+	// Span is the range of source the node was parsed from. Nodes built
+	// by hand rather than by the parser have a zero span.
+	Span() lexer.Span
 	String() string
 	TreeString(prefix string, isLast bool) string
 }
@@ -29,8 +32,11 @@ type Expression interface {
 // Program is the root node of every AST
 type Program struct {
 	Statements []Statement
+	Range      lexer.Span
 }
 
+func (p *Program) Span() lexer.Span { return p.Range }
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -61,9 +67,11 @@ func (p *Program) TokenLiteral() string {
 type Identifier struct {
 	Token lexer.Token
 	Value string
+	Range lexer.Span
 }
 
 func (i *Identifier) expr()                {}
+func (i *Identifier) Span() lexer.Span     { return i.Range }
 func (i *Identifier) String() string       { return i.Value }
 func (i *Identifier) TokenLiteral() string { return i.Value }
 
@@ -79,9 +87,11 @@ func (i *Identifier) TreeString(prefix string, isLast bool) string {
 type NumberLiteral struct {
 	Token lexer.Token
 	Value float64
+	Range lexer.Span
 }
 
 func (nl *NumberLiteral) expr()                {}
+func (nl *NumberLiteral) Span() lexer.Span     { return nl.Range }
 func (nl *NumberLiteral) String() string       { return nl.Token.Literal }
 func (nl *NumberLiteral) TokenLiteral() string { return nl.Token.Literal }
 
@@ -96,9 +106,11 @@ func (nl *NumberLiteral) TreeString(prefix string, isLast bool) string {
 type StringLiteral struct {
 	Token lexer.Token
 	Value string
+	Range lexer.Span
 }
 
 func (sl *StringLiteral) expr()                {}
+func (sl *StringLiteral) Span() lexer.Span     { return sl.Range }
 func (sl *StringLiteral) TokenLiteral() string { return sl.Value }
 func (sl *StringLiteral) String() string       { return sl.Value }
 
@@ -114,9 +126,11 @@ func (sl *StringLiteral) TreeString(prefix string, isLast bool) string {
 type BooleanLiteral struct {
 	Token lexer.Token
 	Value bool
+	Range lexer.Span
 }
 
 func (bl *BooleanLiteral) expr()                {}
+func (bl *BooleanLiteral) Span() lexer.Span     { return bl.Range }
 func (bl *BooleanLiteral) TokenLiteral() string { return bl.String() }
 func (bl *BooleanLiteral) String() string {
 	return fmt.Sprintf("%t", bl.Value)
@@ -133,8 +147,12 @@ func (bl *BooleanLiteral) TreeString(prefix string, isLast bool) string {
 type FunctionParameter struct {
 	Name     *Identifier
 	TypeHint Type // may be nil if no type hint provided
+	Range    lexer.Span
 }
 
+func (fp *FunctionParameter) Span() lexer.Span     { return fp.Range }
+func (fp *FunctionParameter) TokenLiteral() string { return fp.Name.TokenLiteral() }
+
 func (fp *FunctionParameter) String() string {
 	if fp.TypeHint != nil {
 		return fp.Name.String() + ": " + fp.TypeHint.String()
@@ -177,9 +195,11 @@ type FunctionLiteral struct {
 	Parameters []*FunctionParameter // a list of parameters, may be empty
 	Body       *BlockStatement
 	ReturnType Type
+	Range      lexer.Span
 }
 
-func (fl *FunctionLiteral) expr() {}
+func (fl *FunctionLiteral) expr()            {}
+func (fl *FunctionLiteral) Span() lexer.Span { return fl.Range }
 
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
@@ -265,9 +285,11 @@ type Type interface {
 type SimpleType struct {
 	Token lexer.Token
 	Name  string
+	Range lexer.Span
 }
 
 func (st *SimpleType) expr()                {}
+func (st *SimpleType) Span() lexer.Span     { return st.Range }
 func (st *SimpleType) typeNode()            {}
 func (st *SimpleType) TokenLiteral() string { return st.Token.Literal }
 func (st *SimpleType) String() string       { return st.Name }
@@ -282,9 +304,11 @@ func (st *SimpleType) TreeString(prefix string, isLast bool) string {
 type FunctionType struct {
 	ParamTypes []Type
 	ReturnType Type
+	Range      lexer.Span
 }
 
 func (ft *FunctionType) expr()                {}
+func (ft *FunctionType) Span() lexer.Span     { return ft.Range }
 func (ft *FunctionType) typeNode()            {}
 func (ft *FunctionType) TokenLiteral() string { return "fn" }
 func (ft *FunctionType) String() string {
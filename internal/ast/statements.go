@@ -9,9 +9,11 @@ type ExpressionStatement struct {
 	Token        lexer.Token // The first token of the expression
 	Expression   Expression
 	HasSemicolon bool
+	Range        lexer.Span
 }
 
 func (es *ExpressionStatement) stmt()                {}
+func (es *ExpressionStatement) Span() lexer.Span     { return es.Range }
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -45,9 +47,11 @@ func (es *ExpressionStatement) TreeString(prefix string, isLast bool) string {
 type BlockStatement struct {
 	Token      lexer.Token // the { token
 	Statements []Statement
+	Range      lexer.Span
 }
 
-func (bs *BlockStatement) stmt() {}
+func (bs *BlockStatement) stmt()            {}
+func (bs *BlockStatement) Span() lexer.Span { return bs.Range }
 func (bs *BlockStatement) String() string {
 	var out strings.Builder
 
@@ -89,9 +93,12 @@ type LetStatement struct {
 	Name     *Identifier
 	TypeHint Type // for type annotations like ': Number'
 	Value    Expression
+	Doc      string // the /// comment before the let, without the slashes
+	Range    lexer.Span
 }
 
 func (ls *LetStatement) stmt()                {}
+func (ls *LetStatement) Span() lexer.Span     { return ls.Range }
 func (ls *LetStatement) TokenLiteral() string { return "let" }
 func (ls *LetStatement) String() string {
 	var out strings.Builder
@@ -134,9 +141,11 @@ func (ls *LetStatement) TreeString(prefix string, isLast bool) string {
 type ReturnStatement struct {
 	Token       lexer.Token // The "return" token
 	ReturnValue Expression  // The expression value to return
+	Range       lexer.Span
 }
 
 func (rs *ReturnStatement) stmt()                {}
+func (rs *ReturnStatement) Span() lexer.Span     { return rs.Range }
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *ReturnStatement) String() string {
 	var out strings.Builder
@@ -174,3 +183,39 @@ func (rs *ReturnStatement) TreeString(prefix string, isLast bool) string {
 
 	return out.String()
 }
+
+// TestStatement declares a test: a named block that `sigil test` runs and
+// that running the program passes over.
+type TestStatement struct {
+	Token lexer.Token // the TEST token
+	Name  *StringLiteral
+	Body  *BlockStatement
+	Range lexer.Span
+}
+
+func (ts *TestStatement) stmt()                {}
+func (ts *TestStatement) Span() lexer.Span     { return ts.Range }
+func (ts *TestStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TestStatement) String() string {
+	return ts.TokenLiteral() + ` "` + ts.Name.Value + `" { ` + ts.Body.String() + " }"
+}
+func (ts *TestStatement) TreeString(prefix string, isLast bool) string {
+	connector := "├── "
+	if isLast {
+		connector = "└── "
+	}
+	var out strings.Builder
+	out.WriteString(prefix + connector + "TestStatement\n")
+
+	childPrefix := prefix
+	if isLast {
+		childPrefix += "    "
+	} else {
+		childPrefix += "│   "
+	}
+
+	out.WriteString(childPrefix + "├── Name: " + ts.Name.String() + "\n")
+	out.WriteString(ts.Body.TreeString(childPrefix, true))
+
+	return out.String()
+}
@@ -26,3 +26,17 @@ func TestLetStatement(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestTestStatement(t *testing.T) {
+	stmt := &TestStatement{
+		Token: lexer.Token{Type: lexer.TEST, Literal: "test"},
+		Name:  &StringLiteral{Token: lexer.Token{Type: lexer.STRING, Literal: "adds"}, Value: "adds"},
+		Body: &BlockStatement{Statements: []Statement{
+			&ExpressionStatement{Expression: &Identifier{Value: "ok"}},
+		}},
+	}
+
+	if stmt.String() != `test "adds" { ok }` {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
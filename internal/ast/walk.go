@@ -0,0 +1,204 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Visitor's Visit method is called for each node Walk meets. If it returns
+// a visitor w, Walk visits the children of the node with w and then calls
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk visits node and everything below it in depth first order: the node,
+// then its children in source order. Missing children, such as the type hint
+// of a let without one or the parts a parse error left out, are skipped. Walk
+// panics on a node type it does not know, so that a new kind of node cannot
+// be passed over unseen.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	eachChild(node, func(child Node) { Walk(v, child) })
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks the tree under node, calling f for each node. If f returns
+// true, Inspect goes on to the children of the node and then calls f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Children returns the nodes directly below node in source order.
+func Children(node Node) []Node {
+	var out []Node
+	eachChild(node, func(child Node) { out = append(out, child) })
+	return out
+}
+
+// eachChild calls f for each child of node in source order.
+func eachChild(node Node, visit func(Node)) {
+	f := func(child Node) {
+		if !missing(child) {
+			visit(child)
+		}
+	}
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			f(stmt)
+		}
+	case *ExpressionStatement:
+		f(n.Expression)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			f(stmt)
+		}
+	case *LetStatement:
+		f(n.Name)
+		f(n.TypeHint)
+		f(n.Value)
+	case *ReturnStatement:
+		f(n.ReturnValue)
+	case *TestStatement:
+		f(n.Name)
+		f(n.Body)
+	case *Identifier, *NumberLiteral, *StringLiteral, *BooleanLiteral, *SimpleType:
+		// leaves
+	case *FunctionParameter:
+		f(n.Name)
+		f(n.TypeHint)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			f(param)
+		}
+		f(n.ReturnType)
+		f(n.Body)
+	case *FunctionType:
+		for _, param := range n.ParamTypes {
+			f(param)
+		}
+		f(n.ReturnType)
+	case *AssignmentExpression:
+		f(n.Name)
+		f(n.Value)
+	case *CallExpression:
+		f(n.Function)
+		for _, arg := range n.Arguments {
+			f(arg)
+		}
+	case *IfExpression:
+		f(n.Condition)
+		f(n.Consequence)
+		f(n.Alternative)
+	case *PrefixExpression:
+		f(n.Right)
+	case *InfixExpression:
+		f(n.Left)
+		f(n.Right)
+	default:
+		panic(fmt.Sprintf("ast: unexpected node type %T", node))
+	}
+}
+
+// Rewrite rebuilds the tree under node from the bottom up. The children of
+// a node are rewritten first and stored back in it, then f is called with
+// the node and Rewrite returns what f returns, which takes the node's place
+// in its parent. Returning the node itself keeps it. The nodes are changed
+// in place rather than copied.
+//
+// Rewrite panics if f returns a node that cannot stand where the old one
+// was, such as a statement in place of an expression.
+func Rewrite(node Node, f func(Node) Node) Node {
+	rewrite := func(child Node) Node {
+		if missing(child) {
+			return child
+		}
+		return Rewrite(child, f)
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for i, stmt := range n.Statements {
+			n.Statements[i] = as[Statement](rewrite(stmt), n, "statement")
+		}
+	case *ExpressionStatement:
+		n.Expression = as[Expression](rewrite(n.Expression), n, "expression")
+	case *BlockStatement:
+		for i, stmt := range n.Statements {
+			n.Statements[i] = as[Statement](rewrite(stmt), n, "statement")
+		}
+	case *LetStatement:
+		n.Name = as[*Identifier](rewrite(n.Name), n, "name")
+		n.TypeHint = as[Type](rewrite(n.TypeHint), n, "type hint")
+		n.Value = as[Expression](rewrite(n.Value), n, "value")
+	case *ReturnStatement:
+		n.ReturnValue = as[Expression](rewrite(n.ReturnValue), n, "return value")
+	case *TestStatement:
+		n.Name = as[*StringLiteral](rewrite(n.Name), n, "name")
+		n.Body = as[*BlockStatement](rewrite(n.Body), n, "body")
+	case *Identifier, *NumberLiteral, *StringLiteral, *BooleanLiteral, *SimpleType:
+		// leaves
+	case *FunctionParameter:
+		n.Name = as[*Identifier](rewrite(n.Name), n, "name")
+		n.TypeHint = as[Type](rewrite(n.TypeHint), n, "type hint")
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = as[*FunctionParameter](rewrite(param), n, "parameter")
+		}
+		n.ReturnType = as[Type](rewrite(n.ReturnType), n, "return type")
+		n.Body = as[*BlockStatement](rewrite(n.Body), n, "body")
+	case *FunctionType:
+		for i, param := range n.ParamTypes {
+			n.ParamTypes[i] = as[Type](rewrite(param), n, "parameter type")
+		}
+		n.ReturnType = as[Type](rewrite(n.ReturnType), n, "return type")
+	case *AssignmentExpression:
+		n.Name = as[*Identifier](rewrite(n.Name), n, "name")
+		n.Value = as[Expression](rewrite(n.Value), n, "value")
+	case *CallExpression:
+		n.Function = as[Expression](rewrite(n.Function), n, "function")
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = as[Expression](rewrite(arg), n, "argument")
+		}
+	case *IfExpression:
+		n.Condition = as[Expression](rewrite(n.Condition), n, "condition")
+		n.Consequence = as[*BlockStatement](rewrite(n.Consequence), n, "consequence")
+		n.Alternative = as[*BlockStatement](rewrite(n.Alternative), n, "alternative")
+	case *PrefixExpression:
+		n.Right = as[Expression](rewrite(n.Right), n, "operand")
+	case *InfixExpression:
+		n.Left = as[Expression](rewrite(n.Left), n, "left operand")
+		n.Right = as[Expression](rewrite(n.Right), n, "right operand")
+	default:
+		panic(fmt.Sprintf("ast: unexpected node type %T", node))
+	}
+	return f(node)
+}
+
+// missing reports whether a child is absent, either as a nil interface or
+// as a nil pointer.
+func missing(node Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
+
+// as converts the rewritten child of parent to the type of the field it goes
+// in. A missing child stays missing.
+func as[T Node](node Node, parent Node, field string) T {
+	t, ok := node.(T)
+	if !ok && node != nil {
+		panic(fmt.Sprintf("ast: cannot use %T as the %s of %T", node, field, parent))
+	}
+	return t
+}
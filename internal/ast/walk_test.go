@@ -0,0 +1,300 @@
+package ast
+
+import (
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// allNodes holds a value of every node type of the package.
+// TestAllNodesListed keeps it complete.
+var allNodes = []Node{
+	&Program{},
+	&ExpressionStatement{},
+	&BlockStatement{},
+	&LetStatement{},
+	&ReturnStatement{},
+	&TestStatement{},
+	&Identifier{},
+	&NumberLiteral{},
+	&StringLiteral{},
+	&BooleanLiteral{},
+	&FunctionParameter{},
+	&FunctionLiteral{},
+	&SimpleType{},
+	&FunctionType{},
+	&AssignmentExpression{},
+	&CallExpression{},
+	&IfExpression{},
+	&PrefixExpression{},
+	&InfixExpression{},
+}
+
+// TestAllNodesListed checks allNodes against the types declared in the
+// package source that have a Span method, as every node has.
+func TestAllNodesListed(t *testing.T) {
+	notTest := func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", notTest, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var declared []string
+	for _, file := range pkgs["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "Span" {
+				continue
+			}
+			if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+				declared = append(declared, star.X.(*goast.Ident).Name)
+			}
+		}
+	}
+
+	var listed []string
+	for _, node := range allNodes {
+		listed = append(listed, reflect.TypeOf(node).Elem().Name())
+	}
+	sort.Strings(declared)
+	sort.Strings(listed)
+	if strings.Join(declared, " ") != strings.Join(listed, " ") {
+		t.Errorf("the node types are\n%v\nbut allNodes lists\n%v", declared, listed)
+	}
+}
+
+var (
+	nodeInterface       = reflect.TypeOf((*Node)(nil)).Elem()
+	expressionInterface = reflect.TypeOf((*Expression)(nil)).Elem()
+	statementInterface  = reflect.TypeOf((*Statement)(nil)).Elem()
+	typeInterface       = reflect.TypeOf((*Type)(nil)).Elem()
+)
+
+// fill sets every field of node that holds nodes, found by reflection, to
+// new nodes and returns them.
+func fill(t *testing.T, node Node) []Node {
+	var set []Node
+	v := reflect.ValueOf(node).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch typ := field.Type(); {
+		case typ.Kind() == reflect.Slice && typ.Elem().Implements(nodeInterface):
+			list := reflect.MakeSlice(typ, 2, 2)
+			for j := 0; j < list.Len(); j++ {
+				child := newNode(t, typ.Elem())
+				list.Index(j).Set(reflect.ValueOf(child))
+				set = append(set, child)
+			}
+			field.Set(list)
+		case typ.Implements(nodeInterface):
+			child := newNode(t, typ)
+			field.Set(reflect.ValueOf(child))
+			set = append(set, child)
+		}
+	}
+	return set
+}
+
+// newNode returns a new node that can be stored in a field of type typ.
+func newNode(t *testing.T, typ reflect.Type) Node {
+	switch typ {
+	case expressionInterface:
+		return &Identifier{Value: "x"}
+	case statementInterface:
+		return &ReturnStatement{}
+	case typeInterface:
+		return &SimpleType{Name: "Number"}
+	}
+	if typ.Kind() != reflect.Pointer {
+		t.Fatalf("no node to put in a field of type %s", typ)
+	}
+	return reflect.New(typ.Elem()).Interface().(Node)
+}
+
+func sameNodes(a, b []Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || x == y
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWalkCoversEveryField(t *testing.T) {
+	for _, proto := range allNodes {
+		typ := reflect.TypeOf(proto).Elem()
+		node := reflect.New(typ).Interface().(Node)
+		want := fill(t, node)
+
+		if got := Children(node); !sameNodes(got, want) {
+			t.Errorf("%s: Children returned %v, want %v", typ.Name(), got, want)
+		}
+
+		var visited []Node
+		Inspect(node, func(n Node) bool {
+			visited = append(visited, n)
+			return n == node
+		})
+		if len(visited) != len(want)+2 || visited[0] != node || visited[len(visited)-1] != nil {
+			t.Errorf("%s: Inspect visited %v", typ.Name(), visited)
+		} else if !sameNodes(visited[1:len(visited)-1], want) {
+			t.Errorf("%s: Inspect visited %v, want %v", typ.Name(), visited[1:len(visited)-1], want)
+		}
+	}
+}
+
+func TestRewriteCoversEveryField(t *testing.T) {
+	for _, proto := range allNodes {
+		typ := reflect.TypeOf(proto).Elem()
+		node := reflect.New(typ).Interface().(Node)
+		old := fill(t, node)
+
+		var replaced []Node
+		got := Rewrite(node, func(n Node) Node {
+			for _, child := range old {
+				if n == child {
+					fresh := reflect.New(reflect.TypeOf(n).Elem()).Interface().(Node)
+					replaced = append(replaced, fresh)
+					return fresh
+				}
+			}
+			return n
+		})
+
+		if got != node {
+			t.Errorf("%s: Rewrite returned %v, want the node itself", typ.Name(), got)
+		}
+		if children := Children(node); !sameNodes(children, replaced) || len(replaced) != len(old) {
+			t.Errorf("%s: after Rewrite the children are %v, want %v", typ.Name(), children, replaced)
+		}
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	// fun(a: Number): Number { a }
+	fn := &FunctionLiteral{
+		Parameters: []*FunctionParameter{{
+			Name:     &Identifier{Value: "a"},
+			TypeHint: &SimpleType{Name: "Number"},
+		}},
+		ReturnType: &SimpleType{Name: "Number"},
+		Body: &BlockStatement{Statements: []Statement{
+			&ExpressionStatement{Expression: &Identifier{Value: "a"}},
+		}},
+	}
+
+	var got []string
+	Inspect(fn, func(n Node) bool {
+		if n != nil {
+			got = append(got, reflect.TypeOf(n).Elem().Name())
+		}
+		return true
+	})
+	want := "FunctionLiteral FunctionParameter Identifier SimpleType SimpleType BlockStatement ExpressionStatement Identifier"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got order %v, want %s", got, want)
+	}
+}
+
+func TestRewriteFoldsConstants(t *testing.T) {
+	// let x = 1 + 2 * 3;
+	let := &LetStatement{
+		Name: &Identifier{Value: "x"},
+		Value: &InfixExpression{
+			Left:     &NumberLiteral{Value: 1},
+			Operator: "+",
+			Right: &InfixExpression{
+				Left:     &NumberLiteral{Value: 2},
+				Operator: "*",
+				Right:    &NumberLiteral{Value: 3},
+			},
+		},
+	}
+
+	Rewrite(let, func(n Node) Node {
+		infix, ok := n.(*InfixExpression)
+		if !ok {
+			return n
+		}
+		left, lok := infix.Left.(*NumberLiteral)
+		right, rok := infix.Right.(*NumberLiteral)
+		if !lok || !rok {
+			return n
+		}
+		switch infix.Operator {
+		case "+":
+			return &NumberLiteral{Value: left.Value + right.Value}
+		case "*":
+			return &NumberLiteral{Value: left.Value * right.Value}
+		}
+		return n
+	})
+
+	if folded, ok := let.Value.(*NumberLiteral); !ok || folded.Value != 7 {
+		t.Errorf("expected the value to fold to 7, got %s", let.Value.TreeString("", true))
+	}
+}
+
+func TestRewriteRejectsMisplacedNodes(t *testing.T) {
+	defer func() {
+		want := "ast: cannot use *ast.ReturnStatement as the expression of *ast.ExpressionStatement"
+		if r := recover(); r != want {
+			t.Errorf("got panic %v, want %q", r, want)
+		}
+	}()
+
+	stmt := &ExpressionStatement{Expression: &Identifier{Value: "x"}}
+	Rewrite(stmt, func(n Node) Node {
+		if _, ok := n.(*Identifier); ok {
+			return &ReturnStatement{}
+		}
+		return n
+	})
+}
+
+func TestWalkRejectsUnknownNodes(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "unexpected node type *ast.unknownNode") {
+			t.Errorf("got panic %v", r)
+		}
+	}()
+	Children(&unknownNode{})
+}
+
+type unknownNode struct{ Identifier }
+
+func TestWalkSkipsMissingChildren(t *testing.T) {
+	// What the parser leaves of "let x = if (y) {" and "f(" after errors.
+	name := &Identifier{Value: "x"}
+	let := &LetStatement{Name: name, Value: &IfExpression{Condition: &Identifier{Value: "y"}}}
+	call := &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{nil}}
+
+	if got := Children(let); len(got) != 2 || got[0] != name {
+		t.Errorf("got children %v", got)
+	}
+	if got := Children(let.Value); len(got) != 1 {
+		t.Errorf("got children %v", got)
+	}
+	if got := Children(call); len(got) != 1 {
+		t.Errorf("got children %v", got)
+	}
+
+	keep := func(n Node) Node { return n }
+	Rewrite(let, keep)
+	Rewrite(call, keep)
+	if let.TypeHint != nil || let.Value.(*IfExpression).Consequence != nil || call.Arguments[0] != nil {
+		t.Errorf("Rewrite filled in missing children")
+	}
+}
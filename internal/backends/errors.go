@@ -0,0 +1,47 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+)
+
+// RuntimeError is an error raised while a program runs, located at the
+// innermost node that was being evaluated when it happened.
+type RuntimeError struct {
+	Code    string // see diagnostic.Explain
+	Message string
+	Span    lexer.Span
+}
+
+// Errorf returns a RuntimeError with the given code. Its location is filled
+// in by Locate as the error leaves the node that raised it.
+func Errorf(code, format string, args ...any) *RuntimeError {
+	return &RuntimeError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *RuntimeError) Error() string { return e.Message }
+
+func (e *RuntimeError) Diagnostic() *diagnostic.Diagnostic {
+	return &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     e.Code,
+		Message:  e.Message,
+		Span:     e.Span,
+	}
+}
+
+// Locate places err at span, unless it already carries a location from a
+// node evaluated further down. Errors that are not RuntimeErrors were not
+// expected to happen and are reported as internal errors.
+func Locate(err error, span lexer.Span) error {
+	var located *RuntimeError
+	if !errors.As(err, &located) {
+		return &RuntimeError{Code: diagnostic.CodeInternal, Message: err.Error(), Span: span}
+	}
+	if located.Span == (lexer.Span{}) {
+		located.Span = span
+	}
+	return err
+}
@@ -1,7 +1,83 @@
 package backends
 
-import "sigil/internal/ast"
+import (
+	"io"
+	"os"
+	"sigil/internal/ast"
+)
 
 type CompilerBackend interface {
 	Execute(program *ast.Program, debug bool) error
 }
+
+// ResultBackend is a backend that can also report the value a program ends
+// with, so that tools can compare what backends compute as well as what they
+// print.
+type ResultBackend interface {
+	CompilerBackend
+
+	// ExecuteResult runs program as Execute does and returns the value of
+	// its last statement that produced one, as assert_eq shows values, or ""
+	// if there is none.
+	ExecuteResult(program *ast.Program) (string, error)
+}
+
+// CoverageBackend is a backend that can record which parts of a program it
+// runs.
+type CoverageBackend interface {
+	CompilerBackend
+
+	// Cover makes the backend report to c what the programs it executes from
+	// then on run.
+	Cover(c Coverage)
+}
+
+// Coverage receives the statements a CoverageBackend executes and the
+// branches of the ifs it takes.
+type Coverage interface {
+	// Statement is called as stmt starts to run.
+	Statement(stmt ast.Statement)
+	// Branch is called as expr picks its consequence, or its alternative,
+	// which may be missing, when consequence is false.
+	Branch(expr *ast.IfExpression, consequence bool)
+}
+
+// IOConfig holds the streams a backend uses for program input and output.
+// Builtins such as print and println write to these instead of the process
+// streams so that output can be captured, redirected or kept separate
+// between concurrently running programs.
+type IOConfig struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// DefaultIO returns a configuration bound to the process streams.
+func DefaultIO() *IOConfig {
+	return &IOConfig{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// WithDefaults returns a copy of the configuration where every unset stream
+// falls back to the matching process stream. A nil config yields DefaultIO.
+func (c *IOConfig) WithDefaults() *IOConfig {
+	cfg := DefaultIO()
+	if c == nil {
+		return cfg
+	}
+
+	if c.Stdin != nil {
+		cfg.Stdin = c.Stdin
+	}
+	if c.Stdout != nil {
+		cfg.Stdout = c.Stdout
+	}
+	if c.Stderr != nil {
+		cfg.Stderr = c.Stderr
+	}
+
+	return cfg
+}
@@ -0,0 +1,68 @@
+package interpreter
+
+import (
+	"io"
+	"sigil/internal/backends"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"testing"
+)
+
+const fibSource = `let fib = fun(n: Number): Number {
+    if (n < 2) {
+        return n;
+    }
+    fib(n - 1) + fib(n - 2)
+};
+fib(20);`
+
+const factorialSource = `let factorial = fun(n: Number): Number {
+    if (n <= 1) {
+        return 1;
+    }
+    n * factorial(n - 1)
+};
+let run = fun(times: Number): Number {
+    if (times == 0) {
+        return 0;
+    }
+    factorial(30);
+    run(times - 1)
+};
+run(1000);`
+
+func benchmarkProgram(b *testing.B, factory backends.Factory, source string) {
+	b.Helper()
+
+	for i := 0; i < b.N; i++ {
+		// Parse each time: the backends keep their globals between runs.
+		b.StopTimer()
+		p := parser.New(lexer.New(source))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			b.Fatalf("parse errors: %v", p.Errors())
+		}
+		backend := factory(&backends.IOConfig{Stdout: io.Discard})
+		b.StartTimer()
+
+		if err := backend.Execute(program, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluatorFibonacci(b *testing.B) {
+	benchmarkProgram(b, NewEvaluator, fibSource)
+}
+
+func BenchmarkInterpreterFibonacci(b *testing.B) {
+	benchmarkProgram(b, New, fibSource)
+}
+
+func BenchmarkEvaluatorFactorial(b *testing.B) {
+	benchmarkProgram(b, NewEvaluator, factorialSource)
+}
+
+func BenchmarkInterpreterFactorial(b *testing.B) {
+	benchmarkProgram(b, New, factorialSource)
+}
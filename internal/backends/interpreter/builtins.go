@@ -2,34 +2,37 @@ package interpreter
 
 import (
 	"fmt"
+	"sigil/internal/backends"
+	"sigil/internal/diagnostic"
+	"strconv"
 )
 
 var builtins = map[string]*Builtin{
 	"len": {
 		Name:  "len",
 		Arity: 1,
-		Fn: func(args ...Value) (Value, error) {
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
 			switch a := args[0].(type) {
 			case *StringValue:
 				return &NumberValue{Value: float64(len(a.Value))}, nil
 			default:
-				return nil, fmt.Errorf("len not defined for type %s", a.Type())
+				return nil, backends.Errorf(diagnostic.CodeBuiltinArgument, "len not defined for type %s", a.Type())
 			}
 		},
 	},
 	"print": {
 		Name:  "print",
 		Arity: -1, // variadic
-		Fn: func(args ...Value) (Value, error) {
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
 			for i, arg := range args {
 				s, ok := arg.(*StringValue)
 				if !ok {
-					return nil, fmt.Errorf("print only accepts strings, got %s", arg.Type())
+					return nil, backends.Errorf(diagnostic.CodeBuiltinArgument, "print only accepts strings, got %s", arg.Type())
 				}
 				if i > 0 {
-					fmt.Print(" ")
+					fmt.Fprint(cfg.Stdout, " ")
 				}
-				fmt.Print(s.Value)
+				fmt.Fprint(cfg.Stdout, s.Value)
 			}
 			return &VoidValue{}, nil
 		},
@@ -37,30 +40,59 @@ var builtins = map[string]*Builtin{
 	"println": {
 		Name:  "println",
 		Arity: -1, // variadic
-		Fn: func(args ...Value) (Value, error) {
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
 			for i, arg := range args {
 				s, ok := arg.(*StringValue)
 				if !ok {
-					return nil, fmt.Errorf("println only accepts strings, got %s", arg.Type())
+					return nil, backends.Errorf(diagnostic.CodeBuiltinArgument, "println only accepts strings, got %s", arg.Type())
 				}
 				if i > 0 {
-					fmt.Print(" ")
+					fmt.Fprint(cfg.Stdout, " ")
 				}
-				fmt.Print(s.Value)
+				fmt.Fprint(cfg.Stdout, s.Value)
+			}
+			fmt.Fprintln(cfg.Stdout)
+			return &VoidValue{}, nil
+		},
+	},
+	"assert": {
+		Name:  "assert",
+		Arity: 1,
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
+			if b, ok := args[0].(*BoolValue); !ok || !b.Value {
+				return nil, backends.Errorf(diagnostic.CodeAssertionFailed, "assertion failed")
+			}
+			return &VoidValue{}, nil
+		},
+	},
+	"assert_eq": {
+		Name:  "assert_eq",
+		Arity: 2,
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
+			if !valuesEqual(args[0], args[1]) {
+				return nil, backends.Errorf(diagnostic.CodeAssertionFailed, "assertion failed: expected %s, got %s",
+					describeValue(args[0]), describeValue(args[1]))
 			}
-			fmt.Println()
 			return &VoidValue{}, nil
 		},
 	},
 	"string": {
 		Name:  "string",
 		Arity: 1,
-		Fn: func(args ...Value) (Value, error) {
+		Fn: func(cfg *backends.IOConfig, args ...Value) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("expected 1 argument, but got %d", len(args))
+				return nil, backends.Errorf(diagnostic.CodeRuntimeArgumentCount, "expected 1 argument, but got %d", len(args))
 			}
 
 			return &StringValue{Value: args[0].String()}, nil
 		},
 	},
 }
+
+// describeValue shows a value in an assertion message, with strings quoted.
+func describeValue(v Value) string {
+	if s, ok := v.(*StringValue); ok {
+		return strconv.Quote(s.Value)
+	}
+	return v.String()
+}
@@ -1,37 +1,116 @@
 package interpreter
 
+import (
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	"sigil/internal/resolver"
+)
+
+// EvaluatorEnvironment holds the values of one scope in slots, at the
+// addresses the resolver gave to their bindings. The global environment also
+// owns the resolver, since every program run in it adds to the same globals,
+// and the coverage recorder, if any.
 type EvaluatorEnvironment struct {
-	store map[string]Object
-	outer *EvaluatorEnvironment
+	slots    []Object
+	outer    *EvaluatorEnvironment
+	global   *EvaluatorEnvironment
+	table    *resolver.Table // the addresses of the code that runs in this scope
+	resolver *resolver.Resolver
+	coverage backends.Coverage
 }
 
-func NewEnclosedEvaluatorEnvironment(outer *EvaluatorEnvironment) *EvaluatorEnvironment {
-	env := NewEvaluatorEnvironment()
-	env.outer = outer
-	return env
+// NewEnclosedEvaluatorEnvironment creates the frame of a function call with
+// room for size bindings. table holds the addresses of the function's body.
+func NewEnclosedEvaluatorEnvironment(outer *EvaluatorEnvironment, table *resolver.Table, size int) *EvaluatorEnvironment {
+	return &EvaluatorEnvironment{
+		slots:  make([]Object, size),
+		outer:  outer,
+		global: outer.global,
+		table:  table,
+	}
 }
 
+// NewEvaluatorEnvironment creates an empty global environment.
 func NewEvaluatorEnvironment() *EvaluatorEnvironment {
-	s := make(map[string]Object)
-	return &EvaluatorEnvironment{store: s}
+	env := &EvaluatorEnvironment{table: &resolver.Table{}, resolver: resolver.New()}
+	env.global = env
+	return env
 }
 
-func (e *EvaluatorEnvironment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+// Resolve assigns addresses to the bindings of node, a program or a
+// top-level statement, and makes room for the globals it declares. The
+// addresses of the program resolved before it are dropped, except by the
+// functions it made.
+func (e *EvaluatorEnvironment) Resolve(node ast.Node) {
+	g := e.global
+	g.table = g.resolver.Resolve(node)
+	g.grow()
+}
 
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+func (e *EvaluatorEnvironment) grow() {
+	for len(e.slots) < e.resolver.Globals() {
+		e.slots = append(e.slots, nil)
 	}
-
-	return obj, ok
 }
 
-func (e *EvaluatorEnvironment) Contains(name string) bool {
-	_, ok := e.store[name]
-	return ok
+// Get returns the value ident is bound to, or false if it has no value yet.
+func (e *EvaluatorEnvironment) Get(ident *ast.Identifier) (Object, bool) {
+	frame, slot := e.locate(ident)
+	obj := frame.slots[slot]
+	return obj, obj != nil
 }
 
-func (e *EvaluatorEnvironment) Set(name string, value Object) Object {
-	e.store[name] = value
+// Set binds ident, the name of a let or parameter or an assignment target.
+func (e *EvaluatorEnvironment) Set(ident *ast.Identifier, value Object) Object {
+	frame, slot := e.locate(ident)
+	frame.slots[slot] = value
 	return value
 }
+
+// Define binds a global name before a program runs.
+func (e *EvaluatorEnvironment) Define(name string, value Object) {
+	g := e.global
+	slot := g.resolver.Global(name)
+	g.grow()
+	g.slots[slot] = value
+}
+
+// locate finds the frame and slot of ident. An identifier that was never
+// resolved, such as one evaluated on its own, is looked up as a global.
+func (e *EvaluatorEnvironment) locate(ident *ast.Identifier) (*EvaluatorEnvironment, int) {
+	addr, ok := e.table.Address(ident)
+	if !ok {
+		g := e.global
+		slot := g.resolver.Global(ident.Value)
+		g.grow()
+		return g, slot
+	}
+	if addr.Depth == resolver.Global {
+		return e.global, addr.Slot
+	}
+
+	frame := e
+	for i := 0; i < addr.Depth; i++ {
+		frame = frame.outer
+	}
+	return frame, addr.Slot
+}
+
+// frameSize returns the number of slots a call of fn needs.
+func (e *EvaluatorEnvironment) frameSize(fn *ast.FunctionLiteral) int {
+	return e.table.FrameSize(fn)
+}
+
+// covered records that stmt runs, when coverage is being recorded.
+func (e *EvaluatorEnvironment) covered(stmt ast.Statement) {
+	if c := e.global.coverage; c != nil {
+		c.Statement(stmt)
+	}
+}
+
+// branched records the branch expr takes, when coverage is being recorded.
+func (e *EvaluatorEnvironment) branched(expr *ast.IfExpression, consequence bool) {
+	if c := e.global.coverage; c != nil {
+		c.Branch(expr, consequence)
+	}
+}
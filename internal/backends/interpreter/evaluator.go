@@ -4,63 +4,138 @@ import (
 	"fmt"
 	"sigil/internal/ast"
 	"sigil/internal/backends"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
 )
 
-type Evaluator struct{}
+type Evaluator struct {
+	io  *backends.IOConfig
+	env *EvaluatorEnvironment
+}
 
-func NewEvaluator() backends.CompilerBackend {
-	return &Evaluator{}
+// NewEvaluator creates a new evaluator instance. Program output is written
+// to the streams in cfg; a nil cfg uses the process streams.
+func NewEvaluator(cfg *backends.IOConfig) backends.CompilerBackend {
+	e := &Evaluator{
+		io:  cfg.WithDefaults(),
+		env: NewEvaluatorEnvironment(),
+	}
+	for name, builtin := range newEvaluatorBuiltins(e.io) {
+		e.env.Define(name, builtin)
+	}
+	return e
 }
 
 func (e *Evaluator) Execute(program *ast.Program, debug bool) error {
+	last, err := e.Run(program)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		fmt.Fprintf(e.io.Stdout, "INTERPRET RESULT: %+v\n", last)
+	}
+
+	return nil
+}
+
+// ExecuteResult implements the ResultBackend interface.
+func (e *Evaluator) ExecuteResult(program *ast.Program) (string, error) {
+	last, err := e.Run(program)
+	if err != nil || last == nil || last == NULL {
+		return "", err
+	}
+	return describe(last), nil
+}
+
+// Run evaluates the program in the evaluator's global environment and returns
+// the value of the last statement that produced one. Bindings persist between
+// runs, so a program may refer to names defined by an earlier one.
+func (e *Evaluator) Run(program *ast.Program) (Object, error) {
+	e.env.Resolve(program)
+
 	var last Object
-	env := NewEvaluatorEnvironment()
 	for _, stmt := range program.Statements {
-		val := Eval(stmt, env)
+		val := Eval(stmt, e.env)
+
+		switch val := val.(type) {
+		case *Error:
+			return nil, &backends.RuntimeError{Code: val.Code, Message: val.Message, Span: val.Span}
+		case *ReturnObject:
+			return val.Value, nil
+		}
 
 		if val != nil {
 			last = val
 		}
 	}
 
-	if debug {
-		fmt.Printf("INTERPRET RESULT: %+v\n", last)
-	}
+	return last, nil
+}
 
-	return nil
+// Cover implements the CoverageBackend interface.
+func (e *Evaluator) Cover(c backends.Coverage) {
+	e.env.coverage = c
 }
 
-// Eval evaluates an AST Node and returns the resulting Object.
+// Define binds a global name to a value before a program runs.
+func (e *Evaluator) Define(name string, value Object) {
+	e.env.Define(name, value)
+}
+
+// Eval evaluates an AST Node and returns the resulting Object. Errors are
+// located at the innermost node they came from. A program is resolved before
+// it runs; any other node must be part of one that env has resolved.
 func Eval(node ast.Node, env *EvaluatorEnvironment) Object {
+	obj := evalNode(node, env)
+	if err, ok := obj.(*Error); ok && err.Span == (lexer.Span{}) {
+		err.Span = node.Span()
+	}
+	return obj
+}
+
+func evalNode(node ast.Node, env *EvaluatorEnvironment) Object {
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
+		env.Resolve(node)
 		return evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
+		env.covered(node)
 		return Eval(node.Expression, env)
 
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 
 	case *ast.LetStatement:
+		env.covered(node)
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+		// The value can return from the function, as in let x = if (c) { return 1; } else { 2 };
+		if _, ok := val.(*ReturnObject); ok {
+			return val
+		}
 		// Not returning the value because
 		// we don't allow things like this: y = x = 10
 		// Returning this value might encourage bad behavior.
-		_ = env.Set(node.Name.Value, val)
+		_ = env.Set(node.Name, val)
 
 	case *ast.ReturnStatement:
+		env.covered(node)
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &ReturnObject{Value: val}
 
+	case *ast.TestStatement:
+		// Tests only run under sigil test.
+		return nil
+
 	// Expressions
 	case *ast.NumberLiteral:
 		return &Number{Value: node.Value}
@@ -77,6 +152,8 @@ func Eval(node ast.Node, env *EvaluatorEnvironment) Object {
 			Parameters: node.Parameters,
 			Body:       node.Body,
 			ReturnType: node.ReturnType,
+			Slots:      env.frameSize(node),
+			Table:      env.table,
 			Env:        env,
 		}
 
@@ -113,19 +190,10 @@ func Eval(node ast.Node, env *EvaluatorEnvironment) Object {
 			return right
 		}
 
-		ok := false
-		e := env
-		for !ok {
-			ok = e.Contains(node.Name.Value)
-			if ok {
-				e.Set(node.Name.Value, right)
-				break
-			} else if e.outer != nil {
-				e = e.outer
-			} else {
-				return newError("undefined variable: %s", node.Name.Value)
-			}
+		if _, ok := env.Get(node.Name); !ok {
+			return newError(diagnostic.CodeUndefinedAtRuntime, "undefined variable: %s", node.Name.Value)
 		}
+		env.Set(node.Name, right)
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -145,9 +213,16 @@ func Eval(node ast.Node, env *EvaluatorEnvironment) Object {
 }
 
 func applyFunction(fun Object, args []Object) Object {
+	if native, ok := fun.(*NativeFunction); ok {
+		if native.Arity != -1 && native.Arity != len(args) {
+			return newError(diagnostic.CodeRuntimeArgumentCount, "argument count mismatch: expected %d, got %d", native.Arity, len(args))
+		}
+		return native.Fn(args...)
+	}
+
 	function, ok := fun.(*Function)
 	if !ok {
-		return newError("not a function: %s", fun.Type())
+		return newError(diagnostic.CodeRuntimeNotCallable, "not a function: %s", fun.Type())
 	}
 
 	extendedEnv := extendFunctionEnvironment(function, args)
@@ -156,10 +231,10 @@ func applyFunction(fun Object, args []Object) Object {
 }
 
 func extendFunctionEnvironment(fun *Function, args []Object) *EvaluatorEnvironment {
-	env := NewEnclosedEvaluatorEnvironment(fun.Env)
+	env := NewEnclosedEvaluatorEnvironment(fun.Env, fun.Table, fun.Slots)
 
 	for paramIdx, param := range fun.Parameters {
-		env.Set(param.Name.Value, args[paramIdx])
+		env.Set(param.Name, args[paramIdx])
 	}
 
 	return env
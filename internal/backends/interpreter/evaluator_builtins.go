@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"sigil/internal/backends"
+	"sigil/internal/diagnostic"
+	"strconv"
+)
+
+// newEvaluatorBuiltins creates the builtin functions for the evaluator.
+// The I/O builtins write to the streams in cfg.
+func newEvaluatorBuiltins(cfg *backends.IOConfig) map[string]*NativeFunction {
+	return map[string]*NativeFunction{
+		"len": {
+			Name:  "len",
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				s, ok := args[0].(*String)
+				if !ok {
+					return newError(diagnostic.CodeBuiltinArgument, "len not defined for type %s", args[0].Type())
+				}
+				return &Number{Value: float64(len(s.Value))}
+			},
+		},
+		"print": {
+			Name:  "print",
+			Arity: -1, // variadic
+			Fn: func(args ...Object) Object {
+				if err := writeStrings(cfg, "print", args); err != nil {
+					return err
+				}
+				return NULL
+			},
+		},
+		"println": {
+			Name:  "println",
+			Arity: -1, // variadic
+			Fn: func(args ...Object) Object {
+				if err := writeStrings(cfg, "println", args); err != nil {
+					return err
+				}
+				fmt.Fprintln(cfg.Stdout)
+				return NULL
+			},
+		},
+		"assert": {
+			Name:  "assert",
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				if args[0] != TRUE {
+					return newError(diagnostic.CodeAssertionFailed, "assertion failed")
+				}
+				return NULL
+			},
+		},
+		"assert_eq": {
+			Name:  "assert_eq",
+			Arity: 2,
+			Fn: func(args ...Object) Object {
+				if !objectsEqual(args[0], args[1]) {
+					return newError(diagnostic.CodeAssertionFailed, "assertion failed: expected %s, got %s", describe(args[0]), describe(args[1]))
+				}
+				return NULL
+			},
+		},
+		"string": {
+			Name:  "string",
+			Arity: 1,
+			Fn: func(args ...Object) Object {
+				return &String{Value: args[0].Inspect()}
+			},
+		},
+	}
+}
+
+// writeStrings writes space separated string arguments to stdout.
+func writeStrings(cfg *backends.IOConfig, name string, args []Object) *Error {
+	for i, arg := range args {
+		s, ok := arg.(*String)
+		if !ok {
+			return newError(diagnostic.CodeBuiltinArgument, "%s only accepts strings, got %s", name, arg.Type())
+		}
+		if i > 0 {
+			fmt.Fprint(cfg.Stdout, " ")
+		}
+		fmt.Fprint(cfg.Stdout, s.Value)
+	}
+	return nil
+}
+
+// objectsEqual compares the values given to assert_eq as == does, except
+// that strings are equal when they hold the same text.
+func objectsEqual(left, right Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch l := left.(type) {
+	case *Number:
+		return math.Abs(l.Value-right.(*Number).Value) <= EPSILON
+	case *String:
+		return l.Value == right.(*String).Value
+	}
+	return left == right
+}
+
+// describe shows a value in an assertion message, with strings quoted.
+func describe(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
@@ -1,6 +1,9 @@
 package interpreter
 
 import (
+	"bytes"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
 	"sigil/internal/lexer"
 	"sigil/internal/parser"
 	"testing"
@@ -227,14 +230,19 @@ func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
 		expectedMessage string
+		expectedText    string // source the error is located at
 	}{
-		{"5 + true", "type mismatch: Number + Boolean"},
-		{"5 + true; 5;", "type mismatch: Number + Boolean"},
-		{"-true", "unknown operator: -Boolean"},
-		{"true + false", "unknown operator: Boolean + Boolean"},
-		{"5; true + false; 5;", "unknown operator: Boolean + Boolean"},
-		{"if (10 > 1) { true + false; }", "unknown operator: Boolean + Boolean"},
-		{"foobar", "identifier not found: foobar"},
+		{"5 + true", "type mismatch: Number + Boolean", "5 + true"},
+		{"5 + true; 5;", "type mismatch: Number + Boolean", "5 + true"},
+		{"-true", "unknown operator: -Boolean", "-true"},
+		{"true + false", "unknown operator: Boolean + Boolean", "true + false"},
+		{"5; true + false; 5;", "unknown operator: Boolean + Boolean", "true + false"},
+		{"if (10 > 1) { true + false; }", "unknown operator: Boolean + Boolean", "true + false"},
+		{"foobar", "identifier not found: foobar", "foobar"},
+		{"1 + (2 * foobar)", "identifier not found: foobar", "foobar"},
+		{"let f = fun(): Number { later }; f()", "identifier not found: later", "later"},
+		{"x = 1", "undefined variable: x", "x = 1"},
+		{"1 + 10 / 0", "division by zero", "10 / 0"},
 	}
 
 	for _, tt := range tests {
@@ -248,6 +256,10 @@ func TestErrorHandling(t *testing.T) {
 		if errObj.Message != tt.expectedMessage {
 			t.Errorf("wrong message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
 		}
+
+		if got := tt.input[errObj.Span.Start.Offset:errObj.Span.End.Offset]; got != tt.expectedText {
+			t.Errorf("wrong location for %q. expected=%q, got=%q", tt.input, tt.expectedText, got)
+		}
 	}
 }
 
@@ -302,9 +314,103 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fun(x: Number, y: Number): Number { x + y }; add(2, 5);", 7},
 		{"let add = fun(x: Number, y: Number): Number { x + y }; add(5 + 5, add(5, 5));", 20},
 		{"fun(x: Number): Number { x }(5);", 5},
+		{"let sign = fun(x: Number): Number { let s = if (x < 0) { return 0; } else { return 1; }; }; sign(5);", 1},
 	}
 
 	for _, tt := range tests {
 		testNumberObject(t, testEval(tt.input), tt.expected)
 	}
 }
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"let fact = fun(n: Number): Number { if (n <= 1) { return 1; } n * fact(n - 1) }; fact(5);", 120},
+		{"let makeAdder = fun(x: Number): (Number) -> Number { fun(y: Number): Number { x + y } }; let add2 = makeAdder(2); add2(3);", 5},
+		{"let count = 0; let inc = fun(): Number { count = count + 1; count }; inc(); inc(); count;", 2},
+		{"let x = 1; let f = fun(): Number { let x = x + 10; x }; f() + x;", 12},
+		{"let later = fun(): Number { value }; let value = 7; later();", 7},
+	}
+
+	for _, tt := range tests {
+		testNumberObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// TestClosuresOutliveTheirProgram runs programs one after another in the same
+// backend: a closure keeps the addresses of the program that made it, while
+// the environment lets go of them.
+func TestClosuresOutliveTheirProgram(t *testing.T) {
+	parse := func(input string) *ast.Program {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %v", p.Errors())
+		}
+		return program
+	}
+	first := parse("let makeCounter = fun(): () -> Number { let n = 0; fun(): Number { n = n + 1; n } }; let count = makeCounter();")
+	second := parse("count(); count()")
+	name := first.Statements[0].(*ast.LetStatement).Name
+
+	evaluator := NewEvaluator(&backends.IOConfig{}).(*Evaluator)
+	interp := New(&backends.IOConfig{}).(*Interpreter)
+	for _, program := range []*ast.Program{first, second} {
+		if _, err := evaluator.Run(program); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := interp.run(program); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := evaluator.ExecuteResult(parse("count()")); err != nil || got != "3" {
+		t.Errorf("evaluator: got %q, %v", got, err)
+	}
+	if got, err := interp.ExecuteResult(parse("count()")); err != nil || got != "3" {
+		t.Errorf("interpreter: got %q, %v", got, err)
+	}
+	if _, ok := evaluator.env.table.Address(name); ok {
+		t.Error("the evaluator still holds the addresses of the first program")
+	}
+	if _, ok := interp.env.table.Address(name); ok {
+		t.Error("the interpreter still holds the addresses of the first program")
+	}
+}
+
+func TestEvaluatorOutput(t *testing.T) {
+	tests := []struct {
+		input string
+		debug bool
+		want  string
+	}{
+		{`print("Hello")`, false, "Hello"},
+		{`print("Hello", "World")`, false, "Hello World"},
+		{`println("Hello", "World"); println("again");`, false, "Hello World\nagain\n"},
+		{`println(string(len("four")))`, false, "4\n"},
+		{`println(string(1000000), string(0.5))`, false, "1000000 0.5\n"},
+		{`println(string(fun(a: Number, b: Number): Number { a + b }))`, false, "fun(a, b) {\n(a + b)\n}\n"},
+		{`1 + 2`, true, "INTERPRET RESULT: &{Value:3}\n"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %v", p.Errors())
+		}
+
+		var stdout bytes.Buffer
+		evaluator := NewEvaluator(&backends.IOConfig{Stdout: &stdout})
+		if err := evaluator.Execute(program, tt.debug); err != nil {
+			t.Fatalf("input %q: execution error: %v", tt.input, err)
+		}
+
+		if stdout.String() != tt.want {
+			t.Errorf("input %q: got output %q, want %q", tt.input, stdout.String(), tt.want)
+		}
+	}
+}
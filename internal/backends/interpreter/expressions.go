@@ -1,6 +1,9 @@
 package interpreter
 
-import "sigil/internal/ast"
+import (
+	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+)
 
 func nativeBoolToBooleanObject(input bool) Object {
 	if input {
@@ -21,9 +24,10 @@ func evalIfExpression(expr *ast.IfExpression, env *EvaluatorEnvironment) Object
 	// we get here.
 	condVal, ok := condition.(*Boolean)
 	if !ok {
-		return newError("type mismatch: expected %s but got %s", BOOLEAN_OBJ, condition.Type())
+		return newError(diagnostic.CodeRuntimeCondition, "type mismatch: expected %s but got %s", BOOLEAN_OBJ, condition.Type())
 	}
 
+	env.branched(expr, condVal.Value)
 	if condVal.Value {
 		return Eval(expr.Consequence, env)
 	} else if expr.Alternative != nil {
@@ -34,9 +38,9 @@ func evalIfExpression(expr *ast.IfExpression, env *EvaluatorEnvironment) Object
 }
 
 func evalIdentifier(expr *ast.Identifier, env *EvaluatorEnvironment) Object {
-	val, ok := env.Get(expr.Value)
+	val, ok := env.Get(expr)
 	if !ok {
-		return newError("identifier not found: %s", expr.Value)
+		return newError(diagnostic.CodeUndefinedAtRuntime, "identifier not found: %s", expr.Value)
 	}
 
 	return val
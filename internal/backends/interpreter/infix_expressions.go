@@ -2,13 +2,14 @@ package interpreter
 
 import (
 	"math"
+	"sigil/internal/diagnostic"
 )
 
 const EPSILON = 1e-9
 
 func evalInfixExpression(operator string, left, right Object) Object {
 	if left.Type() != right.Type() {
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.CodeRuntimeOperand, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	switch {
@@ -26,7 +27,7 @@ func evalInfixExpression(operator string, left, right Object) Object {
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.CodeRuntimeOperand, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -43,6 +44,9 @@ func evalNumberInfixExpression(operator string, left, right Object) Object {
 	case "*":
 		return &Number{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(diagnostic.CodeDivisionByZero, "division by zero")
+		}
 		return &Number{Value: leftVal / rightVal}
 
 	// Comparison
@@ -61,7 +65,7 @@ func evalNumberInfixExpression(operator string, left, right Object) Object {
 	case "!=":
 		return nativeBoolToBooleanObject(math.Abs(leftVal-rightVal) > EPSILON)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.CodeRuntimeOperand, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
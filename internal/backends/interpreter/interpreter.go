@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"sigil/internal/ast"
 	"sigil/internal/backends"
+	"sigil/internal/diagnostic"
+	"sigil/internal/resolver"
+	"strconv"
+	"strings"
 )
 
 // Value represents a runtime value in the interpreter
@@ -13,7 +17,7 @@ type Value interface {
 	Type() string
 }
 
-type BuiltinFunction func(args ...Value) (Value, error)
+type BuiltinFunction func(cfg *backends.IOConfig, args ...Value) (Value, error)
 type Builtin struct {
 	Name  string
 	Fn    BuiltinFunction
@@ -38,7 +42,7 @@ type NumberValue struct {
 	Value float64
 }
 
-func (nv *NumberValue) String() string { return fmt.Sprintf("%g", nv.Value) }
+func (nv *NumberValue) String() string { return strconv.FormatFloat(nv.Value, 'f', -1, 64) }
 func (nv *NumberValue) Type() string   { return "Number" }
 
 type StringValue struct {
@@ -67,70 +71,148 @@ type VoidValue struct{}
 func (v *VoidValue) Type() string   { return "Void" }
 func (v *VoidValue) String() string { return "" }
 
-// Runtime environment for variable storage
+// Runtime environment for variable storage. Values sit in slots at the
+// addresses the resolver gave to their bindings; the global environment owns
+// the resolver.
 type Environment struct {
-	store map[string]Value
-	outer *Environment
+	slots    []Value
+	outer    *Environment
+	global   *Environment
+	table    *resolver.Table // the addresses of the code that runs in this scope
+	resolver *resolver.Resolver
 }
 
 func NewEnvironment() *Environment {
+	env := &Environment{table: &resolver.Table{}, resolver: resolver.New()}
+	env.global = env
+	return env
+}
+
+func NewEnclosedEnvironment(outer *Environment, table *resolver.Table, size int) *Environment {
 	return &Environment{
-		store: make(map[string]Value),
-		outer: nil,
+		slots:  make([]Value, size),
+		outer:  outer,
+		global: outer.global,
+		table:  table,
 	}
 }
 
-func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+// Resolve assigns addresses to the bindings of a program or a top-level
+// statement and makes room for the globals it declares. The addresses of the
+// program resolved before it are dropped, except by the functions it made.
+func (e *Environment) Resolve(node ast.Node) {
+	g := e.global
+	g.table = g.resolver.Resolve(node)
+	g.grow()
 }
 
-func (e *Environment) Get(name string) (Value, bool) {
-	value, ok := e.store[name]
-	if !ok && e.outer != nil {
-		value, ok = e.outer.Get(name)
+func (e *Environment) grow() {
+	for len(e.slots) < e.resolver.Globals() {
+		e.slots = append(e.slots, nil)
 	}
-	return value, ok
 }
 
-func (e *Environment) Set(name string, value Value) {
-	e.store[name] = value
+func (e *Environment) Get(ident *ast.Identifier) (Value, bool) {
+	frame, slot := e.locate(ident)
+	value := frame.slots[slot]
+	return value, value != nil
+}
+
+func (e *Environment) Set(ident *ast.Identifier, value Value) {
+	frame, slot := e.locate(ident)
+	frame.slots[slot] = value
+}
+
+// frameSize returns the number of slots a call of fn needs.
+func (e *Environment) frameSize(fn *ast.FunctionLiteral) int {
+	return e.table.FrameSize(fn)
+}
+
+// locate finds the frame and slot of ident. An identifier that was never
+// resolved is looked up as a global.
+func (e *Environment) locate(ident *ast.Identifier) (*Environment, int) {
+	addr, ok := e.table.Address(ident)
+	if !ok {
+		g := e.global
+		slot := g.resolver.Global(ident.Value)
+		g.grow()
+		return g, slot
+	}
+	if addr.Depth == resolver.Global {
+		return e.global, addr.Slot
+	}
+
+	frame := e
+	for i := 0; i < addr.Depth; i++ {
+		frame = frame.outer
+	}
+	return frame, addr.Slot
 }
 
 // Interpreter implements the CompilerBackend interface
 type Interpreter struct {
 	env *Environment
+	io  *backends.IOConfig
 }
 
-// New creates a new interpreter instance
-func New() backends.CompilerBackend {
+// New creates a new interpreter instance. Program output is written to the
+// streams in cfg; a nil cfg uses the process streams.
+func New(cfg *backends.IOConfig) backends.CompilerBackend {
 	return &Interpreter{
 		env: NewEnvironment(),
+		io:  cfg.WithDefaults(),
 	}
 }
 
 // Execute implements the CompilerBackend interface
 func (i *Interpreter) Execute(program *ast.Program, debug bool) error {
+	last, err := i.run(program)
+	if err != nil {
+		return err
+	}
+
+	if debug {
+		fmt.Fprintf(i.io.Stdout, "INTERPRET RESULT: %+v\n", last)
+	}
+	return nil
+}
+
+// ExecuteResult implements the ResultBackend interface.
+func (i *Interpreter) ExecuteResult(program *ast.Program) (string, error) {
+	last, err := i.run(program)
+	if _, void := last.(*VoidValue); err != nil || last == nil || void {
+		return "", err
+	}
+	return describeValue(last), nil
+}
+
+// run executes the program and returns the value of the last statement that
+// produced one.
+func (i *Interpreter) run(program *ast.Program) (Value, error) {
+	i.env.Resolve(program)
+
 	var last Value
 	for _, stmt := range program.Statements {
-		val, err := i.ExecuteStatement(stmt)
+		val, err := i.executeStatement(stmt)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if val != nil {
 			last = val
 		}
 	}
-
-	if debug {
-		fmt.Printf("INTERPRET RESULT: %+v\n", last)
-	}
-	return nil
+	return last, nil
 }
 
 // --- Statement Execution ---
+
+// ExecuteStatement runs a top-level statement in the global environment.
 func (i *Interpreter) ExecuteStatement(stmt ast.Statement) (Value, error) {
+	i.env.Resolve(stmt)
+	return i.executeStatement(stmt)
+}
+
+func (i *Interpreter) executeStatement(stmt ast.Statement) (Value, error) {
 	switch s := stmt.(type) {
 	case *ast.LetStatement:
 		return i.executeLetStatement(s)
@@ -142,8 +224,10 @@ func (i *Interpreter) ExecuteStatement(stmt ast.Statement) (Value, error) {
 			return nil, err
 		}
 		return &ReturnValue{Value: val}, nil
+	case *ast.TestStatement:
+		return nil, nil // tests only run under sigil test
 	default:
-		return nil, fmt.Errorf("unknown statement type: %T", stmt)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown statement type: %T", stmt)
 	}
 }
 
@@ -152,7 +236,10 @@ func (i *Interpreter) executeLetStatement(stmt *ast.LetStatement) (Value, error)
 	if err != nil {
 		return nil, err
 	}
-	i.env.Set(stmt.Name.Value, value)
+	if ret, ok := value.(*ReturnValue); ok {
+		return ret, nil // the value returned from the function
+	}
+	i.env.Set(stmt.Name, value)
 	return nil, nil // assignments don’t produce a value
 }
 
@@ -162,8 +249,8 @@ func (i *Interpreter) executeExpressionStatement(stmt *ast.ExpressionStatement)
 		return nil, err
 	}
 
-	// Only discard if semicolon is present
-	if stmt.HasSemicolon {
+	// Only discard if semicolon is present, and never discard a return
+	if _, ok := val.(*ReturnValue); stmt.HasSemicolon && !ok {
 		return nil, nil
 	}
 
@@ -172,6 +259,14 @@ func (i *Interpreter) executeExpressionStatement(stmt *ast.ExpressionStatement)
 
 // --- Expression Evaluation ---
 func (i *Interpreter) evaluateExpression(expr ast.Expression) (Value, error) {
+	val, err := i.evaluateNode(expr)
+	if err != nil {
+		return nil, backends.Locate(err, expr.Span())
+	}
+	return val, nil
+}
+
+func (i *Interpreter) evaluateNode(expr ast.Expression) (Value, error) {
 	switch e := expr.(type) {
 	case *ast.NumberLiteral:
 		return &NumberValue{Value: e.Value}, nil
@@ -194,12 +289,12 @@ func (i *Interpreter) evaluateExpression(expr ast.Expression) (Value, error) {
 	case *ast.AssignmentExpression:
 		return i.evaluateAssignmentExpression(e)
 	default:
-		return nil, fmt.Errorf("unknown expression type: %T", expr)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown expression type: %T", expr)
 	}
 }
 
 func (i *Interpreter) evaluateIdentifier(ident *ast.Identifier) (Value, error) {
-	value, exists := i.env.Get(ident.Value)
+	value, exists := i.env.Get(ident)
 	if exists {
 		return value, nil
 	}
@@ -208,7 +303,7 @@ func (i *Interpreter) evaluateIdentifier(ident *ast.Identifier) (Value, error) {
 		return builtin, nil
 	}
 
-	return nil, fmt.Errorf("undefined variable: %s", ident.Value)
+	return nil, backends.Errorf(diagnostic.CodeUndefinedAtRuntime, "undefined variable: %s", ident.Value)
 }
 
 func (i *Interpreter) evaluateInfixExpression(expr *ast.InfixExpression) (Value, error) {
@@ -241,7 +336,7 @@ func (i *Interpreter) evaluateIfExpression(expr *ast.IfExpression) (Value, error
 
 	boolCond, ok := condValue.(*BoolValue)
 	if !ok {
-		return nil, fmt.Errorf("if condition must be Bool, got %T", condValue)
+		return nil, backends.Errorf(diagnostic.CodeRuntimeCondition, "if condition must be Bool, got %T", condValue)
 	}
 
 	if boolCond.Value {
@@ -256,7 +351,7 @@ func (i *Interpreter) evaluateIfExpression(expr *ast.IfExpression) (Value, error
 func (i *Interpreter) evaluateBlockStatement(block *ast.BlockStatement) (Value, error) {
 	var result Value
 	for _, stmt := range block.Statements {
-		val, err := i.ExecuteStatement(stmt)
+		val, err := i.executeStatement(stmt)
 		if err != nil {
 			return nil, err
 		}
@@ -283,6 +378,8 @@ func (i *Interpreter) evaluateFunctionLiteral(fun *ast.FunctionLiteral) (Value,
 		Name:       fun.Name,
 		Parameters: fun.Parameters,
 		Body:       fun.Body,
+		Slots:      i.env.frameSize(fun),
+		Table:      i.env.table,
 		Env:        i.env, // capture current environment for closures
 	}, nil
 }
@@ -292,7 +389,7 @@ func (i *Interpreter) evaluateAssignmentExpression(expr *ast.AssignmentExpressio
 	if err != nil {
 		return nil, err
 	}
-	i.env.Set(expr.Name.Value, exp)
+	i.env.Set(expr.Name, exp)
 	return exp, nil
 }
 
@@ -305,7 +402,7 @@ func (i *Interpreter) applyCallExpression(ce *ast.CallExpression) (Value, error)
 
 	if bf, ok := fnValue.(*Builtin); ok {
 		if bf.Arity != -1 && bf.Arity != len(ce.Arguments) {
-			return nil, fmt.Errorf("argument count mismatch: expected %d, got %d",
+			return nil, backends.Errorf(diagnostic.CodeRuntimeArgumentCount, "argument count mismatch: expected %d, got %d",
 				bf.Arity, len(ce.Arguments))
 		}
 
@@ -319,17 +416,17 @@ func (i *Interpreter) applyCallExpression(ce *ast.CallExpression) (Value, error)
 			values = append(values, val)
 		}
 
-		return bf.Fn(values...)
+		return bf.Fn(i.io, values...)
 	}
 
 	fv, ok := fnValue.(*FunctionValue)
 	if !ok {
-		return nil, fmt.Errorf("attempted to call a non-function value: %T", fnValue)
+		return nil, backends.Errorf(diagnostic.CodeRuntimeNotCallable, "attempted to call a non-function value: %T", fnValue)
 	}
 
 	// Check argument count
 	if len(ce.Arguments) != len(fv.Parameters) {
-		return nil, fmt.Errorf("argument count mismatch: expected %d, got %d",
+		return nil, backends.Errorf(diagnostic.CodeRuntimeArgumentCount, "argument count mismatch: expected %d, got %d",
 			len(fv.Parameters), len(ce.Arguments))
 	}
 
@@ -344,9 +441,9 @@ func (i *Interpreter) applyCallExpression(ce *ast.CallExpression) (Value, error)
 	}
 
 	// Create a new environment for this function call
-	callEnv := NewEnclosedEnvironment(fv.Env)
+	callEnv := NewEnclosedEnvironment(fv.Env, fv.Table, fv.Slots)
 	for i, param := range fv.Parameters {
-		callEnv.Set(param.Name.Value, args[i])
+		callEnv.Set(param.Name, args[i])
 	}
 
 	// Evaluate the function body in the new environment
@@ -374,7 +471,7 @@ func (i *Interpreter) applyInfixOperator(operator string, left, right Value) (Va
 			if rStr, ok := right.(*StringValue); ok {
 				return i.applyConcat(lStr, rStr)
 			}
-			return nil, fmt.Errorf("cannot concatenate String with %T", right)
+			return nil, backends.Errorf(diagnostic.CodeRuntimeOperand, "cannot concatenate String with %T", right)
 		}
 
 		return i.applyArithmeticOperator(operator, left, right)
@@ -385,7 +482,7 @@ func (i *Interpreter) applyInfixOperator(operator string, left, right Value) (Va
 	case "<", ">", "<=", ">=":
 		return i.applyComparisonOperator(operator, left, right)
 	default:
-		return nil, fmt.Errorf("unknown infix operator: %s", operator)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown infix operator: %s", operator)
 	}
 }
 
@@ -393,7 +490,7 @@ func (i *Interpreter) applyArithmeticOperator(operator string, left, right Value
 	leftNum, leftOk := left.(*NumberValue)
 	rightNum, rightOk := right.(*NumberValue)
 	if !leftOk || !rightOk {
-		return nil, fmt.Errorf("arithmetic operators require numbers")
+		return nil, backends.Errorf(diagnostic.CodeRuntimeOperand, "arithmetic operators require numbers")
 	}
 	switch operator {
 	case "+":
@@ -404,11 +501,11 @@ func (i *Interpreter) applyArithmeticOperator(operator string, left, right Value
 		return &NumberValue{Value: leftNum.Value * rightNum.Value}, nil
 	case "/":
 		if rightNum.Value == 0 {
-			return nil, fmt.Errorf("division by zero")
+			return nil, backends.Errorf(diagnostic.CodeDivisionByZero, "division by zero")
 		}
 		return &NumberValue{Value: leftNum.Value / rightNum.Value}, nil
 	default:
-		return nil, fmt.Errorf("unknown arithmetic operator: %s", operator)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown arithmetic operator: %s", operator)
 	}
 }
 
@@ -419,11 +516,11 @@ func (i *Interpreter) applyConcat(left, right *StringValue) (Value, error) {
 func (i *Interpreter) applyEqualityOperator(operator string, left, right Value) (Value, error) {
 	switch operator {
 	case "==":
-		return &BoolValue{Value: i.valuesEqual(left, right)}, nil
+		return &BoolValue{Value: valuesEqual(left, right)}, nil
 	case "!=":
-		return &BoolValue{Value: !i.valuesEqual(left, right)}, nil
+		return &BoolValue{Value: !valuesEqual(left, right)}, nil
 	default:
-		return nil, fmt.Errorf("unknown equality operator: %s", operator)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown equality operator: %s", operator)
 	}
 }
 
@@ -431,7 +528,7 @@ func (i *Interpreter) applyComparisonOperator(operator string, left, right Value
 	leftNum, leftOk := left.(*NumberValue)
 	rightNum, rightOk := right.(*NumberValue)
 	if !leftOk || !rightOk {
-		return nil, fmt.Errorf("comparison operators require numbers")
+		return nil, backends.Errorf(diagnostic.CodeRuntimeOperand, "comparison operators require numbers")
 	}
 	switch operator {
 	case "<":
@@ -443,7 +540,7 @@ func (i *Interpreter) applyComparisonOperator(operator string, left, right Value
 	case ">=":
 		return &BoolValue{Value: leftNum.Value >= rightNum.Value}, nil
 	default:
-		return nil, fmt.Errorf("unknown comparison operator: %s", operator)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown comparison operator: %s", operator)
 	}
 }
 
@@ -452,22 +549,22 @@ func (i *Interpreter) applyPrefixOperator(operator string, operand Value) (Value
 	case "-":
 		num, ok := operand.(*NumberValue)
 		if !ok {
-			return nil, fmt.Errorf("unary minus requires a number")
+			return nil, backends.Errorf(diagnostic.CodeRuntimeOperand, "unary minus requires a number")
 		}
 		return &NumberValue{Value: -num.Value}, nil
 	case "!":
 		boolVal, ok := operand.(*BoolValue)
 		if !ok {
-			return nil, fmt.Errorf("logical not requires a boolean")
+			return nil, backends.Errorf(diagnostic.CodeRuntimeOperand, "logical not requires a boolean")
 		}
 		return &BoolValue{Value: !boolVal.Value}, nil
 	default:
-		return nil, fmt.Errorf("unknown prefix operator: %s", operator)
+		return nil, backends.Errorf(diagnostic.CodeInternal, "unknown prefix operator: %s", operator)
 	}
 }
 
 // --- Utility ---
-func (i *Interpreter) valuesEqual(left, right Value) bool {
+func valuesEqual(left, right Value) bool {
 	if left.Type() != right.Type() {
 		return false
 	}
@@ -490,19 +587,18 @@ type FunctionValue struct {
 	Name       string
 	Parameters []*ast.FunctionParameter
 	Body       *ast.BlockStatement
+	Slots      int             // size of the frame a call needs
+	Table      *resolver.Table // the addresses of the program it is part of
 	Env        *Environment
 }
 
+// String returns the function's source form, as the evaluator shows it.
 func (fv *FunctionValue) String() string {
-	var out bytes.Buffer
-	out.WriteString("<fun '")
-	out.WriteString(fv.Name)
-	out.WriteString(fmt.Sprintf("' %d param", len(fv.Parameters)))
-	if len(fv.Parameters) != 1 {
-		out.WriteString("s")
+	params := []string{}
+	for _, p := range fv.Parameters {
+		params = append(params, p.Name.String())
 	}
-	out.WriteString(">")
-	return out.String()
+	return "fun(" + strings.Join(params, ", ") + ") {\n" + fv.Body.String() + "\n}"
 }
 
 func (fv *FunctionValue) Type() string { return "Function" }
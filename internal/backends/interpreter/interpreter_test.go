@@ -1,13 +1,16 @@
 package interpreter
 
 import (
+	"bytes"
+	"sigil/internal/backends"
 	"sigil/internal/lexer"
 	"sigil/internal/parser"
 	"testing"
 )
 
-// evalInput executes a program and returns the value of the last non-void expression.
-func evalInput(t *testing.T, input string) Value {
+// evalInput executes a program and returns the value of the last non-void
+// expression and what the program printed.
+func evalInput(t *testing.T, input string) (Value, string) {
 	t.Helper()
 
 	l := lexer.New(input)
@@ -17,7 +20,8 @@ func evalInput(t *testing.T, input string) Value {
 		t.Fatalf("parse errors: %v", p.Errors())
 	}
 
-	interp := New().(*Interpreter)
+	var stdout bytes.Buffer
+	interp := New(&backends.IOConfig{Stdout: &stdout}).(*Interpreter)
 
 	var result Value
 	for _, stmt := range program.Statements {
@@ -30,26 +34,38 @@ func evalInput(t *testing.T, input string) Value {
 		}
 	}
 
-	return result
+	return result, stdout.String()
 }
 
 func TestInterpreterExecution(t *testing.T) {
 	tests := []struct {
-		input string
-		want  interface{}
+		input  string
+		want   interface{}
+		output string // what the program prints before its value or error
 	}{
-		{"1 + 2", 3.0},
-		{"let x: Number = 5; x", 5.0},
-		{"if (true) { 10 } else { 20 }", 10.0},
-		{"if (false) { 10 } else { 20 }", 20.0},
-		{"fun(x: Number): Number { x + 1 }(5)", 6.0},
-		{"let add = fun(x: Number, y: Number): Number { x + y }; add(2,3)", 5.0},
-		{`"Hello" + " World!"`, "Hello World!"},
+		{"1 + 2", 3.0, ""},
+		{"let x: Number = 5; x", 5.0, ""},
+		{"if (true) { 10 } else { 20 }", 10.0, ""},
+		{"if (false) { 10 } else { 20 }", 20.0, ""},
+		{"fun(x: Number): Number { x + 1 }(5)", 6.0, ""},
+		{"let add = fun(x: Number, y: Number): Number { x + y }; add(2,3)", 5.0, ""},
+		{"let fact = fun(n: Number): Number { if (n <= 1) { return 1; } n * fact(n - 1) }; fact(5)", 120.0, ""},
+		{"let f = fun(): Number { if (true) { return 5; } else { 0 }; 1 }; f()", 5.0, ""},
+		{"let sign = fun(x: Number): Number { let s = if (x < 0) { return 0; } else { return 1; }; }; sign(5)", 1.0, ""},
+		{"let makeAdder = fun(x: Number): (Number) -> Number { fun(y: Number): Number { x + y } }; let add2 = makeAdder(2); add2(3)", 5.0, ""},
+		// Assignments inside a function update the binding they resolve to.
+		{"let count = 0; let inc = fun(): Number { count = count + 1; count }; inc(); inc(); count", 2.0, ""},
+		{"let x = 1; let f = fun(): Number { let x = x + 10; x }; f() + x", 12.0, ""},
+		{`"Hello" + " World!"`, "Hello World!", ""},
 		// String concatenation with conversion
 		// {`"Hello " + string(42)`, "Hello 42"}, // Can't do this yet, no builtin string function
 		// Edge case: concatenating non-strings should produce an error
-		{`"Hello " + 42`, "error"},
-		{`1 + " World!"`, "error"},
+		{`"Hello " + 42`, "error", ""},
+		{`1 + " World!"`, "error", ""},
+		// Output written before the value or the error is kept.
+		{`println("adding"); 1 + 2`, 3.0, "adding\n"},
+		{`print("a"); print("b"); true`, true, "ab"},
+		{`println("before"); "Hello " + 42`, "error", "before\n"},
 	}
 
 	for _, tt := range tests {
@@ -62,7 +78,8 @@ func TestInterpreterExecution(t *testing.T) {
 				t.Fatalf("parse errors: %v", p.Errors())
 			}
 
-			interp := New().(*Interpreter)
+			var stdout bytes.Buffer
+			interp := New(&backends.IOConfig{Stdout: &stdout}).(*Interpreter)
 			var execErr error
 			for _, stmt := range program.Statements {
 				_, execErr = interp.ExecuteStatement(stmt)
@@ -74,11 +91,17 @@ func TestInterpreterExecution(t *testing.T) {
 			if execErr == nil {
 				t.Errorf("input %q: expected execution error, got none", tt.input)
 			}
+			if stdout.String() != tt.output {
+				t.Errorf("input %q: got output %q, want %q", tt.input, stdout.String(), tt.output)
+			}
 
 			continue
 		}
 
-		got := evalInput(t, tt.input)
+		got, output := evalInput(t, tt.input)
+		if output != tt.output {
+			t.Errorf("input %q: got output %q, want %q", tt.input, output, tt.output)
+		}
 
 		switch want := tt.want.(type) {
 		case float64:
@@ -110,3 +133,38 @@ func TestInterpreterExecution(t *testing.T) {
 		}
 	}
 }
+
+func TestInterpreterOutput(t *testing.T) {
+	tests := []struct {
+		input string
+		debug bool
+		want  string
+	}{
+		{`print("Hello")`, false, "Hello"},
+		{`print("Hello", "World")`, false, "Hello World"},
+		{`println("Hello", "World"); println("again");`, false, "Hello World\nagain\n"},
+		{`println(string(len("four")))`, false, "4\n"},
+		{`println(string(1000000), string(0.5))`, false, "1000000 0.5\n"},
+		{`println(string(fun(a: Number, b: Number): Number { a + b }))`, false, "fun(a, b) {\n(a + b)\n}\n"},
+		{`1 + 2`, true, "INTERPRET RESULT: 3\n"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %v", p.Errors())
+		}
+
+		var stdout bytes.Buffer
+		interp := New(&backends.IOConfig{Stdout: &stdout})
+		if err := interp.Execute(program, tt.debug); err != nil {
+			t.Fatalf("input %q: execution error: %v", tt.input, err)
+		}
+
+		if stdout.String() != tt.want {
+			t.Errorf("input %q: got output %q, want %q", tt.input, stdout.String(), tt.want)
+		}
+	}
+}
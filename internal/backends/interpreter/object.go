@@ -3,6 +3,8 @@ package interpreter
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/lexer"
+	"sigil/internal/resolver"
 	"strconv"
 	"strings"
 )
@@ -21,6 +23,8 @@ const (
 	RETURN_OBJ   = "RETURN_OBJ"
 	ERROR_OBJ    = "Error"
 	FUNCTION_OBJ = "Function"
+
+	NATIVE_FUNCTION_OBJ = "NativeFunction"
 )
 
 type ObjectType string
@@ -73,14 +77,16 @@ func (ro *ReturnObject) Inspect() string  { return ro.Value.Inspect() }
 func (ro *ReturnObject) Type() ObjectType { return RETURN_OBJ }
 
 type Error struct {
+	Code    string // see diagnostic.Explain
 	Message string
+	Span    lexer.Span // set by Eval to the node that produced the error
 }
 
 func (e *Error) Inspect() string  { return "Error: " + e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
-func newError(format string, args ...any) *Error {
-	return &Error{Message: fmt.Sprintf(format, args...)}
+func newError(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
 }
 
 func isError(obj Object) bool {
@@ -92,6 +98,8 @@ type Function struct {
 	Parameters []*ast.FunctionParameter
 	Body       *ast.BlockStatement
 	ReturnType ast.Type
+	Slots      int             // size of the frame a call needs
+	Table      *resolver.Table // the addresses of the program it is part of
 	Env        *EvaluatorEnvironment
 }
 
@@ -130,3 +138,23 @@ func (f *Function) Inspect() string {
 
 	return out.String()
 }
+
+// NativeFunction is a function implemented in Go and callable from programs.
+type NativeFunction struct {
+	Name  string
+	Arity int // When -1, arity is variadic
+	Fn    func(args ...Object) Object
+}
+
+func (nf *NativeFunction) Type() ObjectType { return NATIVE_FUNCTION_OBJ }
+func (nf *NativeFunction) Inspect() string {
+	var out strings.Builder
+	out.WriteString("<fun '")
+	out.WriteString(nf.Name)
+	out.WriteString(fmt.Sprintf("' %d param", nf.Arity))
+	if nf.Arity != 1 {
+		out.WriteString("s")
+	}
+	out.WriteString(">")
+	return out.String()
+}
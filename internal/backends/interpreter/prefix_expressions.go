@@ -1,5 +1,7 @@
 package interpreter
 
+import "sigil/internal/diagnostic"
+
 // Prefix Expressions
 func evalPrefixExpression(operator string, right Object) Object {
 	switch operator {
@@ -8,7 +10,7 @@ func evalPrefixExpression(operator string, right Object) Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(diagnostic.CodeRuntimeOperand, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -25,7 +27,7 @@ func evalBangOperatorExpression(right Object) Object {
 
 func evalMinusPrefixOperatorExpression(right Object) Object {
 	if right.Type() != NUMBER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError(diagnostic.CodeRuntimeOperand, "unknown operator: -%s", right.Type())
 	}
 
 	value := right.(*Number).Value
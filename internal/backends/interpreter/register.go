@@ -0,0 +1,8 @@
+package interpreter
+
+import "sigil/internal/backends"
+
+func init() {
+	backends.Register("evaluator", NewEvaluator)
+	backends.Register("interpreter", New)
+}
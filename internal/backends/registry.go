@@ -0,0 +1,30 @@
+package backends
+
+import "sort"
+
+// Factory creates a backend that writes program output to the streams in cfg.
+type Factory func(cfg *IOConfig) CompilerBackend
+
+var registry = map[string]Factory{}
+
+// Register makes a backend available under name. Backends register
+// themselves from an init function.
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// Lookup returns the factory registered under name.
+func Lookup(name string) (Factory, bool) {
+	factory, ok := registry[name]
+	return factory, ok
+}
+
+// Names returns the names of all registered backends, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,175 @@
+// Package callgraph finds which functions of a program call which, as far as
+// can be told without running it.
+package callgraph
+
+import (
+	"fmt"
+	"sigil/internal/ast"
+	"sigil/internal/graphviz"
+	"sigil/internal/typechecker"
+	"strings"
+)
+
+// Function is a node of the call graph: the top level of the program, a
+// function literal or a builtin.
+type Function struct {
+	Name    string               // the let the literal is bound to, if any
+	Literal *ast.FunctionLiteral // nil for the top level and builtins
+	Builtin bool
+	Calls   []*Function // the functions called, each once, in order of first call
+}
+
+// Graph is the call graph of a program.
+type Graph struct {
+	Program   *Function   // the statements outside any function
+	Functions []*Function // the program, its literals in source order, then the builtins called
+}
+
+// Build finds the calls of a type checked program. A call is followed when
+// it names a function bound by a let, a builtin, or calls a literal
+// directly. Calls of parameters and of functions returned by other calls
+// depend on the values at run time and are left out.
+func Build(program *ast.Program, result *typechecker.Result) *Graph {
+	b := &builder{
+		result:   result,
+		bound:    map[*typechecker.Symbol]*Function{},
+		literals: map[*ast.FunctionLiteral]*Function{},
+		builtins: map[string]*Function{},
+	}
+	b.graph.Program = &Function{Name: "<program>"}
+	b.graph.Functions = []*Function{b.graph.Program}
+
+	b.declare(program)
+	b.calls(b.graph.Program, program)
+
+	for _, fn := range b.order {
+		b.graph.Functions = append(b.graph.Functions, b.builtins[fn])
+	}
+	return &b.graph
+}
+
+type builder struct {
+	graph    Graph
+	result   *typechecker.Result
+	bound    map[*typechecker.Symbol]*Function // the literals bound by lets
+	literals map[*ast.FunctionLiteral]*Function
+	builtins map[string]*Function
+	order    []string // the builtins in order of first call
+}
+
+// declare makes a function of every literal under node.
+func (b *builder) declare(node ast.Node) {
+	if let, ok := node.(*ast.LetStatement); ok {
+		if lit, ok := let.Value.(*ast.FunctionLiteral); ok {
+			fn := b.literal(lit)
+			fn.Name = let.Name.Value
+			if sym := b.result.SymbolOf(let.Name); sym != nil {
+				b.bound[sym] = fn
+			}
+		}
+	}
+	if lit, ok := node.(*ast.FunctionLiteral); ok {
+		b.literal(lit)
+	}
+	for _, child := range ast.Children(node) {
+		b.declare(child)
+	}
+}
+
+func (b *builder) literal(lit *ast.FunctionLiteral) *Function {
+	if fn, ok := b.literals[lit]; ok {
+		return fn
+	}
+	start := lit.Span().Start
+	fn := &Function{Name: fmt.Sprintf("fun@%d:%d", start.Line, start.Column), Literal: lit}
+	b.literals[lit] = fn
+	b.graph.Functions = append(b.graph.Functions, fn)
+	return fn
+}
+
+// calls adds the calls made under node to caller. The calls in the body of
+// a literal belong to the literal instead.
+func (b *builder) calls(caller *Function, node ast.Node) {
+	switch n := node.(type) {
+	case *ast.FunctionLiteral:
+		caller = b.literals[n]
+	case *ast.CallExpression:
+		if callee := b.callee(n.Function); callee != nil {
+			caller.call(callee)
+		}
+	}
+	for _, child := range ast.Children(node) {
+		b.calls(caller, child)
+	}
+}
+
+// callee returns the function a call expression calls, or nil if that is
+// only known at run time.
+func (b *builder) callee(expr ast.Expression) *Function {
+	switch e := expr.(type) {
+	case *ast.FunctionLiteral:
+		return b.literals[e]
+	case *ast.Identifier:
+		sym := b.result.SymbolOf(e)
+		if sym == nil {
+			return nil
+		}
+		if fn, ok := b.bound[sym]; ok {
+			return fn
+		}
+		if sym.Line != 0 {
+			return nil // a parameter or a value that holds a function
+		}
+		fn, ok := b.builtins[sym.Name]
+		if !ok {
+			fn = &Function{Name: sym.Name, Builtin: true}
+			b.builtins[sym.Name] = fn
+			b.order = append(b.order, sym.Name)
+		}
+		return fn
+	}
+	return nil
+}
+
+func (fn *Function) call(callee *Function) {
+	for _, c := range fn.Calls {
+		if c == callee {
+			return
+		}
+	}
+	fn.Calls = append(fn.Calls, callee)
+}
+
+// String lists the calls one per line as "caller -> callee".
+func (g *Graph) String() string {
+	var out strings.Builder
+	for _, fn := range g.Functions {
+		for _, callee := range fn.Calls {
+			fmt.Fprintf(&out, "%s -> %s\n", fn.Name, callee.Name)
+		}
+	}
+	return out.String()
+}
+
+// Graphviz draws the graph. Builtins are dashed and the program is a box.
+func (g *Graph) Graphviz() *graphviz.Graph {
+	out := graphviz.New("calls")
+	ids := map[*Function]string{}
+	for i, fn := range g.Functions {
+		ids[fn] = fmt.Sprintf("f%d", i)
+		attrs := graphviz.Attrs{"label": fn.Name}
+		switch {
+		case fn == g.Program:
+			attrs["shape"] = "box"
+		case fn.Builtin:
+			attrs["style"] = "dashed"
+		}
+		out.Node(ids[fn], attrs)
+	}
+	for _, fn := range g.Functions {
+		for _, callee := range fn.Calls {
+			out.Edge(ids[fn], ids[callee], nil)
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package callgraph
+
+import (
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+	"strings"
+	"testing"
+)
+
+func build(t *testing.T, input string) *Graph {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	result := typechecker.New().CheckProgram(program)
+	if len(result.Errors) > 0 {
+		t.Fatalf("type errors: %v", result.Errors)
+	}
+	return Build(program, result)
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			"recursion",
+			"let fib = fun(n: Number): Number { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };\nprintln(string(fib(10)));",
+			"<program> -> println\n<program> -> string\n<program> -> fib\nfib -> fib\n",
+		},
+		{
+			"parameters are not followed",
+			"let apply = fun(f: (Number) -> Number, x: Number): Number { f(x) };\nlet inc = fun(x: Number): Number { x + 1 };\napply(inc, 1);",
+			"<program> -> apply\n",
+		},
+		{
+			"nested literals",
+			"let outer = fun(x: Number): (Number) -> Number {\n  let helper = fun(): Number { x };\n  fun(y: Number): Number { helper() + y }\n};\nouter(1)(2);",
+			"<program> -> outer\nfun@3:3 -> helper\n",
+		},
+		{
+			"literal called directly",
+			"fun(): Number { len(\"a\") }();",
+			"<program> -> fun@1:1\nfun@1:1 -> len\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := build(t, tt.input).String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphviz(t *testing.T) {
+	g := build(t, "let f = fun(n: Number): Number { f(n) };\nprintln(string(f(1)));")
+	want := `digraph "calls" {
+  "f0" [label="<program>", shape="box"];
+  "f1" [label="f"];
+  "f2" [label="println", style="dashed"];
+  "f3" [label="string", style="dashed"];
+  "f0" -> "f2";
+  "f0" -> "f3";
+  "f0" -> "f1";
+  "f1" -> "f1";
+}
+`
+	if got := g.Graphviz().String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if !strings.Contains(g.String(), "f -> f\n") {
+		t.Errorf("expected the recursive call in %q", g.String())
+	}
+}
@@ -0,0 +1,108 @@
+// Package compiler runs the front end every tool shares: it parses a Sigil
+// program and, if it parses, type checks it. The command line, the language
+// server, the conformance runner and the embedding API all compile through
+// here, so that they agree on what a program means and which errors it has.
+package compiler
+
+import (
+	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+)
+
+// Error is an error reported by one of the stages, located in the source.
+type Error interface {
+	error
+	diagnostic.Diagnoser
+}
+
+// Result is what compiling a program produced. Program holds the statements
+// that parsed even when there are parse errors; Checked is nil unless the
+// whole program parsed.
+type Result struct {
+	Program     *ast.Program
+	ParseErrors []*parser.ParseError
+	Checked     *typechecker.Result
+}
+
+// Errors returns the parse errors, or the type errors if the program parsed.
+func (r *Result) Errors() []Error {
+	var errs []Error
+	for _, err := range r.ParseErrors {
+		errs = append(errs, err)
+	}
+	if r.Checked != nil {
+		for _, err := range r.Checked.Errors {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Option configures a compilation.
+type Option func(*config)
+
+type config struct {
+	globals *typechecker.Environment
+	parser  []parser.Option
+	trivia  bool
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithGlobals checks the program against the bindings of env and declares
+// its top-level bindings there, instead of in an environment of its own.
+func WithGlobals(env *typechecker.Environment) Option {
+	return func(c *config) { c.globals = env }
+}
+
+// WithParserOptions passes opts, such as a tracer, on to the parser.
+func WithParserOptions(opts ...parser.Option) Option {
+	return func(c *config) { c.parser = append(c.parser, opts...) }
+}
+
+// WithTrivia has the lexer keep whitespace and comments, for tools that
+// build a concrete syntax tree from the program.
+func WithTrivia() Option {
+	return func(c *config) { c.trivia = true }
+}
+
+// Parse parses source without type checking it.
+func Parse(source string, opts ...Option) *Result {
+	return newConfig(opts).parse(source)
+}
+
+func (c *config) parse(source string) *Result {
+	l := lexer.New(source)
+	if c.trivia {
+		l = lexer.NewWithTrivia(source)
+	}
+	par := parser.New(l, c.parser...)
+	program := par.ParseProgram()
+	return &Result{Program: program, ParseErrors: par.Errors()}
+}
+
+// Compile parses source and type checks it if it parses.
+func Compile(source string, opts ...Option) *Result {
+	c := newConfig(opts)
+
+	result := c.parse(source)
+	if len(result.ParseErrors) > 0 {
+		return result
+	}
+
+	tc := typechecker.New()
+	if c.globals != nil {
+		tc = typechecker.NewWithEnvironment(c.globals)
+	}
+	result.Checked = tc.CheckProgram(result.Program)
+	return result
+}
@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input   string
+		parse   int
+		checked bool
+		errors  string
+	}{
+		{"let x = 1; x + 1", 0, true, ""},
+		{"let = 1; 2", 1, false, "Parse error at line 1, column 5: expected next token to be IDENT, got ASSIGN instead"},
+		{"1 + true", 0, true, "Type error at line 1, column 1: cannot add Number and Boolean"},
+	}
+
+	for _, tt := range tests {
+		result := Compile(tt.input)
+		if len(result.ParseErrors) != tt.parse || (result.Checked != nil) != tt.checked {
+			t.Errorf("input %q: got %d parse errors and checked %v", tt.input, len(result.ParseErrors), result.Checked != nil)
+		}
+		if result.Program == nil {
+			t.Errorf("input %q: no program", tt.input)
+		}
+
+		errs := result.Errors()
+		got := ""
+		if len(errs) > 0 {
+			got = errs[0].Error()
+		}
+		if got != tt.errors {
+			t.Errorf("input %q: got error %q, want %q", tt.input, got, tt.errors)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	result := Parse("1 + true")
+	if result.Checked != nil || len(result.Errors()) != 0 || len(result.Program.Statements) != 1 {
+		t.Errorf("got %+v", result)
+	}
+}
+
+func TestWithGlobals(t *testing.T) {
+	globals := typechecker.NewEnvironment()
+	if errs := Compile("let x = 1;", WithGlobals(globals)).Errors(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if _, ok := globals.Get("x"); !ok {
+		t.Error("x was not declared in the globals")
+	}
+	if errs := Compile("x + 1", WithGlobals(globals)).Errors(); len(errs) != 0 {
+		t.Errorf("x is not visible to a later program: %v", errs)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	var trace strings.Builder
+	result := Parse("// note\n1 + 2", WithTrivia(), WithParserOptions(parser.WithTraceWriter(&trace)))
+	if len(result.Errors()) != 0 || result.Program.String() != "(1 + 2)" {
+		t.Errorf("got %q, %v", result.Program.String(), result.Errors())
+	}
+	if !strings.Contains(trace.String(), "parseStatement") {
+		t.Errorf("the parser was not traced:\n%s", trace.String())
+	}
+}
@@ -0,0 +1,251 @@
+// Package conformance checks Sigil scripts against golden files and the
+// backends against each other. Beside a script name.sgl,
+//
+//	name.out holds what the script prints, then "=> value" if it ends
+//	         with a value
+//	name.err holds the diagnostics reported while compiling or running it
+//
+// A missing golden file stands for no output at all. Where the backends are
+// known to disagree, name.<backend>.out and name.<backend>.err hold what each
+// of them produces instead, so that the divergence is written down and any
+// change to it is noticed. The package's tests run every example on every
+// registered backend; go test -update rewrites the golden files of the
+// examples from what the backends produce.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sigil/internal/backends"
+	"sigil/internal/compiler"
+	"sigil/internal/diagnostic"
+	"sigil/internal/typechecker"
+	"sort"
+	"strings"
+)
+
+// Result is what running a script produced.
+type Result struct {
+	Output string // the contents of the .out file
+	Errors string // the contents of the .err file
+}
+
+// Run compiles source and runs it on a backend made by factory, the way
+// sigil run does. name is the file name the diagnostics show.
+func Run(name, source string, factory backends.Factory) Result {
+	var stdout, stderr strings.Builder
+	printer := diagnostic.NewPrinter(&stderr, diagnostic.NewFile(name, source), false)
+	report := func(err error) {
+		var d diagnostic.Diagnoser
+		if errors.As(err, &d) {
+			printer.Print(d.Diagnostic())
+			return
+		}
+		printer.Print(&diagnostic.Diagnostic{Severity: diagnostic.Error, Message: err.Error()})
+	}
+	done := func() Result { return Result{Output: stdout.String(), Errors: stderr.String()} }
+
+	compiled := compiler.Compile(source)
+	if errs := compiled.Errors(); len(errs) > 0 {
+		for _, err := range errs {
+			report(err)
+		}
+		return done()
+	}
+	program, checked := compiled.Program, compiled.Checked
+
+	cfg := &backends.IOConfig{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr}
+	backend := factory(cfg)
+	result, ok := backend.(backends.ResultBackend)
+	if !ok {
+		if err := backend.Execute(program, false); err != nil {
+			report(err)
+		}
+		return done()
+	}
+
+	value, err := result.ExecuteResult(program)
+	if err != nil {
+		report(err)
+	}
+	// The backends differ in what a statement whose value is discarded
+	// leaves behind, so the value only counts where the type checker, like
+	// the REPL, sees one.
+	if _, void := checked.Type.(*typechecker.VoidType); !void && value != "" {
+		if stdout.Len() > 0 && !strings.HasSuffix(stdout.String(), "\n") {
+			stdout.WriteString("\n")
+		}
+		fmt.Fprintf(&stdout, "=> %s\n", value)
+	}
+	return done()
+}
+
+// Results holds the results of one script by the name of the backend that
+// produced them.
+type Results map[string]Result
+
+// RunAll runs source on each of the named backends.
+func RunAll(name, source string, names []string) (Results, error) {
+	results := Results{}
+	for _, backend := range names {
+		factory, ok := backends.Lookup(backend)
+		if !ok {
+			return nil, fmt.Errorf("unknown backend %q", backend)
+		}
+		results[backend] = Run(name, source, factory)
+	}
+	return results, nil
+}
+
+// Agree returns the result every backend produced, or false if they
+// disagree.
+func (rs Results) Agree() (Result, bool) {
+	var first *Result
+	for _, r := range rs {
+		if first == nil {
+			first = &r
+		} else if r != *first {
+			return Result{}, false
+		}
+	}
+	if first == nil {
+		return Result{}, true
+	}
+	return *first, true
+}
+
+// Divergence describes how the backends disagree: for the output and the
+// errors in turn, what each backend produced when they are not all the same.
+func (rs Results) Divergence() string {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	section := func(what string, field func(Result) string) {
+		same := true
+		for _, name := range names[1:] {
+			same = same && field(rs[name]) == field(rs[names[0]])
+		}
+		if same {
+			return
+		}
+		fmt.Fprintf(&out, "%s differs:\n", what)
+		for _, name := range names {
+			fmt.Fprintf(&out, "--- %s\n%s", name, terminated(field(rs[name])))
+		}
+	}
+	if len(names) > 0 {
+		section("output", func(r Result) string { return r.Output })
+		section("errors", func(r Result) string { return r.Errors })
+	}
+	return out.String()
+}
+
+// Golden returns the result recorded in the golden files of the script at
+// path.
+func Golden(path string) (Result, error) {
+	output, err := readGolden(goldenPath(path, ".out"))
+	if err != nil {
+		return Result{}, err
+	}
+	errs, err := readGolden(goldenPath(path, ".err"))
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Output: output, Errors: errs}, nil
+}
+
+// GoldenFor returns the result backend is expected to produce for the script
+// at path: the one in the backend's own golden files if the script has any,
+// which own reports, and otherwise the one all backends share.
+func GoldenFor(path, backend string) (r Result, own bool, err error) {
+	for _, ext := range []string{".out", ".err"} {
+		if _, err := os.Stat(goldenPath(path, "."+backend+ext)); err == nil {
+			own = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return Result{}, false, err
+		}
+	}
+	if !own {
+		r, err = Golden(path)
+		return r, false, err
+	}
+
+	r.Output, err = readGolden(goldenPath(path, "."+backend+".out"))
+	if err != nil {
+		return Result{}, false, err
+	}
+	r.Errors, err = readGolden(goldenPath(path, "."+backend+".err"))
+	return r, true, err
+}
+
+// Update records r in the golden files of the script at path, removing the
+// files that would be empty.
+func Update(path string, r Result) error {
+	return writeResult(path, "", r)
+}
+
+// UpdateAll records rs in the golden files of the script at path: in the
+// shared ones if the backends agree, and otherwise in each backend's own.
+// The files the other case would use are removed.
+func UpdateAll(path string, rs Results) error {
+	agreed, ok := rs.Agree()
+	shared := Result{}
+	if ok {
+		shared = agreed
+	}
+	if err := Update(path, shared); err != nil {
+		return err
+	}
+	for backend, r := range rs {
+		if ok {
+			r = Result{}
+		}
+		if err := writeResult(path, "."+backend, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeResult(path, backend string, r Result) error {
+	if err := writeGolden(goldenPath(path, backend+".out"), r.Output); err != nil {
+		return err
+	}
+	return writeGolden(goldenPath(path, backend+".err"), r.Errors)
+}
+
+func goldenPath(path, ext string) string {
+	return strings.TrimSuffix(path, ".sgl") + ext
+}
+
+func readGolden(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+func writeGolden(path, contents string) error {
+	if contents == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(contents), 0o644)
+}
+
+// terminated ends s with a newline so that listings stay readable.
+func terminated(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+package conformance
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sigil/internal/backends"
+	_ "sigil/internal/backends/interpreter"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the examples from what the backends produce")
+
+const examples = "../../examples"
+
+// TestExamples runs every example on every backend and compares what they
+// produce with each other and with the example's golden files. The backends
+// may only disagree on examples whose golden files record the divergence.
+// Run with -update to rewrite the golden files.
+func TestExamples(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir(examples, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".sgl" {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, file := range files {
+		name, _ := filepath.Rel(examples, file)
+		name = filepath.ToSlash(name)
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := RunAll(name, string(data), backends.Names())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, agree := results.Agree()
+
+			if *update {
+				if !agree {
+					t.Logf("recording the divergence of the backends:\n%s", results.Divergence())
+				}
+				if err := UpdateAll(file, results); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			wants := Results{}
+			known := false
+			for backend := range results {
+				want, own, err := GoldenFor(file, backend)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wants[backend] = want
+				known = known || own
+			}
+			if !agree {
+				if !known {
+					t.Fatalf("the backends disagree:\n%s", results.Divergence())
+				}
+				t.Logf("known divergence of the backends:\n%s", results.Divergence())
+			}
+
+			for backend, got := range results {
+				want, ext := wants[backend], ""
+				if known {
+					ext = "." + backend
+				}
+				if got.Output != want.Output {
+					t.Errorf("%s: output differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s",
+						backend, goldenPath(name, ext+".out"), got.Output, want.Output)
+				}
+				if got.Errors != want.Errors {
+					t.Errorf("%s: errors differ from %s (run with -update to accept them)\ngot:\n%s\nwant:\n%s",
+						backend, goldenPath(name, ext+".err"), got.Errors, want.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		source string
+		want   Result
+	}{
+		{`println("hi"); 1 + 2`, Result{Output: "hi\n=> 3\n"}},
+		{`print("hi"); "there"`, Result{Output: "hi\n=> \"there\"\n"}},
+		{`println("hi");`, Result{Output: "hi\n"}},
+		{`let f = fun(x: Number): Number { x }; f`, Result{Output: "=> fun(x) {\nx\n}\n"}},
+		{
+			`println("before"); assert_eq(1, 2)`,
+			Result{
+				Output: "before\n",
+				Errors: "error[E0309]: assertion failed: expected 1, got 2\n" +
+					" --> t.sgl:1:20\n" +
+					"  |\n" +
+					"1 | println(\"before\"); assert_eq(1, 2)\n" +
+					"  |                    ^^^^^^^^^^^^^^^\n" +
+					"  |\n\n",
+			},
+		},
+		{
+			`let x: String = 1;`,
+			Result{Errors: "error[E0207]: type mismatch: declared String but got Number\n" +
+				" --> t.sgl:1:17\n" +
+				"  |\n" +
+				"1 | let x: String = 1;\n" +
+				"  |                 ^\n" +
+				"  |        ------ expected because of this annotation\n" +
+				"  |\n\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		results, err := RunAll("t.sgl", tt.source, backends.Names())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, got := range results {
+			if got != tt.want {
+				t.Errorf("%s: %q\ngot:\n%s%s\nwant:\n%s%s", name, tt.source, got.Output, got.Errors, tt.want.Output, tt.want.Errors)
+			}
+		}
+	}
+}
+
+func TestRunAllUnknownBackend(t *testing.T) {
+	if _, err := RunAll("t.sgl", "1", []string{"nope"}); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	results := Results{
+		"a": {Output: "=> 1\n", Errors: "oops\n"},
+		"b": {Output: "=> 1.0\n", Errors: "oops\n"},
+	}
+	if _, ok := results.Agree(); ok {
+		t.Fatal("expected the results to disagree")
+	}
+	want := "output differs:\n--- a\n=> 1\n--- b\n=> 1.0\n"
+	if got := results.Divergence(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	results["b"] = results["a"]
+	if got, ok := results.Agree(); !ok || got != results["a"] {
+		t.Errorf("expected the results to agree, got %v", got)
+	}
+	if got := results.Divergence(); got != "" {
+		t.Errorf("expected no divergence, got\n%s", got)
+	}
+}
+
+func TestGoldenFiles(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.sgl")
+
+	if got, err := Golden(script); err != nil || got != (Result{}) {
+		t.Fatalf("missing golden files gave %v, %v", got, err)
+	}
+
+	want := Result{Output: "=> 1\n", Errors: "error\n"}
+	if err := Update(script, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Golden(script); err != nil || got != want {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+
+	if err := Update(script, Result{Output: "=> 2\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(goldenPath(script, ".err")); !os.IsNotExist(err) {
+		t.Errorf("expected the empty .err file to be removed, got %v", err)
+	}
+}
+
+func TestBackendGoldenFiles(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.sgl")
+
+	diverging := Results{"a": {Output: "=> +Inf\n"}, "b": {Errors: "error\n"}}
+	if err := UpdateAll(script, diverging); err != nil {
+		t.Fatal(err)
+	}
+	for backend, want := range diverging {
+		if got, own, err := GoldenFor(script, backend); err != nil || !own || got != want {
+			t.Errorf("%s: got %v, %v, %v, want %v of its own", backend, got, own, err, want)
+		}
+	}
+
+	agreeing := Results{"a": {Output: "=> 1\n"}, "b": {Output: "=> 1\n"}}
+	if err := UpdateAll(script, agreeing); err != nil {
+		t.Fatal(err)
+	}
+	for backend, want := range agreeing {
+		if got, own, err := GoldenFor(script, backend); err != nil || own || got != want {
+			t.Errorf("%s: got %v, %v, %v, want the shared %v", backend, got, own, err, want)
+		}
+	}
+	if _, err := os.Stat(goldenPath(script, ".a.out")); !os.IsNotExist(err) {
+		t.Errorf("expected the divergence to be removed, got %v", err)
+	}
+}
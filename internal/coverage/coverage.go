@@ -0,0 +1,193 @@
+// Package coverage records which statements of a program run and which
+// branches of its ifs are taken, and reports it line by line: as text, as an
+// HTML page with the source highlighted, or as an lcov tracefile.
+package coverage
+
+import (
+	"fmt"
+	"sigil/internal/ast"
+	"strings"
+)
+
+// Profile counts how often the statements of one program ran and the
+// branches of its ifs were taken. It implements backends.Coverage.
+type Profile struct {
+	Name   string // the file name reports show
+	Source string
+
+	statements []ast.Statement
+	ifs        []*ast.IfExpression
+	runs       map[ast.Statement]int
+	taken      map[*ast.IfExpression]*[2]int // consequence, alternative
+}
+
+// New returns an empty profile of program, parsed from source. Test
+// declarations are left out, since they never run with the program.
+func New(name, source string, program *ast.Program) *Profile {
+	p := &Profile{
+		Name:   name,
+		Source: source,
+		runs:   map[ast.Statement]int{},
+		taken:  map[*ast.IfExpression]*[2]int{},
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TestStatement:
+			return false
+		case *ast.BlockStatement:
+			// Only the statements in a block run.
+		case ast.Statement:
+			p.statements = append(p.statements, n)
+			p.runs[n] = 0
+		case *ast.IfExpression:
+			p.ifs = append(p.ifs, n)
+			p.taken[n] = &[2]int{}
+		}
+		return true
+	})
+	return p
+}
+
+// Statement records that stmt ran. Statements of other programs are
+// ignored.
+func (p *Profile) Statement(stmt ast.Statement) {
+	if _, ok := p.runs[stmt]; ok {
+		p.runs[stmt]++
+	}
+}
+
+// Branch records the branch expr took. An if without an else still has two
+// branches: the second one is taken when there is nothing to run.
+func (p *Profile) Branch(expr *ast.IfExpression, consequence bool) {
+	taken, ok := p.taken[expr]
+	if !ok {
+		return
+	}
+	if consequence {
+		taken[0]++
+	} else {
+		taken[1]++
+	}
+}
+
+// Branch is how often an if took each of its branches.
+type Branch struct {
+	If          *ast.IfExpression
+	Consequence int
+	Alternative int
+}
+
+// Line is the coverage of one line of source.
+type Line struct {
+	Number int
+	Text   string
+	// Statements counts the statements that start on the line, Missed
+	// those of them that never ran and Runs the times the one run most ran.
+	Statements int
+	Missed     int
+	Runs       int
+	// Branches holds the ifs that start on the line.
+	Branches []Branch
+}
+
+// Status tells how much of the line ran: "missed", "partial" or "covered",
+// or "" if there is nothing on it that runs.
+func (l Line) Status() string {
+	parts, missed := l.Statements, l.Missed
+	for _, b := range l.Branches {
+		parts += 2
+		if b.Consequence == 0 {
+			missed++
+		}
+		if b.Alternative == 0 {
+			missed++
+		}
+	}
+	switch {
+	case parts == 0:
+		return ""
+	case missed == parts:
+		return "missed"
+	case missed > 0:
+		return "partial"
+	default:
+		return "covered"
+	}
+}
+
+// Lines returns the coverage of every line of the source.
+func (p *Profile) Lines() []Line {
+	texts := strings.Split(strings.TrimSuffix(p.Source, "\n"), "\n")
+	lines := make([]Line, len(texts))
+	for i, text := range texts {
+		lines[i] = Line{Number: i + 1, Text: strings.TrimSuffix(text, "\r")}
+	}
+	at := func(n ast.Node) *Line {
+		i := n.Span().Start.Line - 1
+		if i < 0 || i >= len(lines) {
+			return nil
+		}
+		return &lines[i]
+	}
+
+	for _, stmt := range p.statements {
+		line := at(stmt)
+		if line == nil {
+			continue
+		}
+		runs := p.runs[stmt]
+		line.Statements++
+		if runs == 0 {
+			line.Missed++
+		}
+		line.Runs = max(line.Runs, runs)
+	}
+	for _, expr := range p.ifs {
+		if line := at(expr); line != nil {
+			taken := p.taken[expr]
+			line.Branches = append(line.Branches, Branch{If: expr, Consequence: taken[0], Alternative: taken[1]})
+		}
+	}
+	return lines
+}
+
+// Summary totals the statements and branches of a profile.
+type Summary struct {
+	Statements, StatementsRun int
+	Branches, BranchesTaken   int
+}
+
+// Summary totals the statements that ran and the branches that were taken.
+func (p *Profile) Summary() Summary {
+	var s Summary
+	for _, stmt := range p.statements {
+		s.Statements++
+		if p.runs[stmt] > 0 {
+			s.StatementsRun++
+		}
+	}
+	for _, expr := range p.ifs {
+		s.Branches += 2
+		for _, n := range p.taken[expr] {
+			if n > 0 {
+				s.BranchesTaken++
+			}
+		}
+	}
+	return s
+}
+
+// String returns the summary as in "5 of 6 statements (83.3%), 3 of 4
+// branches (75.0%)".
+func (s Summary) String() string {
+	return fmt.Sprintf("%s, %s",
+		ratio(s.StatementsRun, s.Statements, "statements"),
+		ratio(s.BranchesTaken, s.Branches, "branches"))
+}
+
+func ratio(part, total int, what string) string {
+	if total == 0 {
+		return "no " + what
+	}
+	return fmt.Sprintf("%d of %d %s (%.1f%%)", part, total, what, 100*float64(part)/float64(total))
+}
@@ -0,0 +1,190 @@
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	"sigil/internal/backends/interpreter"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"strings"
+	"testing"
+)
+
+const source = `let n = 1;
+if (n > 0) {
+    println("positive");
+} else {
+    println("negative");
+}
+`
+
+// run runs input on the evaluator and returns its profile.
+func run(t *testing.T, input string) *Profile {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	profile := New("script.sgl", input, program)
+	backend := interpreter.NewEvaluator(&backends.IOConfig{Stdout: io.Discard}).(backends.CoverageBackend)
+	backend.Cover(profile)
+	backend.Execute(program, false)
+	return profile
+}
+
+func TestProfile(t *testing.T) {
+	profile := run(t, `let sign = fun(n: Number): String {
+    if (n > 0) {
+        return "positive";
+    }
+    if (n < 0) { "negative" } else { "zero" }
+}
+
+sign(3);
+sign(0);
+let unused = fun(): Number { 1 };
+test "ignored" { sign(-1); }
+`)
+
+	want := Summary{Statements: 10, StatementsRun: 8, Branches: 4, BranchesTaken: 3}
+	if got := profile.Summary(); got != want {
+		t.Errorf("got summary %+v, want %+v", got, want)
+	}
+
+	type line struct {
+		statements, missed, runs int
+		branches                 string
+		status                   string
+	}
+	wants := map[int]line{
+		1:  {1, 0, 1, "", "covered"},
+		2:  {1, 0, 2, "1/1", "covered"},
+		3:  {1, 0, 1, "", "covered"},
+		4:  {0, 0, 0, "", ""},
+		5:  {3, 1, 1, "0/1", "partial"},
+		8:  {1, 0, 1, "", "covered"},
+		10: {2, 1, 1, "", "partial"},
+		11: {0, 0, 0, "", ""},
+	}
+	lines := profile.Lines()
+	if len(lines) != 11 {
+		t.Fatalf("got %d lines, want 11", len(lines))
+	}
+	for number, want := range wants {
+		l := lines[number-1]
+		var branches []string
+		for _, b := range l.Branches {
+			branches = append(branches, fmt.Sprintf("%d/%d", b.Consequence, b.Alternative))
+		}
+		got := line{l.Statements, l.Missed, l.Runs, strings.Join(branches, " "), l.Status()}
+		if got != want {
+			t.Errorf("line %d: got %+v, want %+v", number, got, want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		line Line
+		want string
+	}{
+		{Line{}, ""},
+		{Line{Statements: 2, Missed: 2}, "missed"},
+		{Line{Statements: 2, Missed: 1, Runs: 3}, "partial"},
+		{Line{Statements: 1, Runs: 1}, "covered"},
+		{Line{Statements: 1, Runs: 1, Branches: []Branch{{Consequence: 1}}}, "partial"},
+		{Line{Branches: []Branch{{Consequence: 1, Alternative: 2}}}, "covered"},
+		{Line{Branches: []Branch{{}}}, "missed"},
+	}
+	for _, tt := range tests {
+		if got := tt.line.Status(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestOtherProgramsAreIgnored(t *testing.T) {
+	profile := New("script.sgl", "1", &ast.Program{})
+	profile.Statement(&ast.ExpressionStatement{})
+	profile.Branch(&ast.IfExpression{}, true)
+	if got := profile.Summary(); got != (Summary{}) {
+		t.Errorf("got summary %+v", got)
+	}
+	if got := profile.Summary().String(); got != "no statements, no branches" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out strings.Builder
+	if err := run(t, source).WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `script.sgl: 3 of 4 statements (75.0%), 1 of 2 branches (50.0%)
+
+1  1  | let n = 1;
+2  1~ | if (n > 0) {  [then 1, else 0]
+3  1  |     println("positive");
+4     | } else {
+5  0! |     println("negative");
+6     | }
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var out strings.Builder
+	if err := run(t, source+"let f = fun(x: Boolean): Number { if (x) { 1 } else { 2 } };\n").WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `TN:
+SF:script.sgl
+BRDA:2,0,0,1
+BRDA:2,0,1,0
+BRDA:7,1,0,-
+BRDA:7,1,1,-
+BRF:4
+BRH:1
+DA:1,1
+DA:2,1
+DA:3,1
+DA:5,0
+DA:7,1
+LF:5
+LH:4
+end_of_record
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out strings.Builder
+	if err := run(t, source).WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	html := out.String()
+	for _, want := range []string{
+		"<title>Coverage of script.sgl</title>",
+		"<p>3 of 4 statements (75.0%), 1 of 2 branches (50.0%)</p>",
+		`<span class="line covered"><span class="number">1</span><span class="runs">1</span>let n = 1;</span>`,
+		`<span class="line partial" title="then 1, else 0"><span class="number">2</span><span class="runs">1</span>if (n &gt; 0) {</span>`,
+		`<span class="line missed"><span class="number">5</span><span class="runs">0</span>    println(&#34;negative&#34;);</span>`,
+		`<span class="line"><span class="number">6</span><span class="runs"></span>}</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("the page does not contain %s\n%s", want, html)
+		}
+	}
+}
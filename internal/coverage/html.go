@@ -0,0 +1,71 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage of {{.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { font-family: monospace; line-height: 1.4; }
+.line { display: block; }
+.covered { background: #d4f4d4; }
+.partial { background: #f9f0c0; }
+.missed { background: #f8d0d0; }
+.number, .runs { display: inline-block; color: #888; text-align: right; user-select: none; }
+.number { width: {{.Width}}ch; }
+.runs { width: {{.RunsWidth}}ch; margin: 0 1em; }
+.legend span { padding: 0 0.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Summary}}</p>
+<p class="legend"><span class="covered">covered</span> <span class="partial">partly covered</span> <span class="missed">not covered</span></p>
+<pre>{{range .Lines}}<span class="line{{with .Status}} {{.}}{{end}}"{{with .Title}} title="{{.}}"{{end}}><span class="number">{{.Number}}</span><span class="runs">{{.Runs}}</span>{{.Text}}</span>{{end}}</pre>
+</body>
+</html>
+`))
+
+type htmlLine struct {
+	Number int
+	Runs   string
+	Text   string
+	Status string
+	Title  string
+}
+
+// WriteHTML writes the profile as an HTML page that shows the source with
+// each line colored by how much of it ran, and the times it ran beside it.
+// Hovering over a line with an if shows the branches it took.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	lines := p.Lines()
+	data := struct {
+		Name             string
+		Summary          Summary
+		Width, RunsWidth int
+		Lines            []htmlLine
+	}{Name: p.Name, Summary: p.Summary(), Width: len(fmt.Sprint(len(lines))), RunsWidth: 1}
+
+	for _, line := range lines {
+		l := htmlLine{Number: line.Number, Text: line.Text, Status: line.Status()}
+		if line.Statements > 0 {
+			l.Runs = fmt.Sprint(line.Runs)
+			data.RunsWidth = max(data.RunsWidth, len(l.Runs))
+		}
+		var taken []string
+		for _, b := range line.Branches {
+			taken = append(taken, fmt.Sprintf("then %d, else %d", b.Consequence, b.Alternative))
+		}
+		l.Title = strings.Join(taken, "; ")
+		data.Lines = append(data.Lines, l)
+	}
+	return page.Execute(w, data)
+}
@@ -0,0 +1,50 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteLCOV writes the profile as an lcov tracefile with one record for the
+// source file. Each if is a block of two branches, its consequence and its
+// alternative; lines count the runs of the statement run most that starts
+// on them.
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "TN:\nSF:%s\n", p.Name)
+
+	block, found, hit := 0, 0, 0
+	lines := p.Lines()
+	for _, line := range lines {
+		for _, b := range line.Branches {
+			for i, n := range []int{b.Consequence, b.Alternative} {
+				taken := "-" // the if never ran
+				if b.Consequence+b.Alternative > 0 {
+					taken = fmt.Sprint(n)
+				}
+				fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", line.Number, block, i, taken)
+				found++
+				if n > 0 {
+					hit++
+				}
+			}
+			block++
+		}
+	}
+	fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", found, hit)
+
+	found, hit = 0, 0
+	for _, line := range lines {
+		if line.Statements == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "DA:%d,%d\n", line.Number, line.Runs)
+		found++
+		if line.Runs > 0 {
+			hit++
+		}
+	}
+	fmt.Fprintf(out, "LF:%d\nLH:%d\nend_of_record\n", found, hit)
+	return out.Flush()
+}
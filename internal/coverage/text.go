@@ -0,0 +1,57 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes the profile as a summary followed by the source, each
+// line preceded by the times its statements ran and followed by the
+// branches its ifs took. Lines where something never ran are marked with a
+// "!", or a "~" if other parts of them did run:
+//
+//	script.sgl: 3 of 4 statements (75.0%), 1 of 2 branches (50.0%)
+//
+//	1  1  | let n = 1;
+//	2  1~ | if (n > 0) {  [then 1, else 0]
+//	3  1  |     println("positive");
+//	4     | } else {
+//	5  0! |     println("negative");
+//	6     | }
+func (p *Profile) WriteText(w io.Writer) error {
+	lines := p.Lines()
+	width := len(strconv.Itoa(len(lines)))
+	runsWidth := 1
+	for _, line := range lines {
+		runsWidth = max(runsWidth, len(strconv.Itoa(line.Runs)))
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%s: %s\n\n", p.Name, p.Summary())
+	for _, line := range lines {
+		runs := ""
+		if line.Statements > 0 {
+			runs = strconv.Itoa(line.Runs)
+		}
+		mark := " "
+		switch line.Status() {
+		case "missed":
+			mark = "!"
+		case "partial":
+			mark = "~"
+		}
+		text := fmt.Sprintf("%*d  %*s%s | %s", width, line.Number, runsWidth, runs, mark, line.Text)
+		if len(line.Branches) > 0 {
+			var taken []string
+			for _, b := range line.Branches {
+				taken = append(taken, fmt.Sprintf("then %d, else %d", b.Consequence, b.Alternative))
+			}
+			text += "  [" + strings.Join(taken, "; ") + "]"
+		}
+		fmt.Fprintln(out, strings.TrimRight(text, " "))
+	}
+	return out.Flush()
+}
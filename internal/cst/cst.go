@@ -0,0 +1,134 @@
+// Package cst builds concrete syntax trees. Unlike the abstract syntax tree,
+// a concrete syntax tree keeps every byte of the source: the punctuation,
+// whitespace and comments the parser passes over are tokens of the tree, so
+// printing it gives back the source exactly.
+package cst
+
+import (
+	"reflect"
+	"sigil/internal/ast"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"strings"
+)
+
+// Node is a node of a concrete syntax tree. It is either a token, a leaf
+// holding the text it was read from, or a syntax node: an AST node and the
+// nodes and tokens that make it up, in source order.
+type Node struct {
+	Kind     string      // the type of the AST node, or of the token
+	Span     lexer.Span  // the source the node covers
+	Text     string      // the source text of a token
+	Token    lexer.Token // the token, for a token
+	AST      ast.Node    // the AST node, for a syntax node
+	Children []*Node
+}
+
+// IsToken reports whether the node is a token.
+func (n *Node) IsToken() bool {
+	return n.AST == nil
+}
+
+// String returns the source text the node covers. For the root of a tree
+// that is the whole source.
+func (n *Node) String() string {
+	var out strings.Builder
+	n.write(&out)
+	return out.String()
+}
+
+func (n *Node) write(out *strings.Builder) {
+	if n.IsToken() {
+		out.WriteString(n.Text)
+		return
+	}
+	for _, child := range n.Children {
+		child.write(out)
+	}
+}
+
+// TreeString returns the tree indented one level per depth, with the text of
+// each token quoted.
+func (n *Node) TreeString() string {
+	var out strings.Builder
+	n.tree(&out, "")
+	return out.String()
+}
+
+func (n *Node) tree(out *strings.Builder, indent string) {
+	out.WriteString(indent + n.Kind)
+	if n.IsToken() {
+		out.WriteString(" " + quote(n.Text))
+	}
+	out.WriteString("\n")
+	for _, child := range n.Children {
+		child.tree(out, indent+"  ")
+	}
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s) + `"`
+}
+
+// Parse parses src into a concrete syntax tree. The tree holds all of src
+// even when it does not parse: tokens that belong to no syntax node, such as
+// those of a statement dropped for a parse error, are children of the
+// closest syntax node around them.
+func Parse(src string) (*Node, []*parser.ParseError) {
+	par := parser.New(lexer.NewWithTrivia(src))
+	program := par.ParseProgram()
+	return Build(program, src), par.Errors()
+}
+
+// Build builds the concrete syntax tree of a program parsed from src.
+func Build(program *ast.Program, src string) *Node {
+	b := &builder{src: src}
+	l := lexer.NewWithTrivia(src)
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		b.tokens = append(b.tokens, tok)
+	}
+
+	root := b.node(program, len(src))
+	root.Span = lexer.Span{
+		Start: lexer.Position{Offset: 0, Line: 1, Column: 1},
+		End:   l.NextToken().Span.End,
+	}
+	return root
+}
+
+type builder struct {
+	src    string
+	tokens []lexer.Token
+	next   int // the first token not in the tree yet
+}
+
+// node builds the syntax node of n from the tokens that end by end.
+func (b *builder) node(n ast.Node, end int) *Node {
+	out := &Node{Kind: kind(n), Span: n.Span(), AST: n}
+	for _, child := range ast.Children(n) {
+		span := child.Span()
+		b.tokensBefore(out, span.Start.Offset)
+		out.Children = append(out.Children, b.node(child, span.End.Offset))
+	}
+	b.tokensBefore(out, end)
+	return out
+}
+
+// tokensBefore adds the tokens that end by offset to n.
+func (b *builder) tokensBefore(n *Node, offset int) {
+	for b.next < len(b.tokens) && b.tokens[b.next].Span.End.Offset <= offset {
+		tok := b.tokens[b.next]
+		n.Children = append(n.Children, &Node{
+			Kind:  string(tok.Type),
+			Span:  tok.Span,
+			Text:  b.src[tok.Span.Start.Offset:tok.Span.End.Offset],
+			Token: tok,
+		})
+		b.next++
+	}
+}
+
+// kind names an AST node by its type, such as "LetStatement".
+func kind(n ast.Node) string {
+	return reflect.TypeOf(n).Elem().Name()
+}
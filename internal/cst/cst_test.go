@@ -0,0 +1,105 @@
+package cst
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLossless(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t",
+		"// only a comment",
+		"let x = 1; // one\n\n/// doc\nlet y = (x + 2) * 3;\r\n",
+		"let f = fun(a: Number, b: (Number) -> Bool): Number {\n    if (b(a)) { a } else { -a }\n};\nf(1, fun(n: Number): Bool { n > 0 })",
+		"let = 1;\nlet ok = 2;",
+		"let s = \"unterminated",
+		"let café = 1.5.; @ #",
+		"if (x) {",
+	}
+
+	for _, input := range inputs {
+		tree, _ := Parse(input)
+		if got := tree.String(); got != input {
+			t.Errorf("Parse(%q).String() = %q", input, got)
+		}
+		checkSpans(t, input, tree)
+	}
+}
+
+func TestLosslessExamples(t *testing.T) {
+	err := filepath.WalkDir("../../examples", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".sgl" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, _ := Parse(string(data))
+		if tree.String() != string(data) {
+			t.Errorf("%s: the tree does not reproduce the source", path)
+		}
+		checkSpans(t, string(data), tree)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkSpans checks that every token of the tree covers the text it holds.
+func checkSpans(t *testing.T, src string, n *Node) {
+	t.Helper()
+	if n.IsToken() {
+		if got := src[n.Span.Start.Offset:n.Span.End.Offset]; got != n.Text {
+			t.Errorf("%s token %q has the span of %q", n.Kind, n.Text, got)
+		}
+		return
+	}
+	for _, child := range n.Children {
+		checkSpans(t, src, child)
+	}
+}
+
+func TestTree(t *testing.T) {
+	tree, errs := Parse("/// doc\nlet x: Number = -1; // trailing\n")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	want := `Program
+  COMMENT "/// doc"
+  WHITESPACE "\n"
+  LetStatement
+    LET "let"
+    WHITESPACE " "
+    Identifier
+      IDENT "x"
+    COLON ":"
+    WHITESPACE " "
+    SimpleType
+      IDENT "Number"
+    WHITESPACE " "
+    ASSIGN "="
+    WHITESPACE " "
+    PrefixExpression
+      MINUS "-"
+      NumberLiteral
+        NUMBER "1"
+    SEMICOLON ";"
+  WHITESPACE " "
+  COMMENT "// trailing"
+  WHITESPACE "\n"
+`
+	if got := tree.TreeString(); got != want {
+		t.Errorf("got tree\n%s\nwant\n%s", got, want)
+	}
+
+	let := tree.Children[2]
+	if let.AST == nil || let.AST.TokenLiteral() != "let" || let.String() != "let x: Number = -1;" {
+		t.Errorf("got let node %s %q", let.Kind, let.String())
+	}
+}
@@ -0,0 +1,413 @@
+package diagnostic
+
+import (
+	"sort"
+	"strings"
+)
+
+// Codes identify every kind of problem Sigil reports. They never change
+// meaning once released; `sigil explain CODE` prints the explanation
+// registered for each of them below.
+//
+// E00xx come from the lexer, E01xx from the parser, E02xx from the type
+// checker and E03xx from the backends at run time. Wxxxx are warnings from
+// the lint rules of the type checker.
+const (
+	CodeUnexpectedCharacter = "E0001"
+	CodeUnterminatedString  = "E0002"
+
+	CodeUnexpectedToken    = "E0101"
+	CodeExpectedExpression = "E0102"
+	CodeUnclosedBlock      = "E0103"
+	CodeInvalidNumber      = "E0104"
+	CodeInvalidType        = "E0105"
+	CodeInvalidFunction    = "E0106"
+
+	CodeUndefinedVariable     = "E0201"
+	CodeUnknownType           = "E0202"
+	CodeMismatchedOperands    = "E0203"
+	CodeInvalidOperand        = "E0204"
+	CodeNonBooleanCondition   = "E0205"
+	CodeMismatchedBranches    = "E0206"
+	CodeAnnotationMismatch    = "E0207"
+	CodeAssignmentMismatch    = "E0208"
+	CodeNotCallable           = "E0209"
+	CodeArgumentCount         = "E0210"
+	CodeArgumentType          = "E0211"
+	CodeReturnOutsideFunction = "E0212"
+	CodeReturnMismatch        = "E0213"
+	CodeMissingReturn         = "E0214"
+	CodeNestedTest            = "E0215"
+
+	CodeDivisionByZero       = "E0301"
+	CodeUndefinedAtRuntime   = "E0302"
+	CodeRuntimeOperand       = "E0303"
+	CodeRuntimeCondition     = "E0304"
+	CodeRuntimeNotCallable   = "E0305"
+	CodeRuntimeArgumentCount = "E0306"
+	CodeBuiltinArgument      = "E0307"
+	CodeHostFunction         = "E0308"
+	CodeAssertionFailed      = "E0309"
+
+	// CodeInternal marks a bug in Sigil itself rather than in the program.
+	CodeInternal = "E0900"
+
+	CodeUnusedVariable     = "W0001"
+	CodeShadowing          = "W0002"
+	CodeUnreachableCode    = "W0003"
+	CodeDiscardedValue     = "W0004"
+	CodeFunctionComparison = "W0005"
+)
+
+// Explanation is the long form description of a code, with a program that
+// reports it and the same program fixed. Internal errors have no examples.
+type Explanation struct {
+	Code    string
+	Title   string
+	Text    string
+	Failing string
+	Fixed   string
+}
+
+// Explain returns the explanation of code, which may be given in lower case.
+func Explain(code string) (*Explanation, bool) {
+	e, ok := explanations[strings.ToUpper(code)]
+	return e, ok
+}
+
+// Codes returns every registered code in order.
+func Codes() []string {
+	codes := make([]string, 0, len(explanations))
+	for code := range explanations {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+var explanations = map[string]*Explanation{}
+
+func init() {
+	for _, e := range []*Explanation{
+		{
+			Code:  CodeUnexpectedCharacter,
+			Title: "unexpected character",
+			Text: `The source contains a character that is not part of any Sigil token.
+Sigil programs are made of names, numbers, strings, keywords and the
+operators + - * / ! = == != < <= > >= -> => along with ( ) { } , ; :.`,
+			Failing: "let total = 1 @ 2;",
+			Fixed:   "let total = 1 + 2;",
+		},
+		{
+			Code:  CodeUnterminatedString,
+			Title: "unterminated string literal",
+			Text: `A string literal was opened with a double quote but the file ended
+before the closing quote. Strings may span lines, so the missing quote
+is often far above the place the file ends.`,
+			Failing: `let greeting = "hello;`,
+			Fixed:   `let greeting = "hello";`,
+		},
+		{
+			Code:  CodeUnexpectedToken,
+			Title: "unexpected token",
+			Text: `The parser needed a particular token, such as a name after let or a
+closing parenthesis, and found something else.`,
+			Failing: "let = 5;",
+			Fixed:   "let five = 5;",
+		},
+		{
+			Code:  CodeExpectedExpression,
+			Title: "expected an expression",
+			Text: `An expression was expected but the next token cannot start one, for
+example a let statement without a value or an operator with nothing on
+its left.`,
+			Failing: "let x = ;",
+			Fixed:   "let x = 0;",
+		},
+		{
+			Code:  CodeUnclosedBlock,
+			Title: "unclosed block",
+			Text: `A block opened with { reached the end of the file before its closing }.
+The error points at the end of the file and notes where the block was
+opened.`,
+			Failing: "let one = fun(): Number {\n    1",
+			Fixed:   "let one = fun(): Number {\n    1\n}",
+		},
+		{
+			Code:  CodeInvalidNumber,
+			Title: "invalid number literal",
+			Text: `A number literal is too large to be represented. Numbers are 64-bit
+floating point values, so literals must stay below about 1.8e308.`,
+			Failing: "let huge = 1" + strings.Repeat("0", 309) + ";",
+			Fixed:   "let huge = 1" + strings.Repeat("0", 300) + ";",
+		},
+		{
+			Code:  CodeInvalidType,
+			Title: "malformed type annotation",
+			Text: `A type annotation is not a type name such as Number or a function type
+such as (Number, String) -> Boolean.`,
+			Failing: "let twice = fun(f: (Number) Number, x: Number): Number { f(f(x)) };",
+			Fixed:   "let twice = fun(f: (Number) -> Number, x: Number): Number { f(f(x)) };",
+		},
+		{
+			Code:  CodeInvalidFunction,
+			Title: "malformed function literal",
+			Text: `A function literal must list its parameters with their types, then a
+colon and the return type, then a body in braces:
+
+    fun(a: Number, b: Number): Number { a + b }`,
+			Failing: "let add = fun(a: Number, b: Number) Number { a + b };",
+			Fixed:   "let add = fun(a: Number, b: Number): Number { a + b };",
+		},
+		{
+			Code:  CodeUndefinedVariable,
+			Title: "undefined variable",
+			Text: `A name is used or assigned to without being declared with let in this
+scope or an enclosing one, and it is not a builtin. When a visible name
+is spelled similarly, the error suggests it in a note.`,
+			Failing: "let total = 1;\ntotal + count",
+			Fixed:   "let total = 1;\nlet count = 2;\ntotal + count",
+		},
+		{
+			Code:  CodeUnknownType,
+			Title: "unknown type",
+			Text: `A type annotation names a type that does not exist. The types are
+Number, String, Boolean and Void, and type names start with a capital.`,
+			Failing: "let ready: Bool = true;",
+			Fixed:   "let ready: Boolean = true;",
+		},
+		{
+			Code:  CodeMismatchedOperands,
+			Title: "mismatched operand types",
+			Text: `+ adds two numbers or joins two strings, and == and != compare two values
+of the same type. Other combinations are rejected; convert one side
+first, for example with string().`,
+			Failing: `"total: " + 3`,
+			Fixed:   `"total: " + string(3)`,
+		},
+		{
+			Code:  CodeInvalidOperand,
+			Title: "invalid operand type",
+			Text: `The arithmetic operators - * / and the comparisons < <= > >= only work
+on numbers, unary - needs a number and ! needs a Boolean.`,
+			Failing: `let half = "10" / 2;`,
+			Fixed:   `let half = 10 / 2;`,
+		},
+		{
+			Code:    CodeNonBooleanCondition,
+			Title:   "condition is not a Boolean",
+			Text:    `The condition of an if expression must be a Boolean. Sigil does not treat numbers or strings as true or false.`,
+			Failing: "let count = 3;\nif (count) { \"some\" } else { \"none\" }",
+			Fixed:   "let count = 3;\nif (count > 0) { \"some\" } else { \"none\" }",
+		},
+		{
+			Code:    CodeMismatchedBranches,
+			Title:   "if branches have different types",
+			Text:    `Both branches of an if expression with an else must produce the same type, since either may become its value.`,
+			Failing: "let label = if (true) { \"yes\" } else { 0 };",
+			Fixed:   "let label = if (true) { \"yes\" } else { \"no\" };",
+		},
+		{
+			Code:    CodeAnnotationMismatch,
+			Title:   "value does not match its annotation",
+			Text:    `A let statement declares a type, and the value it binds has another.`,
+			Failing: `let age: Number = "42";`,
+			Fixed:   `let age: Number = 42;`,
+		},
+		{
+			Code:  CodeAssignmentMismatch,
+			Title: "assignment changes the type of a variable",
+			Text: `A variable keeps the type it was declared with, so every value assigned
+to it later must have that type too.`,
+			Failing: "let count = 1;\ncount = \"two\";",
+			Fixed:   "let count = 1;\ncount = 2;",
+		},
+		{
+			Code:    CodeNotCallable,
+			Title:   "call of a value that is not a function",
+			Text:    `Only functions can be called.`,
+			Failing: "let width = 3;\nwidth(2)",
+			Fixed:   "let width = fun(n: Number): Number { n * 3 };\nwidth(2)",
+		},
+		{
+			Code:    CodeArgumentCount,
+			Title:   "wrong number of arguments",
+			Text:    `A function was called with more or fewer arguments than it has parameters.`,
+			Failing: "let add = fun(a: Number, b: Number): Number { a + b };\nadd(1)",
+			Fixed:   "let add = fun(a: Number, b: Number): Number { a + b };\nadd(1, 2)",
+		},
+		{
+			Code:    CodeArgumentType,
+			Title:   "argument type mismatch",
+			Text:    `An argument does not have the type of the parameter it is passed to.`,
+			Failing: "let double = fun(n: Number): Number { n * 2 };\ndouble(\"4\")",
+			Fixed:   "let double = fun(n: Number): Number { n * 2 };\ndouble(4)",
+		},
+		{
+			Code:  CodeReturnOutsideFunction,
+			Title: "return outside of a function",
+			Text: `return leaves the enclosing function, or ends a test early, so it
+cannot appear at the top level of a program. The last expression of a
+program is its value.`,
+			Failing: "return 1;",
+			Fixed:   "1",
+		},
+		{
+			Code:  CodeReturnMismatch,
+			Title: "return type mismatch",
+			Text: `A function returns a value of a different type than it declares, either
+from a return statement or from the last expression of its body.`,
+			Failing: "let name = fun(): String { 42 };",
+			Fixed:   "let name = fun(): String { \"42\" };",
+		},
+		{
+			Code:  CodeMissingReturn,
+			Title: "missing return on some paths",
+			Text: `A function with a return type other than Void can reach the end of its
+body without returning or producing a value, for example through an if
+without an else, or because a semicolon discards the last value. Every
+path must end in a return or in an expression of the declared type.`,
+			Failing: "let check_positive = fun(n: Number): Number {\n    if (n < 0) {\n        return 0;\n    }\n};",
+			Fixed:   "let check_positive = fun(n: Number): Number {\n    if (n < 0) {\n        return 0;\n    }\n    return n;\n};",
+		},
+		{
+			Code:  CodeNestedTest,
+			Title: "test declared inside a block",
+			Text: `A test declaration sits inside a function or another block. Tests are
+declared at the top level of a file, where sigil test finds them; each
+runs on its own against the top-level bindings of its file.`,
+			Failing: "if (true) {\n    test \"inside\" { assert(true); }\n}",
+			Fixed:   "test \"outside\" { assert(true); }",
+		},
+		{
+			Code:    CodeDivisionByZero,
+			Title:   "division by zero",
+			Text:    `A number was divided by zero while the program ran.`,
+			Failing: "let share = fun(total: Number, people: Number): Number { total / people };\nshare(10, 0)",
+			Fixed: "let share = fun(total: Number, people: Number): Number {\n" +
+				"    if (people == 0) { 0 } else { total / people }\n};\nshare(10, 0)",
+		},
+		{
+			Code:  CodeUndefinedAtRuntime,
+			Title: "undefined variable at run time",
+			Text: `A name was looked up while the program ran and nothing was bound to it.
+The type checker reports E0201 for such programs first, so this is
+only seen when code is run without being checked.`,
+			Failing: "println(greeting);",
+			Fixed:   "let greeting = \"hello\";\nprintln(greeting);",
+		},
+		{
+			Code:  CodeRuntimeOperand,
+			Title: "invalid operand at run time",
+			Text: `An operator was applied to values it does not support while the program
+ran. The type checker reports E0203 or E0204 for such programs first.`,
+			Failing: "1 + true",
+			Fixed:   "1 + 1",
+		},
+		{
+			Code:  CodeRuntimeCondition,
+			Title: "condition is not a Boolean at run time",
+			Text: `An if condition evaluated to something other than a Boolean. The type
+checker reports E0205 for such programs first.`,
+			Failing: "if (1) { 2 } else { 3 }",
+			Fixed:   "if (1 > 0) { 2 } else { 3 }",
+		},
+		{
+			Code:  CodeRuntimeNotCallable,
+			Title: "call of a value that is not a function at run time",
+			Text: `A value that is not a function was called while the program ran. The
+type checker reports E0209 for such programs first.`,
+			Failing: "let width = 3;\nwidth(2)",
+			Fixed:   "let width = fun(n: Number): Number { n * 3 };\nwidth(2)",
+		},
+		{
+			Code:  CodeRuntimeArgumentCount,
+			Title: "wrong number of arguments at run time",
+			Text: `A function was called with the wrong number of arguments while the
+program ran. The type checker reports E0210 for such programs first.`,
+			Failing: "let id = fun(n: Number): Number { n };\nid(1, 2)",
+			Fixed:   "let id = fun(n: Number): Number { n };\nid(1)",
+		},
+		{
+			Code:  CodeBuiltinArgument,
+			Title: "invalid argument to a builtin",
+			Text: `A builtin such as len or println was given a value it cannot handle:
+len measures strings and print and println only write strings. Convert
+other values with string() first.`,
+			Failing: "println(42);",
+			Fixed:   "println(string(42));",
+		},
+		{
+			Code:  CodeHostFunction,
+			Title: "host function failed",
+			Text: `A function provided by the program embedding Sigil returned an error,
+or was called with a value it cannot accept. The message starts with
+the function's name; its documentation tells what it expects. The
+examples assume the host registered read_file(String) -> String.`,
+			Failing: `read_file("missing.txt")`,
+			Fixed:   `read_file("present.txt")`,
+		},
+		{
+			Code:  CodeAssertionFailed,
+			Title: "assertion failed",
+			Text: `An assert was given false, or an assert_eq was given two different
+values. assert_eq takes the expected value first and the actual one
+second, and the message shows both.`,
+			Failing: "assert_eq(4, 2 + 1)",
+			Fixed:   "assert_eq(3, 2 + 1)",
+		},
+		{
+			Code:  CodeInternal,
+			Title: "internal error",
+			Text: `Sigil reached a state it does not expect, such as a syntax tree node
+that a later stage does not know about. This is a bug in Sigil rather
+than in the program; please report it with the program that caused it.`,
+		},
+		{
+			Code:  CodeUnusedVariable,
+			Title: "unused variable",
+			Text: `A variable or parameter declared inside a function is never read.
+Assigning to it does not count as a use. Remove it, or start its name
+with an underscore to show it is unused on purpose.`,
+			Failing: "let area = fun(width: Number, height: Number): Number {\n    width * width\n};",
+			Fixed:   "let area = fun(width: Number, _height: Number): Number {\n    width * width\n};",
+		},
+		{
+			Code:  CodeShadowing,
+			Title: "shadowed binding",
+			Text: `A let inside a function declares a name that is already bound in an
+enclosing scope, hiding the outer binding for the rest of the function.
+Code such as let x = x + 1 reads the outer x but leaves it unchanged,
+which is rarely what was meant.`,
+			Failing: "let total = 10;\nlet add = fun(n: Number): Number {\n    let total = total + n;\n    total\n};",
+			Fixed:   "let total = 10;\nlet add = fun(n: Number): Number {\n    let sum = total + n;\n    sum\n};",
+		},
+		{
+			Code:    CodeUnreachableCode,
+			Title:   "unreachable code",
+			Text:    `Statements that follow a return in the same block never run.`,
+			Failing: "let echo = fun(n: Number): Number {\n    return n;\n    println(\"done\");\n    n\n};",
+			Fixed:   "let echo = fun(n: Number): Number {\n    println(\"done\");\n    return n;\n};",
+		},
+		{
+			Code:  CodeDiscardedValue,
+			Title: "discarded value",
+			Text: `An expression statement computes a value that nothing uses, such as
+x + 1; on its own line. Only the last expression of a block without a
+semicolon becomes its value; everything else is thrown away.`,
+			Failing: "let next = fun(n: Number): Number {\n    n + 1;\n    n\n};",
+			Fixed:   "let next = fun(n: Number): Number {\n    n + 1\n};",
+		},
+		{
+			Code:  CodeFunctionComparison,
+			Title: "comparison of functions",
+			Text: `Comparing two functions with == or != tells whether they are the same
+function value, not whether they compute the same results. Compare
+their results instead.`,
+			Failing: "let a = fun(n: Number): Number { n };\nlet b = fun(n: Number): Number { n };\nlet same = a == b;",
+			Fixed:   "let a = fun(n: Number): Number { n };\nlet b = fun(n: Number): Number { n };\nlet same = a(1) == b(1);",
+		},
+	} {
+		explanations[e.Code] = e
+	}
+}
@@ -0,0 +1,84 @@
+package diagnostic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// declaredCodes reads the Code constants out of codes.go, so that a code
+// added without an explanation fails the test.
+func declaredCodes(t *testing.T) map[string]string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "codes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := map[string]string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "Code") || i >= len(spec.Values) {
+				continue
+			}
+			if lit, ok := spec.Values[i].(*ast.BasicLit); ok {
+				value, _ := strconv.Unquote(lit.Value)
+				codes[name.Name] = value
+			}
+		}
+		return true
+	})
+	if len(codes) == 0 {
+		t.Fatal("found no Code constants in codes.go")
+	}
+	return codes
+}
+
+func TestEveryCodeIsExplained(t *testing.T) {
+	format := regexp.MustCompile(`^[EW]\d{4}$`)
+	seen := map[string]string{}
+
+	for name, code := range declaredCodes(t) {
+		if !format.MatchString(code) {
+			t.Errorf("%s = %q does not look like E0000 or W0000", name, code)
+		}
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share the code %s", name, other, code)
+		}
+		seen[code] = name
+
+		e, ok := Explain(code)
+		if !ok {
+			t.Errorf("%s (%s) has no explanation", name, code)
+			continue
+		}
+		if e.Title == "" || e.Text == "" {
+			t.Errorf("%s needs a title and a text", code)
+		}
+		if code != CodeInternal && (e.Failing == "" || e.Fixed == "") {
+			t.Errorf("%s needs a failing and a fixed example", code)
+		}
+	}
+
+	if len(Codes()) != len(seen) {
+		t.Errorf("explained %d codes but declared %d", len(Codes()), len(seen))
+	}
+}
+
+func TestExplainIgnoresCase(t *testing.T) {
+	if e, ok := Explain("e0201"); !ok || e.Code != CodeUndefinedVariable {
+		t.Errorf("expected e0201 to find %s, got %+v", CodeUndefinedVariable, e)
+	}
+	if _, ok := Explain("E9999"); ok {
+		t.Error("expected no explanation for an unknown code")
+	}
+}
@@ -0,0 +1,62 @@
+// Package diagnostic describes problems found in a Sigil program and renders
+// them against the source they refer to, in the style of rustc:
+//
+//	error[E0203]: cannot add Number and Boolean
+//	 --> main.sgl:1:9
+//	  |
+//	1 | let x = 1 + true;
+//	  |         ^^^^^^^^
+//	  |
+package diagnostic
+
+import (
+	"sigil/internal/lexer"
+)
+
+// Severity tells how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Label marks a secondary span that helps explain a diagnostic, for example
+// the place a variable was declared.
+type Label struct {
+	Span    lexer.Span
+	Message string
+}
+
+// Diagnostic is a single problem reported against a span of source. A zero
+// Span means the problem has no known location.
+type Diagnostic struct {
+	Severity Severity
+	Code     string // stable identifier such as E0201, see Explain
+	Message  string
+	Span     lexer.Span
+	Label    string // printed under the primary span, may be empty
+	Related  []Label
+	Notes    []string // printed after the source excerpt, such as suggestions
+}
+
+// Diagnoser is implemented by the errors of every compiler stage.
+type Diagnoser interface {
+	Diagnostic() *Diagnostic
+}
+
+// Emitter receives the diagnostics found in one file.
+type Emitter interface {
+	Emit(d *Diagnostic)
+	// Close writes out anything held back until every diagnostic is known.
+	Close() error
+}
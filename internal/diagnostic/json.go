@@ -0,0 +1,76 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"io"
+	"sigil/internal/lexer"
+)
+
+// JSONEmitter collects diagnostics and writes them as a single JSON document
+// when closed:
+//
+//	{"diagnostics": [{"severity": "error", "code": "E0208", "file": "main.sgl", ...}]}
+type JSONEmitter struct {
+	w           io.Writer
+	file        *File
+	diagnostics []jsonDiagnostic
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonLocation struct {
+	Message string        `json:"message,omitempty"`
+	File    string        `json:"file"`
+	Start   *jsonPosition `json:"start,omitempty"`
+	End     *jsonPosition `json:"end,omitempty"`
+}
+
+type jsonDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	jsonLocation
+	Related []jsonLocation `json:"related"`
+	Notes   []string       `json:"notes"`
+}
+
+func NewJSONEmitter(w io.Writer, file *File) *JSONEmitter {
+	return &JSONEmitter{w: w, file: file, diagnostics: []jsonDiagnostic{}}
+}
+
+func (e *JSONEmitter) Emit(d *Diagnostic) {
+	out := jsonDiagnostic{
+		Severity:     d.Severity.String(),
+		Code:         d.Code,
+		Message:      d.Message,
+		jsonLocation: e.location(d.Span, ""),
+		Related:      []jsonLocation{},
+		Notes:        append([]string{}, d.Notes...),
+	}
+	for _, label := range d.Related {
+		out.Related = append(out.Related, e.location(label.Span, label.Message))
+	}
+	e.diagnostics = append(e.diagnostics, out)
+}
+
+func (e *JSONEmitter) Close() error {
+	enc := json.NewEncoder(e.w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	}{e.diagnostics})
+}
+
+func (e *JSONEmitter) location(span lexer.Span, message string) jsonLocation {
+	loc := jsonLocation{Message: message, File: e.file.Name}
+	if span.Start.Line > 0 {
+		loc.Start = &jsonPosition{span.Start.Offset, span.Start.Line, span.Start.Column}
+		loc.End = &jsonPosition{span.End.Offset, span.End.Line, span.End.Column}
+	}
+	return loc
+}
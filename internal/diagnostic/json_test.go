@@ -0,0 +1,74 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONEmitter(t *testing.T) {
+	var out bytes.Buffer
+	e := NewJSONEmitter(&out, NewFile("main.sgl", "let x: Number = 1;\nx = \"a\";"))
+	e.Emit(&Diagnostic{
+		Code:    CodeAssignmentMismatch,
+		Message: "assignment type mismatch",
+		Span:    span(2, 5, 2, 8),
+		Related: []Label{{Span: span(1, 5, 1, 6), Message: "x declared here"}},
+		Notes:   []string{"did you mean `y`?"},
+	})
+	e.Emit(&Diagnostic{Severity: Warning, Code: CodeAssignmentMismatch, Message: "without location"})
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Diagnostics []struct {
+			Severity string
+			Code     string
+			Message  string
+			File     string
+			Start    *struct{ Line, Column int }
+			End      *struct{ Line, Column int }
+			Related  []struct {
+				Message string
+				Start   struct{ Line, Column int }
+			}
+			Notes []string
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, out.String())
+	}
+
+	if len(doc.Diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d", len(doc.Diagnostics))
+	}
+
+	d := doc.Diagnostics[0]
+	if d.Severity != "error" || d.Code != "E0208" || d.File != "main.sgl" {
+		t.Errorf("wrong header fields: %+v", d)
+	}
+	if d.Start == nil || d.Start.Line != 2 || d.Start.Column != 5 || d.End.Column != 8 {
+		t.Errorf("wrong location: %+v %+v", d.Start, d.End)
+	}
+	if len(d.Related) != 1 || d.Related[0].Message != "x declared here" || d.Related[0].Start.Line != 1 {
+		t.Errorf("wrong related locations: %+v", d.Related)
+	}
+	if len(d.Notes) != 1 || d.Notes[0] != "did you mean `y`?" {
+		t.Errorf("wrong notes: %+v", d.Notes)
+	}
+
+	if w := doc.Diagnostics[1]; w.Severity != "warning" || w.Start != nil || w.Notes == nil {
+		t.Errorf("expected a warning without location, got %+v", w)
+	}
+}
+
+func TestJSONEmitterWithoutDiagnostics(t *testing.T) {
+	var out bytes.Buffer
+	if err := NewJSONEmitter(&out, NewFile("main.sgl", "")).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "{\n  \"diagnostics\": []\n}\n" {
+		t.Errorf("got %q", out.String())
+	}
+}
@@ -0,0 +1,200 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/lexer"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is a named source text that diagnostics are rendered against.
+type File struct {
+	Name  string
+	lines []string
+}
+
+func NewFile(name, source string) *File {
+	return &File{Name: name, lines: strings.Split(source, "\n")}
+}
+
+// line returns line n, counting from 1, without its line terminator.
+func (f *File) line(n int) string {
+	if n < 1 || n > len(f.lines) {
+		return ""
+	}
+	return strings.TrimSuffix(f.lines[n-1], "\r")
+}
+
+// ANSI escape sequences used when color is enabled.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	blue   = "\x1b[1;34m"
+)
+
+// Spans covering more lines than this only show their first and last line.
+const maxSpanLines = 4
+
+// Printer renders diagnostics for one file.
+type Printer struct {
+	w     io.Writer
+	file  *File
+	color bool
+}
+
+func NewPrinter(w io.Writer, file *File, color bool) *Printer {
+	return &Printer{w: w, file: file, color: color}
+}
+
+type annotation struct {
+	span    lexer.Span
+	message string
+	primary bool
+}
+
+// Emit prints d straight away.
+func (p *Printer) Emit(d *Diagnostic) { p.Print(d) }
+
+// Close does nothing, a Printer holds nothing back.
+func (p *Printer) Close() error { return nil }
+
+// Print renders d followed by a blank line.
+func (p *Printer) Print(d *Diagnostic) {
+	severityColor := red
+	if d.Severity == Warning {
+		severityColor = yellow
+	}
+
+	header := d.Severity.String()
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(p.w, "%s%s\n", p.paint(severityColor, header), p.paint(bold, ": "+d.Message))
+
+	if d.Span.Start.Line == 0 {
+		fmt.Fprintf(p.w, " %s %s\n", p.paint(blue, "-->"), p.file.Name)
+		p.notes(" ", d.Notes)
+		fmt.Fprintln(p.w)
+		return
+	}
+
+	annotations := []annotation{{span: p.normalize(d.Span), message: d.Label, primary: true}}
+	for _, label := range d.Related {
+		if label.Span.Start.Line > 0 {
+			annotations = append(annotations, annotation{span: p.normalize(label.Span), message: label.Message})
+		}
+	}
+
+	shown := map[int]bool{}
+	for _, a := range annotations {
+		for line := a.span.Start.Line; line <= a.span.End.Line; line++ {
+			if a.span.End.Line-a.span.Start.Line < maxSpanLines || line == a.span.Start.Line || line == a.span.End.Line {
+				shown[line] = true
+			}
+		}
+	}
+	lines := make([]int, 0, len(shown))
+	for line := range shown {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	width := len(strconv.Itoa(lines[len(lines)-1]))
+	pad := strings.Repeat(" ", width)
+	gutter := p.paint(blue, pad+" |")
+
+	fmt.Fprintf(p.w, "%s%s %s:%d:%d\n", pad, p.paint(blue, "-->"), p.file.Name, d.Span.Start.Line, d.Span.Start.Column)
+	fmt.Fprintln(p.w, gutter)
+
+	for i, line := range lines {
+		if i > 0 && line > lines[i-1]+1 {
+			fmt.Fprintln(p.w, p.paint(blue, "..."))
+		}
+
+		text := p.file.line(line)
+		fmt.Fprintf(p.w, "%s %s\n", p.paint(blue, fmt.Sprintf("%*d |", width, line)), text)
+
+		for _, a := range annotations {
+			if line < a.span.Start.Line || line > a.span.End.Line {
+				continue
+			}
+			p.underline(gutter, text, line, a, severityColor)
+		}
+	}
+
+	fmt.Fprintln(p.w, gutter)
+	p.notes(pad, d.Notes)
+	fmt.Fprintln(p.w)
+}
+
+// notes prints each note lined up with the gutter.
+func (p *Printer) notes(pad string, notes []string) {
+	for _, note := range notes {
+		fmt.Fprintf(p.w, "%s %s %s\n", pad, p.paint(blue, "="), p.paint(bold, "note:")+" "+note)
+	}
+}
+
+// underline prints the markers under the part of text that a covers on the
+// given line, followed by its message on the span's last line.
+func (p *Printer) underline(gutter, text string, line int, a annotation, severityColor string) {
+	start := 1
+	if line == a.span.Start.Line {
+		start = a.span.Start.Column
+	} else {
+		start += len(text) - len(strings.TrimLeft(text, " \t"))
+	}
+
+	end := len(text) + 1
+	if line == a.span.End.Line {
+		end = a.span.End.Column
+	}
+
+	// Keep tabs so the markers line up with the source above them.
+	var indent strings.Builder
+	for i := 0; i < start-1; i++ {
+		if i < len(text) && text[i] == '\t' {
+			indent.WriteByte('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+
+	marker, color := "-", blue
+	if a.primary {
+		marker, color = "^", severityColor
+	}
+
+	markers := strings.Repeat(marker, max(1, end-start))
+	if line == a.span.End.Line && a.message != "" {
+		markers += " " + a.message
+	}
+
+	fmt.Fprintf(p.w, "%s %s%s\n", gutter, indent.String(), p.paint(color, markers))
+}
+
+func (p *Printer) paint(color, text string) string {
+	if !p.color {
+		return text
+	}
+	return color + text + reset
+}
+
+// normalize gives spans without a usable end a width of one column, and
+// moves ends just past a line break back to the end of the line before.
+func (p *Printer) normalize(span lexer.Span) lexer.Span {
+	if span.End.Line > span.Start.Line && span.End.Column == 1 {
+		span.End.Line--
+		span.End.Column = len(p.file.line(span.End.Line)) + 1
+	}
+
+	if span.End.Line < span.Start.Line ||
+		(span.End.Line == span.Start.Line && span.End.Column <= span.Start.Column) {
+		span.End = span.Start
+		span.End.Column++
+	}
+	return span
+}
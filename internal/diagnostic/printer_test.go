@@ -0,0 +1,130 @@
+package diagnostic
+
+import (
+	"bytes"
+	"sigil/internal/lexer"
+	"strings"
+	"testing"
+)
+
+func span(startLine, startColumn, endLine, endColumn int) lexer.Span {
+	return lexer.Span{
+		Start: lexer.Position{Line: startLine, Column: startColumn},
+		End:   lexer.Position{Line: endLine, Column: endColumn},
+	}
+}
+
+func TestPrint(t *testing.T) {
+	source := "let x: Number = 1;\n\tlet y = x + true;\nx = \"a\";"
+
+	tests := []struct {
+		name       string
+		diagnostic *Diagnostic
+		expected   string
+	}{
+		{
+			"single line",
+			&Diagnostic{Message: "cannot add Number and Boolean", Span: span(2, 10, 2, 18)},
+			`error: cannot add Number and Boolean
+ --> main.sgl:2:10
+  |
+2 | 	let y = x + true;
+  | 	        ^^^^^^^^
+  |
+
+`,
+		},
+		{
+			"secondary label",
+			&Diagnostic{
+				Message: "assignment type mismatch, expected Number, got String",
+				Span:    span(3, 5, 3, 8),
+				Label:   "expected Number",
+				Related: []Label{{Span: span(1, 5, 1, 6), Message: "x declared here"}},
+			},
+			`error: assignment type mismatch, expected Number, got String
+ --> main.sgl:3:5
+  |
+1 | let x: Number = 1;
+  |     - x declared here
+...
+3 | x = "a";
+  |     ^^^ expected Number
+  |
+
+`,
+		},
+		{
+			"multiple lines",
+			&Diagnostic{Severity: Warning, Message: "unused", Span: span(1, 17, 2, 11)},
+			`warning: unused
+ --> main.sgl:1:17
+  |
+1 | let x: Number = 1;
+  |                 ^^
+2 | 	let y = x + true;
+  | 	^^^^^^^^^
+  |
+
+`,
+		},
+		{
+			"without end",
+			&Diagnostic{Message: "unexpected token", Span: span(3, 1, 0, 0)},
+			`error: unexpected token
+ --> main.sgl:3:1
+  |
+3 | x = "a";
+  | ^
+  |
+
+`,
+		},
+		{
+			"notes",
+			&Diagnostic{Message: "undefined variable: y", Span: span(3, 1, 3, 2), Notes: []string{"did you mean `x`?"}},
+			`error: undefined variable: y
+ --> main.sgl:3:1
+  |
+3 | x = "a";
+  | ^
+  |
+  = note: did you mean ` + "`x`" + `?
+
+`,
+		},
+		{
+			"without location",
+			&Diagnostic{Message: "something went wrong"},
+			"error: something went wrong\n --> main.sgl\n\n",
+		},
+		{
+			"notes without location",
+			&Diagnostic{Message: "something went wrong", Notes: []string{"try again"}},
+			"error: something went wrong\n --> main.sgl\n  = note: try again\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		NewPrinter(&out, NewFile("main.sgl", source), false).Print(tt.diagnostic)
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output.\nexpected:\n%s\ngot:\n%s", tt.name, tt.expected, out.String())
+		}
+	}
+}
+
+func TestPrintColor(t *testing.T) {
+	var out bytes.Buffer
+	NewPrinter(&out, NewFile("main.sgl", "1 + true"), true).Print(&Diagnostic{
+		Message: "cannot add Number and Boolean",
+		Span:    span(1, 1, 1, 9),
+	})
+
+	if !strings.HasPrefix(out.String(), red+"error"+reset) {
+		t.Errorf("expected a colored severity, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), red+"^^^^^^^^"+reset) {
+		t.Errorf("expected colored markers, got %q", out.String())
+	}
+}
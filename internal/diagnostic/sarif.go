@@ -0,0 +1,163 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"io"
+	"sigil/internal/lexer"
+	"sort"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// SARIFEmitter collects diagnostics and writes them as a SARIF 2.1.0 log
+// when closed, for code scanning tools that annotate pull requests.
+type SARIFEmitter struct {
+	w       io.Writer
+	file    *File
+	version string // of the sigil tool
+	results []sarifResult
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+	FullDescription  *sarifMessage `json:"fullDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion uses 1-based lines and columns like Sigil does; columns count
+// bytes, which matches SARIF's default for ASCII sources.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	CharOffset  int `json:"charOffset"`
+	CharLength  int `json:"charLength"`
+}
+
+func NewSARIFEmitter(w io.Writer, file *File, version string) *SARIFEmitter {
+	return &SARIFEmitter{w: w, file: file, version: version, results: []sarifResult{}}
+}
+
+func (e *SARIFEmitter) Emit(d *Diagnostic) {
+	level := "error"
+	if d.Severity == Warning {
+		level = "warning"
+	}
+
+	// SARIF has no place of its own for notes, so they follow the message
+	// the way the printer shows them.
+	text := d.Message
+	for _, note := range d.Notes {
+		text += "\nnote: " + note
+	}
+
+	result := sarifResult{
+		RuleID:    d.Code,
+		Level:     level,
+		Message:   sarifMessage{Text: text},
+		Locations: []sarifLocation{e.location(d.Span)},
+	}
+	for i, label := range d.Related {
+		loc := e.location(label.Span)
+		id := i + 1
+		loc.ID = &id
+		loc.Message = &sarifMessage{Text: label.Message}
+		result.RelatedLocations = append(result.RelatedLocations, loc)
+	}
+	e.results = append(e.results, result)
+}
+
+func (e *SARIFEmitter) Close() error {
+	seen := map[string]bool{}
+	rules := []sarifRule{}
+	for _, result := range e.results {
+		if !seen[result.RuleID] {
+			seen[result.RuleID] = true
+			rule := sarifRule{ID: result.RuleID}
+			if explanation, ok := Explain(result.RuleID); ok {
+				rule.ShortDescription = &sarifMessage{Text: explanation.Title}
+				rule.FullDescription = &sarifMessage{Text: explanation.Text}
+			}
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	enc := json.NewEncoder(e.w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "sigil", Version: e.version, Rules: rules}},
+			Results: e.results,
+		}},
+	})
+}
+
+func (e *SARIFEmitter) location(span lexer.Span) sarifLocation {
+	loc := sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: e.file.Name}},
+	}
+	if span.Start.Line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   span.Start.Line,
+			StartColumn: span.Start.Column,
+			EndLine:     span.End.Line,
+			EndColumn:   span.End.Column,
+			CharOffset:  span.Start.Offset,
+			CharLength:  span.End.Offset - span.Start.Offset,
+		}
+	}
+	return loc
+}
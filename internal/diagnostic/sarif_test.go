@@ -0,0 +1,92 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSARIFEmitter(t *testing.T) {
+	var out bytes.Buffer
+	e := NewSARIFEmitter(&out, NewFile("main.sgl", "let x: Number = 1;\nx = \"a\";"), "1.2.3")
+	e.Emit(&Diagnostic{
+		Code:    CodeAssignmentMismatch,
+		Message: "assignment type mismatch",
+		Span:    span(2, 5, 2, 8),
+		Related: []Label{{Span: span(1, 5, 1, 6), Message: "x declared here"}},
+	})
+	e.Emit(&Diagnostic{Severity: Warning, Code: CodeUnexpectedToken, Message: "odd", Notes: []string{"did you mean `even`?", "see the docs"}})
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name    string
+					Version string
+					Rules   []struct {
+						ID               string
+						ShortDescription struct{ Text string }
+					}
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           *struct{ StartLine, StartColumn, EndLine, EndColumn int }
+					}
+				}
+				RelatedLocations []struct {
+					ID      int
+					Message struct{ Text string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, out.String())
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected one SARIF 2.1.0 run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "sigil" || run.Tool.Driver.Version != "1.2.3" {
+		t.Errorf("wrong driver: %+v", run.Tool.Driver)
+	}
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "E0101" || run.Tool.Driver.Rules[1].ID != "E0208" {
+		t.Errorf("expected sorted rules for each code, got %+v", run.Tool.Driver.Rules)
+	}
+	if got := run.Tool.Driver.Rules[1].ShortDescription.Text; got != "assignment changes the type of a variable" {
+		t.Errorf("expected the rule to be described by its explanation, got %q", got)
+	}
+
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(run.Results))
+	}
+	result := run.Results[0]
+	if result.RuleID != "E0208" || result.Level != "error" || result.Message.Text != "assignment type mismatch" {
+		t.Errorf("wrong result: %+v", result)
+	}
+	loc := result.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "main.sgl" || loc.Region == nil || loc.Region.StartLine != 2 || loc.Region.EndColumn != 8 {
+		t.Errorf("wrong location: %+v", loc)
+	}
+	if len(result.RelatedLocations) != 1 || result.RelatedLocations[0].ID != 1 || result.RelatedLocations[0].Message.Text != "x declared here" {
+		t.Errorf("wrong related locations: %+v", result.RelatedLocations)
+	}
+
+	if w := run.Results[1]; w.Level != "warning" || w.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("expected a warning without a region, got %+v", w)
+	}
+	if got := run.Results[1].Message.Text; got != "odd\nnote: did you mean `even`?\nnote: see the docs" {
+		t.Errorf("expected the notes after the message, got %q", got)
+	}
+}
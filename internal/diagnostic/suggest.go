@@ -0,0 +1,60 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Suggest returns the candidate closest to name, for "did you mean" notes on
+// misspelled names. Case is ignored when measuring, and a candidate must be
+// within one edit for every three characters of name to count as close.
+func Suggest(name string, candidates []string) (string, bool) {
+	limit := max(1, len(name)/3)
+	best, bestDistance := "", limit+1
+
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// DidYouMean formats a suggestion as a note.
+func DidYouMean(suggestion string) string {
+	return fmt.Sprintf("did you mean `%s`?", suggestion)
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent characters that turn a into b, so that "valeu" is one edit away
+// from "value".
+func editDistance(a, b string) int {
+	// d[i][j] is the distance between the first i bytes of a and the first
+	// j bytes of b.
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,57 @@
+package diagnostic
+
+import "testing"
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"count", "counter", "print", "println", "Number", "String"}
+
+	tests := []struct {
+		name     string
+		expected string // empty when nothing is close enough
+	}{
+		{"cont", "count"},
+		{"countr", "count"},
+		{"prnt", "print"},
+		{"printn", "print"},
+		{"Numbr", "Number"},
+		{"number", "Number"},
+		{"strin", "String"},
+		{"x", ""},
+		{"banana", ""},
+		{"count", ""}, // an exact match is not a suggestion
+	}
+
+	for _, tt := range tests {
+		got, ok := Suggest(tt.name, candidates)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("%q: expected no suggestion, got %q", tt.name, got)
+			}
+			continue
+		}
+		if !ok || got != tt.expected {
+			t.Errorf("%q: expected %q, got %q (ok=%v)", tt.name, tt.expected, got, ok)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"same", "same", 0},
+		{"valeu", "value", 1},
+		{"ab", "ba", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
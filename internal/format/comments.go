@@ -0,0 +1,36 @@
+package format
+
+import "strings"
+
+// comment is a line comment of the source, from its // to the end of the
+// line.
+type comment struct {
+	start, end int
+	text       string
+}
+
+// scanComments finds the comments of src. The lexer skips comments, so they
+// are collected here by the same rules: a // outside a string literal runs
+// to the end of the line.
+func scanComments(src string) []comment {
+	var comments []comment
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return comments
+			}
+			i += end + 1
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			text := strings.TrimRight(src[i:i+end], " \t\r")
+			comments = append(comments, comment{start: i, end: i + end, text: text})
+			i += end
+		}
+	}
+	return comments
+}
@@ -0,0 +1,399 @@
+// Package format prints Sigil programs in the canonical style: four space
+// indentation, spaces around binary operators, a semicolon after every let
+// and return, and call arguments one per line when a call does not fit on
+// its line. Comments and single blank lines between statements are kept.
+package format
+
+import (
+	"sigil/internal/ast"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// lineWidth is the column past which call arguments are wrapped.
+const lineWidth = 100
+
+const indentation = "    "
+
+// Source formats a program. It returns the first parse error if src does not
+// parse.
+func Source(src string) (string, error) {
+	par := parser.New(lexer.New(src))
+	program := par.ParseProgram()
+	if errs := par.Errors(); len(errs) > 0 {
+		return "", errs[0]
+	}
+	return Program(program, src), nil
+}
+
+// Program formats a program parsed from src. The source supplies the
+// comments and blank lines the syntax tree does not keep.
+func Program(program *ast.Program, src string) string {
+	p := &printer{src: src, comments: scanComments(src)}
+	out := p.statements(program.Statements, 0, len(src), 0)
+	if out == "" {
+		return ""
+	}
+	return out + "\n"
+}
+
+type printer struct {
+	src      string
+	comments []comment
+	next     int // the first comment not printed yet
+}
+
+// statements prints a list of statements at the given depth, one per line,
+// with the comments that fall between start and end. Every line but the
+// first is indented; the caller indents the first.
+func (p *printer) statements(stmts []ast.Statement, start, end, depth int) string {
+	pad := strings.Repeat(indentation, depth)
+	var lines []string
+	last := start
+
+	// line adds a line, after a blank one if the source had a blank line
+	// between the previous line and the source at offset.
+	line := func(text string, offset int) {
+		if len(lines) > 0 && strings.Count(p.src[last:offset], "\n") > 1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, pad+text)
+	}
+
+	for i, stmt := range stmts {
+		span := stmt.Span()
+		for p.pending(span.Start.Offset) {
+			c := p.take()
+			line(c.text, c.start)
+			last = c.end
+		}
+
+		line(p.statement(stmt, depth), span.Start.Offset)
+		last = span.End.Offset
+
+		// Comments inside the statement that no block or call of it printed,
+		// and the comment ending its line, follow the statement.
+		limit := end
+		if i+1 < len(stmts) {
+			limit = stmts[i+1].Span().Start.Offset
+		}
+		trailing := true
+		for p.pending(limit) {
+			c := p.comments[p.next]
+			if c.start >= span.End.Offset && strings.Contains(p.src[span.End.Offset:c.start], "\n") {
+				break
+			}
+			p.take()
+			if trailing {
+				lines[len(lines)-1] += " " + c.text
+				trailing = false
+			} else {
+				lines = append(lines, pad+c.text)
+			}
+			last = max(last, c.end)
+		}
+	}
+
+	for p.pending(end) {
+		c := p.take()
+		line(c.text, c.start)
+		last = c.end
+	}
+
+	return strings.TrimPrefix(strings.Join(lines, "\n"), pad)
+}
+
+// pending reports whether a comment not printed yet starts before offset.
+func (p *printer) pending(offset int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].start < offset
+}
+
+func (p *printer) take() comment {
+	c := p.comments[p.next]
+	p.next++
+	return c
+}
+
+func (p *printer) statement(stmt ast.Statement, depth int) string {
+	col := depth * len(indentation)
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		head := "let " + s.Name.Value
+		if s.TypeHint != nil {
+			head += ": " + typeString(s.TypeHint)
+		}
+		head += " = "
+		return head + p.expression(s.Value, depth, col+len(head)) + ";"
+
+	case *ast.ReturnStatement:
+		if s.ReturnValue == nil {
+			return "return;"
+		}
+		return "return " + p.expression(s.ReturnValue, depth, col+len("return ")) + ";"
+
+	case *ast.TestStatement:
+		head := "test " + p.expression(s.Name, depth, col+len("test ")) + " "
+		return head + p.block(s.Body, depth, col+len(head))
+
+	case *ast.ExpressionStatement:
+		out := p.expression(s.Expression, depth, col)
+		if s.HasSemicolon {
+			out += ";"
+		}
+		return out
+	}
+	return stmt.String()
+}
+
+// expression prints expr starting at column col of a line indented depth
+// levels. Lines after the first carry their own indentation.
+func (p *printer) expression(expr ast.Expression, depth, col int) string {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		return e.Value
+
+	case *ast.NumberLiteral:
+		if e.Token.Literal != "" {
+			return e.Token.Literal
+		}
+		return strconv.FormatFloat(e.Value, 'f', -1, 64)
+
+	case *ast.StringLiteral:
+		return `"` + e.Value + `"`
+
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(e.Value)
+
+	case *ast.PrefixExpression:
+		right := p.operand(e.Right, prefixPrecedence, false, depth, col+len(e.Operator))
+		return e.Operator + right
+
+	case *ast.InfixExpression:
+		prec := precedence(e.Operator)
+		left := p.operand(e.Left, prec, false, depth, col)
+		out := left + " " + e.Operator + " "
+		return out + p.operand(e.Right, prec, true, depth, advance(col, out))
+
+	case *ast.AssignmentExpression:
+		out := e.Name.Value + " = "
+		return out + p.expression(e.Value, depth, col+len(out))
+
+	case *ast.CallExpression:
+		return p.call(e, depth, col)
+
+	case *ast.IfExpression:
+		out := "if ("
+		out += p.expression(e.Condition, depth, advance(col, out)) + ") "
+		out += p.block(e.Consequence, depth, advance(col, out))
+		if e.Alternative != nil {
+			out += " else "
+			out += p.block(e.Alternative, depth, advance(col, out))
+		}
+		return out
+
+	case *ast.FunctionLiteral:
+		params := make([]string, len(e.Parameters))
+		for i, param := range e.Parameters {
+			params[i] = param.Name.Value
+			if param.TypeHint != nil {
+				params[i] += ": " + typeString(param.TypeHint)
+			}
+		}
+		out := "fun(" + strings.Join(params, ", ") + ")"
+		if e.ReturnType != nil {
+			out += ": " + typeString(e.ReturnType)
+		}
+		out += " "
+		return out + p.block(e.Body, depth, advance(col, out))
+	}
+	return expr.String()
+}
+
+// call prints a call on one line if it fits and holds no comments outside
+// its blocks, and otherwise with one argument per line, each followed by the
+// comments written after it.
+func (p *printer) call(e *ast.CallExpression, depth, col int) string {
+	out := p.operand(e.Function, callPrecedence, false, depth, col) + "("
+	if len(e.Arguments) == 0 {
+		return out + ")"
+	}
+
+	end := e.Span().End.Offset
+	next := p.next
+	flat := out
+	for i, arg := range e.Arguments {
+		if i > 0 {
+			flat += ", "
+		}
+		flat += p.expression(arg, depth, advance(col, flat))
+	}
+	flat += ")"
+	if fits(col, flat) && !p.commentsIn(e.Token.Span.Start.Offset, end) {
+		return flat
+	}
+
+	// Print the arguments again, so that comments inside them are taken in
+	// the same order.
+	p.next = next
+	pad := strings.Repeat(indentation, depth+1)
+	out += "\n"
+	for i, arg := range e.Arguments {
+		span := arg.Span()
+		for p.pending(span.Start.Offset) {
+			out += pad + p.take().text + "\n"
+		}
+
+		out += pad + p.expression(arg, depth+1, len(pad))
+		if i < len(e.Arguments)-1 {
+			out += ","
+		}
+
+		// The comment ending the argument's line follows it; the comments
+		// inside it that no block printed go on lines of their own.
+		limit := end
+		if i+1 < len(e.Arguments) {
+			limit = e.Arguments[i+1].Span().Start.Offset
+		}
+		trailing := true
+		for p.pending(limit) {
+			c := p.comments[p.next]
+			if c.start >= span.End.Offset && strings.Contains(p.src[span.End.Offset:c.start], "\n") {
+				break
+			}
+			p.take()
+			if trailing {
+				out += " " + c.text
+				trailing = false
+			} else {
+				out += "\n" + pad + c.text
+			}
+		}
+		out += "\n"
+	}
+	for p.pending(end) {
+		out += pad + p.take().text + "\n"
+	}
+	return out + strings.Repeat(indentation, depth) + ")"
+}
+
+// commentsIn reports whether a comment not printed yet starts between start
+// and end.
+func (p *printer) commentsIn(start, end int) bool {
+	for _, c := range p.comments[p.next:] {
+		if c.start >= end {
+			break
+		}
+		if c.start > start {
+			return true
+		}
+	}
+	return false
+}
+
+// block prints a block on one line if it was written on one line, holds at
+// most one statement and no comments, and fits. Otherwise each statement
+// gets a line of its own.
+func (p *printer) block(b *ast.BlockStatement, depth, col int) string {
+	span := b.Span()
+	if !p.pending(span.End.Offset) {
+		if len(b.Statements) == 0 {
+			return "{}"
+		}
+		if len(b.Statements) == 1 && !strings.Contains(p.src[span.Start.Offset:span.End.Offset], "\n") {
+			next := p.next
+			flat := "{ " + p.statement(b.Statements[0], depth) + " }"
+			if fits(col, flat) {
+				return flat
+			}
+			p.next = next
+		}
+	}
+
+	body := p.statements(b.Statements, span.Start.Offset+1, span.End.Offset, depth+1)
+	pad := strings.Repeat(indentation, depth)
+	return "{\n" + pad + indentation + body + "\n" + pad + "}"
+}
+
+// Binding strength of the operators, as in the parser.
+const (
+	lowestPrecedence = iota
+	equalsPrecedence
+	comparePrecedence
+	sumPrecedence
+	productPrecedence
+	prefixPrecedence
+	callPrecedence
+)
+
+func precedence(operator string) int {
+	switch operator {
+	case "==", "!=":
+		return equalsPrecedence
+	case "<", ">", "<=", ">=":
+		return comparePrecedence
+	case "+", "-":
+		return sumPrecedence
+	case "*", "/":
+		return productPrecedence
+	}
+	return lowestPrecedence
+}
+
+// operand prints an operand of an operator of the given precedence, in
+// parentheses if it binds less tightly. Operators associate to the left, so
+// a right operand of the same precedence needs parentheses too.
+func (p *printer) operand(expr ast.Expression, prec int, right bool, depth, col int) string {
+	inner := callPrecedence
+	switch e := expr.(type) {
+	case *ast.InfixExpression:
+		inner = precedence(e.Operator)
+	case *ast.PrefixExpression:
+		inner = prefixPrecedence
+	case *ast.AssignmentExpression:
+		inner = lowestPrecedence
+	}
+
+	if inner < prec || (right && inner == prec) {
+		return "(" + p.expression(expr, depth, col+1) + ")"
+	}
+	return p.expression(expr, depth, col)
+}
+
+func typeString(t ast.Type) string {
+	switch tt := t.(type) {
+	case *ast.SimpleType:
+		return tt.Name
+	case *ast.FunctionType:
+		params := make([]string, len(tt.ParamTypes))
+		for i, param := range tt.ParamTypes {
+			params[i] = typeString(param)
+		}
+		return "(" + strings.Join(params, ", ") + ") -> " + typeString(tt.ReturnType)
+	}
+	return t.String()
+}
+
+// advance returns the column reached by writing s from column col.
+func advance(col int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return utf8.RuneCountInString(s[i+1:])
+	}
+	return col + utf8.RuneCountInString(s)
+}
+
+// fits reports whether every line of s, the first starting at column col,
+// ends within the line width.
+func fits(col int, s string) bool {
+	for i, line := range strings.Split(s, "\n") {
+		if i == 0 {
+			line = strings.Repeat(" ", col) + line
+		}
+		if utf8.RuneCountInString(line) > lineWidth {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package format
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"spacing", "let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"grouping", "(1+2)*3;", "(1 + 2) * 3;\n"},
+		{"redundant parentheses", "((1 * 2)) + 3", "1 * 2 + 3\n"},
+		{"right operand", "a - (b - c)", "a - (b - c)\n"},
+		{"left operand", "(a - b) - c", "a - b - c\n"},
+		{"prefix", "-(a + b); !flag;", "-(a + b);\n!flag;\n"},
+		{"return", "let f = fun(): Number { return 1 }", "let f = fun(): Number { return 1; };\n"},
+		{
+			"types",
+			"let f=fun(a:Number,b:String):(Number)->Bool{g};",
+			"let f = fun(a: Number, b: String): (Number) -> Bool { g };\n",
+		},
+		{"one line if", "if (c) {a} else {b}", "if (c) { a } else { b }\n"},
+		{
+			"block",
+			"if (c) {\nlet a = 1;   a\n}",
+			"if (c) {\n    let a = 1;\n    a\n}\n",
+		},
+		{
+			"test",
+			"test   \"adds\"{\nassert_eq(2,1+1)\n}\ntest \"one line\" {assert(true);}",
+			"test \"adds\" {\n    assert_eq(2, 1 + 1)\n}\ntest \"one line\" { assert(true); }\n",
+		},
+		{"empty block", "let f = fun(): Number {\n};", "let f = fun(): Number {};\n"},
+		{
+			"blank lines",
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"comments",
+			"// leading\nlet a = 1;   // trailing   \n\n// before b\nlet b = 2;\n// last\n",
+			"// leading\nlet a = 1; // trailing\n\n// before b\nlet b = 2;\n// last\n",
+		},
+		{
+			"comment in block",
+			"let f = fun(): Number { // why\n  // how\n    1\n}",
+			"let f = fun(): Number {\n    // why\n    // how\n    1\n};\n",
+		},
+		{"comment in string", `println("// not a comment")`, "println(\"// not a comment\")\n"},
+		{
+			"comments in call arguments",
+			"let h = f(\n 1, // one\n 2 // two\n);",
+			"let h = f(\n    1, // one\n    2 // two\n);\n",
+		},
+		{
+			"comments around call arguments",
+			"g(\n  // first\n  1,\n  h(2, // two\n    3),\n  4\n  // last\n)",
+			"g(\n    // first\n    1,\n    h(\n        2, // two\n        3\n    ),\n    4\n    // last\n)\n",
+		},
+		{
+			"comment in block argument",
+			"apply(fun(): Number {\n  // one\n  1\n}, 2)",
+			"apply(fun(): Number {\n    // one\n    1\n}, 2)\n",
+		},
+		{
+			"long call",
+			`println(concat("the first argument is long", "the second one is a good deal longer", "and then a third"))`,
+			"println(concat(\n    \"the first argument is long\",\n    \"the second one is a good deal longer\",\n    \"and then a third\"\n))\n",
+		},
+		{
+			"long call in block",
+			`let f = fun(): Number { add(aVeryLongArgumentNameIndeed, anotherVeryLongArgumentNameToo, yetAnotherMuchLongerArgumentName) }`,
+			"let f = fun(): Number {\n    add(\n        aVeryLongArgumentNameIndeed,\n        anotherVeryLongArgumentNameToo,\n        yetAnotherMuchLongerArgumentName\n    )\n};\n",
+		},
+		{"empty", "// only a comment", "// only a comment\n"},
+		{"nothing", "\n\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source(tt.input)
+			if err != nil {
+				t.Fatalf("Source(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Source(%q) =\n%s\nwant\n%s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source("let = 1;"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+// TestExamples checks that formatting every example that parses keeps its
+// syntax tree and comments, and that formatted code is left as it is.
+func TestExamples(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir("../../examples", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".sgl" {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src := string(data)
+			before, ok := tree(src)
+			if !ok {
+				t.Skip("does not parse")
+			}
+
+			once, err := Source(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, ok := tree(once)
+			if !ok {
+				t.Fatalf("formatted code does not parse:\n%s", once)
+			}
+			if after != before {
+				t.Errorf("formatting changed the syntax tree:\n%s\nbecame\n%s", before, after)
+			}
+			if got, want := commentText(once), commentText(src); got != want {
+				t.Errorf("formatting changed the comments:\n%s\nbecame\n%s", want, got)
+			}
+
+			twice, err := Source(once)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if twice != once {
+				t.Errorf("formatting is not idempotent:\n%s\nbecame\n%s", once, twice)
+			}
+		})
+	}
+}
+
+// tree returns the syntax tree of src, without positions, and whether src
+// parses.
+func tree(src string) (string, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return "", false
+	}
+	return program.TreeString("", true), true
+}
+
+func commentText(src string) string {
+	var texts []string
+	for _, c := range scanComments(src) {
+		texts = append(texts, c.text)
+	}
+	return strings.Join(texts, "\n")
+}
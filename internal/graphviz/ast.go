@@ -0,0 +1,151 @@
+package graphviz
+
+import (
+	"fmt"
+	"reflect"
+	"sigil/internal/ast"
+	"sigil/internal/typechecker"
+	"strconv"
+)
+
+// AST draws a syntax tree. A node is labelled with its kind, what sets it
+// apart such as the name of an identifier or an operator, and the type the
+// checker worked out for it if result is not nil. An edge is labelled with the field
+// of the parent that holds the child.
+func AST(program *ast.Program, result *typechecker.Result) *Graph {
+	g := New("ast")
+	g.NodeAttrs = Attrs{"fontname": "monospace"}
+	next := 0
+
+	var add func(node ast.Node)
+	add = func(node ast.Node) {
+		id := fmt.Sprintf("n%d", next)
+		next++
+
+		label := reflect.TypeOf(node).Elem().Name()
+		if detail := detail(node); detail != "" {
+			label += "\n" + detail
+		}
+		if result != nil {
+			t := result.TypeOf(node)
+			if _, unknown := t.(*typechecker.UnknownType); t != nil && !unknown {
+				label += "\n: " + t.String()
+			}
+		}
+
+		attrs := Attrs{"label": label}
+		switch node.(type) {
+		case ast.Statement, *ast.Program:
+			attrs["shape"] = "box"
+		case ast.Type:
+			attrs["style"] = "dashed"
+		}
+		g.Node(id, attrs)
+
+		for _, f := range fields(node) {
+			// The child gets the next ID, so that the edges come in the
+			// same order as the nodes.
+			g.Edge(id, fmt.Sprintf("n%d", next), Attrs{"label": f.name})
+			add(f.node)
+		}
+	}
+	add(program)
+	return g
+}
+
+// detail returns what tells a node apart from others of its kind.
+func detail(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Identifier:
+		return n.Value
+	case *ast.NumberLiteral:
+		if n.Token.Literal != "" {
+			return n.Token.Literal
+		}
+		return strconv.FormatFloat(n.Value, 'f', -1, 64)
+	case *ast.StringLiteral:
+		return strconv.Quote(n.Value)
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(n.Value)
+	case *ast.PrefixExpression:
+		return n.Operator
+	case *ast.InfixExpression:
+		return n.Operator
+	case *ast.SimpleType:
+		return n.Name
+	}
+	return ""
+}
+
+type field struct {
+	name string
+	node ast.Node
+}
+
+// fields returns the children of a node with the names of the fields that
+// hold them, in source order.
+func fields(node ast.Node) []field {
+	var out []field
+	add := func(name string, node ast.Node) {
+		if node != nil && !reflect.ValueOf(node).IsNil() {
+			out = append(out, field{name, node})
+		}
+	}
+	indexed := func(name string, i int) string {
+		return fmt.Sprintf("%s[%d]", name, i)
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for i, stmt := range n.Statements {
+			add(indexed("statements", i), stmt)
+		}
+	case *ast.BlockStatement:
+		for i, stmt := range n.Statements {
+			add(indexed("statements", i), stmt)
+		}
+	case *ast.LetStatement:
+		add("name", n.Name)
+		add("typeHint", n.TypeHint)
+		add("value", n.Value)
+	case *ast.ReturnStatement:
+		add("returnValue", n.ReturnValue)
+	case *ast.ExpressionStatement:
+		add("expression", n.Expression)
+	case *ast.TestStatement:
+		add("name", n.Name)
+		add("body", n.Body)
+	case *ast.AssignmentExpression:
+		add("name", n.Name)
+		add("value", n.Value)
+	case *ast.PrefixExpression:
+		add("right", n.Right)
+	case *ast.InfixExpression:
+		add("left", n.Left)
+		add("right", n.Right)
+	case *ast.IfExpression:
+		add("condition", n.Condition)
+		add("consequence", n.Consequence)
+		add("alternative", n.Alternative)
+	case *ast.CallExpression:
+		add("function", n.Function)
+		for i, arg := range n.Arguments {
+			add(indexed("arguments", i), arg)
+		}
+	case *ast.FunctionLiteral:
+		for i, param := range n.Parameters {
+			add(indexed("parameters", i), param)
+		}
+		add("returnType", n.ReturnType)
+		add("body", n.Body)
+	case *ast.FunctionParameter:
+		add("name", n.Name)
+		add("typeHint", n.TypeHint)
+	case *ast.FunctionType:
+		for i, param := range n.ParamTypes {
+			add(indexed("paramTypes", i), param)
+		}
+		add("returnType", n.ReturnType)
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+// Package graphviz writes graphs in the DOT language of Graphviz, and draws
+// syntax trees as graphs.
+package graphviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph is a directed graph. Nodes and edges are written in the order they
+// were added.
+type Graph struct {
+	Name      string
+	NodeAttrs Attrs // the defaults for every node
+	nodes     []node
+	edges     []edge
+	ids       map[string]bool
+}
+
+// Attrs are the attributes of a node or an edge, such as "label" or
+// "shape".
+type Attrs map[string]string
+
+type node struct {
+	id    string
+	attrs Attrs
+}
+
+type edge struct {
+	from, to string
+	attrs    Attrs
+}
+
+// New returns an empty graph.
+func New(name string) *Graph {
+	return &Graph{Name: name, ids: map[string]bool{}}
+}
+
+// Node adds a node. Adding a node with an ID the graph already has does
+// nothing.
+func (g *Graph) Node(id string, attrs Attrs) {
+	if g.ids[id] {
+		return
+	}
+	g.ids[id] = true
+	g.nodes = append(g.nodes, node{id, attrs})
+}
+
+// HasNode reports whether the graph has a node with the ID.
+func (g *Graph) HasNode(id string) bool {
+	return g.ids[id]
+}
+
+// Edge adds an edge between two nodes.
+func (g *Graph) Edge(from, to string, attrs Attrs) {
+	g.edges = append(g.edges, edge{from, to, attrs})
+}
+
+// String returns the graph in the DOT language.
+func (g *Graph) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "digraph %s {\n", Quote(g.Name))
+	if len(g.NodeAttrs) > 0 {
+		fmt.Fprintf(&out, "  node%s;\n", g.NodeAttrs)
+	}
+	for _, n := range g.nodes {
+		fmt.Fprintf(&out, "  %s%s;\n", Quote(n.id), n.attrs)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&out, "  %s -> %s%s;\n", Quote(e.from), Quote(e.to), e.attrs)
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// String returns the attributes in brackets, sorted by name, or nothing if
+// there are none.
+func (a Attrs) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + Quote(a[name])
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// Quote returns s as a DOT string. Newlines become line breaks in labels.
+func Quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,69 @@
+package graphviz
+
+import (
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	g := New("g")
+	g.Node("a", Attrs{"label": `say "hi"` + "\nthen \\ leave"})
+	g.Node("b", nil)
+	g.Node("a", Attrs{"label": "ignored"})
+	g.Edge("a", "b", Attrs{"style": "dashed", "label": "x"})
+
+	want := `digraph "g" {
+  "a" [label="say \"hi\"\nthen \\ leave"];
+  "b";
+  "a" -> "b" [label="x", style="dashed"];
+}
+`
+	if got := g.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if !g.HasNode("b") || g.HasNode("c") {
+		t.Errorf("HasNode disagrees with the nodes added")
+	}
+}
+
+func TestAST(t *testing.T) {
+	p := parser.New(lexer.New("let f = fun(n: Number): Number { -n };"))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	result := typechecker.New().CheckProgram(program)
+
+	want := `digraph "ast" {
+  node [fontname="monospace"];
+  "n0" [label="Program\n: Void", shape="box"];
+  "n1" [label="LetStatement\n: Void", shape="box"];
+  "n2" [label="Identifier\nf\n: (Number) -> Number"];
+  "n3" [label="FunctionLiteral\n: (Number) -> Number"];
+  "n4" [label="FunctionParameter"];
+  "n5" [label="Identifier\nn\n: Number"];
+  "n6" [label="SimpleType\nNumber\n: Number", style="dashed"];
+  "n7" [label="SimpleType\nNumber\n: Number", style="dashed"];
+  "n8" [label="BlockStatement\n: Number", shape="box"];
+  "n9" [label="ExpressionStatement\n: Number", shape="box"];
+  "n10" [label="PrefixExpression\n-\n: Number"];
+  "n11" [label="Identifier\nn\n: Number"];
+  "n0" -> "n1" [label="statements[0]"];
+  "n1" -> "n2" [label="name"];
+  "n1" -> "n3" [label="value"];
+  "n3" -> "n4" [label="parameters[0]"];
+  "n4" -> "n5" [label="name"];
+  "n4" -> "n6" [label="typeHint"];
+  "n3" -> "n7" [label="returnType"];
+  "n3" -> "n8" [label="body"];
+  "n8" -> "n9" [label="statements[0]"];
+  "n9" -> "n10" [label="expression"];
+  "n10" -> "n11" [label="right"];
+}
+`
+	if got := AST(program, result).String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 )
 
@@ -11,15 +12,34 @@ type Lexer struct {
 	ch           byte // current char
 	line         int
 	column       int
+
+	trivia bool     // emit whitespace and comments as tokens
+	end    Position // where the last token returned ended
+
+	docLines []string // the /// comment lines since the last token
+	doc      string   // the doc comment of the last token returned
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input, line: 1}
+	l := &Lexer{input: input, line: 1, end: Position{Offset: 0, Line: 1, Column: 1}}
 	l.readChar()
 	return l
 }
 
+// NewWithTrivia returns a lexer that also returns the whitespace and
+// comments between tokens, as WHITESPACE and COMMENT tokens, so that the
+// spans of its tokens cover the whole input.
+func NewWithTrivia(input string) *Lexer {
+	l := New(input)
+	l.trivia = true
+	return l
+}
+
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return // already at the end, stay on the EOF position
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -43,9 +63,70 @@ func (l *Lexer) peekChar() byte {
 }
 
 func (l *Lexer) NextToken() Token {
-	var tok Token
+	if l.trivia && l.atTrivia() {
+		return l.readTrivia()
+	}
 	l.skipWhitespaceAndComments()
 
+	start := Position{Offset: l.position, Line: l.line, Column: l.column}
+	tok := l.readToken()
+	tok.Span = Span{Start: start, End: l.endOf(start)}
+	l.end = tok.Span.End
+
+	l.doc = strings.Join(l.docLines, "\n")
+	l.docLines = nil
+	return tok
+}
+
+// DocComment returns the doc comment written right before the last token
+// NextToken returned other than trivia: the text of the /// lines with no
+// blank line or other comment between them and the token, one line per
+// comment without the slashes.
+func (l *Lexer) DocComment() string {
+	return l.doc
+}
+
+func (l *Lexer) atTrivia() bool {
+	return isSpace(l.ch) || (l.ch == '/' && l.peekChar() == '/')
+}
+
+// readTrivia reads a run of whitespace or a comment. It starts where the
+// last token ended, since a newline's own position is reported on the line
+// after it.
+func (l *Lexer) readTrivia() Token {
+	start := l.end
+	tok := Token{Line: start.Line, Column: start.Column}
+	if isSpace(l.ch) {
+		tok.Type = WHITESPACE
+		tok.Literal = l.readWhitespace()
+	} else {
+		tok.Type = COMMENT
+		tok.Literal = l.readComment()
+	}
+	tok.Span = Span{Start: start, End: l.endOf(start)}
+	l.end = tok.Span.End
+	return tok
+}
+
+// endOf returns the position just past the token that began at start and
+// ends where the lexer currently stands.
+func (l *Lexer) endOf(start Position) Position {
+	end := start
+	stop := min(l.position, len(l.input))
+	for ; end.Offset < stop; end.Offset++ {
+		if l.input[end.Offset] == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+	}
+	return end
+}
+
+func (l *Lexer) readToken() Token {
+	var tok Token
+
 	// Capture position at the START of the token
 	tokenLine := l.line
 	tokenColumn := l.column
@@ -150,6 +231,11 @@ func (l *Lexer) NextToken() Token {
 		tok.Literal = l.readString()
 		tok.Line = tokenLine
 		tok.Column = tokenColumn
+		if l.ch == 0 {
+			// Unterminated, keep the opening quote so the parser can tell.
+			tok.Type = ILLEGAL
+			tok.Literal = `"` + tok.Literal
+		}
 	case 0:
 		tok.Type = EOF
 		tok.Literal = ""
@@ -188,25 +274,59 @@ func (l *Lexer) newTokenAt(t TokenType, ch string, line, column int) Token {
 
 func (l *Lexer) skipWhitespaceAndComments() {
 	for {
-		switch l.ch {
-		case ' ', '\t', '\n', '\r':
-			l.readChar()
-		case '/':
-			if l.peekChar() == '/' {
-				// single-line comment
-				l.readChar() // consume second '/'
-				for l.ch != '\n' && l.ch != 0 {
-					l.readChar()
-				}
-			} else {
-				return
-			}
+		switch {
+		case isSpace(l.ch):
+			l.readWhitespace()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
 		default:
 			return
 		}
 	}
 }
 
+// readWhitespace reads a run of whitespace. A blank line ends the doc
+// comment being collected.
+func (l *Lexer) readWhitespace() string {
+	position := l.position
+	for isSpace(l.ch) {
+		l.readChar()
+	}
+	text := l.input[position:l.position]
+	if strings.Count(text, "\n") > 1 {
+		l.docLines = nil
+	}
+	return text
+}
+
+// readComment reads a line comment up to the end of its line. A /// comment
+// adds a line to the doc comment being collected and any other comment ends
+// it.
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	text := l.input[position:min(l.position, len(l.input))]
+	if line, ok := docLine(text); ok {
+		l.docLines = append(l.docLines, line)
+	} else {
+		l.docLines = nil
+	}
+	return text
+}
+
+// docLine returns the text of a /// comment. A comment that starts with four
+// or more slashes is not a doc comment, so that a line of slashes drawn to
+// divide a file is not taken for one.
+func docLine(comment string) (string, bool) {
+	if !strings.HasPrefix(comment, "///") || strings.HasPrefix(comment, "////") {
+		return "", false
+	}
+	line := strings.TrimPrefix(comment[3:], " ")
+	return strings.TrimRight(line, " \t\r"), true
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) || isDigit(l.ch) {
@@ -242,6 +362,10 @@ func (l *Lexer) readString() string {
 	return l.input[position:l.position]
 }
 
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
 func isLetter(ch byte) bool {
 	return unicode.IsLetter(rune(ch)) || ch == '_'
 }
@@ -139,3 +139,131 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenSpans(t *testing.T) {
+	input := "let s = \"a\nb\";\n  x >= 10"
+
+	tests := []struct {
+		expectedType TokenType
+		expectedSpan Span
+	}{
+		{LET, Span{Position{0, 1, 1}, Position{3, 1, 4}}},
+		{IDENT, Span{Position{4, 1, 5}, Position{5, 1, 6}}},
+		{ASSIGN, Span{Position{6, 1, 7}, Position{7, 1, 8}}},
+		{STRING, Span{Position{8, 1, 9}, Position{13, 2, 3}}},
+		{SEMICOLON, Span{Position{13, 2, 3}, Position{14, 2, 4}}},
+		{IDENT, Span{Position{17, 3, 3}, Position{18, 3, 4}}},
+		{GREATER_THAN_OR_EQUAL, Span{Position{19, 3, 5}, Position{21, 3, 7}}},
+		{NUMBER, Span{Position{22, 3, 8}, Position{24, 3, 10}}},
+		{EOF, Span{Position{24, 3, 10}, Position{24, 3, 10}}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - wrong token type. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Span != tt.expectedSpan {
+			t.Errorf("tests[%d] - wrong span for %s. expected=%+v, got=%+v", i, tok.Type, tt.expectedSpan, tok.Span)
+		}
+		if tok.Line != tok.Span.Start.Line || tok.Column != tok.Span.Start.Column {
+			t.Errorf("tests[%d] - Line/Column %d:%d disagree with span start %+v", i, tok.Line, tok.Column, tok.Span.Start)
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := New(`let s = "abc`)
+	for i := 0; i < 3; i++ {
+		l.NextToken()
+	}
+
+	tok := l.NextToken()
+	if tok.Type != ILLEGAL || tok.Literal != `"abc` {
+		t.Fatalf("expected ILLEGAL \"abc, got %s %q", tok.Type, tok.Literal)
+	}
+
+	eof := l.NextToken()
+	if eof.Type != EOF || eof.Column != 13 || l.NextToken().Column != 13 {
+		t.Errorf("expected EOF to stay at column 13, got %s at %d", eof.Type, eof.Column)
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "let x = 1; // one\n\n\t// two\nx"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+		expectedSpan    Span
+	}{
+		{LET, "let", Span{Position{0, 1, 1}, Position{3, 1, 4}}},
+		{WHITESPACE, " ", Span{Position{3, 1, 4}, Position{4, 1, 5}}},
+		{IDENT, "x", Span{Position{4, 1, 5}, Position{5, 1, 6}}},
+		{WHITESPACE, " ", Span{Position{5, 1, 6}, Position{6, 1, 7}}},
+		{ASSIGN, "=", Span{Position{6, 1, 7}, Position{7, 1, 8}}},
+		{WHITESPACE, " ", Span{Position{7, 1, 8}, Position{8, 1, 9}}},
+		{NUMBER, "1", Span{Position{8, 1, 9}, Position{9, 1, 10}}},
+		{SEMICOLON, ";", Span{Position{9, 1, 10}, Position{10, 1, 11}}},
+		{WHITESPACE, " ", Span{Position{10, 1, 11}, Position{11, 1, 12}}},
+		{COMMENT, "// one", Span{Position{11, 1, 12}, Position{17, 1, 18}}},
+		{WHITESPACE, "\n\n\t", Span{Position{17, 1, 18}, Position{20, 3, 2}}},
+		{COMMENT, "// two", Span{Position{20, 3, 2}, Position{26, 3, 8}}},
+		{WHITESPACE, "\n", Span{Position{26, 3, 8}, Position{27, 4, 1}}},
+		{IDENT, "x", Span{Position{27, 4, 1}, Position{28, 4, 2}}},
+		{EOF, "", Span{Position{28, 4, 2}, Position{28, 4, 2}}},
+	}
+
+	l := NewWithTrivia(input)
+	var text string
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - expected %s %q, got %s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Span != tt.expectedSpan {
+			t.Errorf("tests[%d] - wrong span for %s. expected=%+v, got=%+v", i, tok.Type, tt.expectedSpan, tok.Span)
+		}
+		if tok.IsTrivia() != (tok.Type == WHITESPACE || tok.Type == COMMENT) {
+			t.Errorf("tests[%d] - IsTrivia is %v for %s", i, tok.IsTrivia(), tok.Type)
+		}
+		text += input[tok.Span.Start.Offset:tok.Span.End.Offset]
+	}
+	if text != input {
+		t.Errorf("the tokens cover %q, want %q", text, input)
+	}
+}
+
+func TestDocComment(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"/// Adds one.\nlet", "Adds one."},
+		{"///   Indented.  \n///\n/// Second paragraph.\nlet", "  Indented.\n\nSecond paragraph."},
+		{"/// Separated.\n\nlet", ""},
+		{"/// Interrupted.\n// plain\nlet", ""},
+		{"// plain\n/// After.\nlet", "After."},
+		{"//// Divider\nlet", ""},
+		{"let", ""},
+	}
+
+	for _, tt := range tests {
+		for _, l := range []*Lexer{New(tt.input), NewWithTrivia(tt.input)} {
+			tok := l.NextToken()
+			for tok.IsTrivia() {
+				tok = l.NextToken()
+			}
+			if tok.Type != LET {
+				t.Fatalf("%q: expected LET, got %s", tt.input, tok.Type)
+			}
+			if got := l.DocComment(); got != tt.want {
+				t.Errorf("%q: got doc comment %q, want %q", tt.input, got, tt.want)
+			}
+			if l.NextToken(); l.DocComment() != "" {
+				t.Errorf("%q: the doc comment carried over to EOF", tt.input)
+			}
+		}
+	}
+}
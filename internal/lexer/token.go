@@ -7,6 +7,10 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	// Trivia, returned only by a lexer made with NewWithTrivia
+	WHITESPACE = "WHITESPACE"
+	COMMENT    = "COMMENT"
+
 	// Identifiers and Literals
 	IDENT  = "IDENT"
 	NUMBER = "NUMBER"
@@ -21,6 +25,10 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 
+	// TEST is not a keyword: "test" lexes as an IDENT, which the parser
+	// retypes as TEST where it starts a test declaration.
+	TEST = "TEST"
+
 	// Single-char Operators
 	ASSIGN = "ASSIGN"
 	PLUS   = "PLUS"
@@ -55,6 +63,27 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+	Span    Span // the source text the token was read from
+}
+
+// IsTrivia reports whether the token is whitespace or a comment.
+func (tok Token) IsTrivia() bool {
+	return tok.Type == WHITESPACE || tok.Type == COMMENT
+}
+
+// Position is a location in the source. Offset counts bytes from the start of
+// the input, Line and Column count from 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the half-open range of source between Start and End. End is the
+// position just past the last byte covered.
+type Span struct {
+	Start Position
+	End   Position
 }
 
 var keywords = map[string]TokenType{
@@ -17,6 +17,7 @@ func TestLookupIdent(t *testing.T) {
 		{"false", FALSE},
 		{"if", IF},
 		{"else", ELSE},
+		{"test", IDENT},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,282 @@
+package lsp
+
+import (
+	"sigil/internal/ast"
+	"sigil/internal/compiler"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/internal/typechecker"
+	"sort"
+)
+
+// document is an open file together with what checking it found out.
+type document struct {
+	uri         string
+	version     int
+	lines       *lineIndex
+	program     *ast.Program
+	result      *typechecker.Result // nil when the document does not parse
+	diagnostics []Diagnostic
+}
+
+// analyze compiles a document the way the command line does, so it only type
+// checks a document that parses.
+func analyze(uri string, version int, text string) *document {
+	doc := &document{uri: uri, version: version, lines: newLineIndex(text), diagnostics: []Diagnostic{}}
+
+	compiled := compiler.Compile(text)
+	doc.program, doc.result = compiled.Program, compiled.Checked
+	for _, err := range compiled.Errors() {
+		doc.report(err.Diagnostic())
+	}
+	if doc.result != nil {
+		for _, w := range doc.result.Warnings {
+			doc.report(w.Diagnostic())
+		}
+	}
+	return doc
+}
+
+func (doc *document) report(d *diagnostic.Diagnostic) {
+	out := Diagnostic{
+		Range:    doc.lines.rangeOf(d.Span),
+		Severity: severityError,
+		Code:     d.Code,
+		Source:   "sigil",
+		Message:  d.Message,
+	}
+	if d.Severity == diagnostic.Warning {
+		out.Severity = severityWarning
+	}
+	if d.Label != "" {
+		out.Message += ": " + d.Label
+	}
+	for _, note := range d.Notes {
+		out.Message += "\nnote: " + note
+	}
+	switch d.Code {
+	case diagnostic.CodeUnusedVariable, diagnostic.CodeUnreachableCode:
+		out.Tags = []int{tagUnnecessary}
+	}
+	for _, label := range d.Related {
+		out.RelatedInformation = append(out.RelatedInformation, DiagnosticRelatedInformation{
+			Location: Location{URI: doc.uri, Range: doc.lines.rangeOf(label.Span)},
+			Message:  label.Message,
+		})
+	}
+	doc.diagnostics = append(doc.diagnostics, out)
+}
+
+// covers reports whether offset falls within span. The end is included so
+// that a cursor just past an identifier still finds it.
+func covers(span lexer.Span, offset int) bool {
+	return span.Start.Offset <= offset && offset <= span.End.Offset
+}
+
+func size(span lexer.Span) int {
+	return span.End.Offset - span.Start.Offset
+}
+
+// nodeAt returns the innermost expression or type annotation at offset that
+// has a type, preferring identifiers over other nodes of the same size. The
+// tree is walked in source order, so when the offset is where one node ends
+// and the next begins, the first of them wins.
+func (doc *document) nodeAt(offset int) ast.Node {
+	if doc.result == nil {
+		return nil
+	}
+
+	var best ast.Node
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		if node == nil || !covers(node.Span(), offset) {
+			return false
+		}
+		switch node.(type) {
+		case ast.Expression, ast.Type:
+		default:
+			return true
+		}
+		if _, ok := doc.result.Types[node]; !ok {
+			return true
+		}
+		if best == nil || size(node.Span()) < size(best.Span()) {
+			best = node
+			return true
+		}
+		_, isIdent := node.(*ast.Identifier)
+		_, bestIsIdent := best.(*ast.Identifier)
+		if isIdent && !bestIsIdent && size(node.Span()) == size(best.Span()) {
+			best = node
+		}
+		return true
+	})
+	return best
+}
+
+// identifierAt returns the first resolved identifier at offset in source
+// order.
+func (doc *document) identifierAt(offset int) *ast.Identifier {
+	if doc.result == nil {
+		return nil
+	}
+
+	var found *ast.Identifier
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		if found != nil || node == nil || !covers(node.Span(), offset) {
+			return false
+		}
+		if ident, ok := node.(*ast.Identifier); ok && doc.result.SymbolOf(ident) != nil {
+			found = ident
+		}
+		return found == nil
+	})
+	return found
+}
+
+func (doc *document) hover(offset int) *Hover {
+	node := doc.nodeAt(offset)
+	if node == nil {
+		return nil
+	}
+
+	text := doc.result.TypeOf(node).String()
+	var comment string
+	if ident, ok := node.(*ast.Identifier); ok {
+		text = ident.Value + ": " + text
+		if sym := doc.result.SymbolOf(ident); sym != nil {
+			comment = doc.docComment(doc.program, sym.Span)
+		}
+	}
+	value := "```sigil\n" + text + "\n```"
+	if comment != "" {
+		value += "\n\n" + comment
+	}
+	r := doc.lines.rangeOf(node.Span())
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    &r,
+	}
+}
+
+// docComment returns the doc comment of the let under node that declares
+// the name at span.
+func (doc *document) docComment(node ast.Node, span lexer.Span) string {
+	if let, ok := node.(*ast.LetStatement); ok && let.Name.Span() == span {
+		return let.Doc
+	}
+	for _, child := range ast.Children(node) {
+		if covers(child.Span(), span.Start.Offset) {
+			return doc.docComment(child, span)
+		}
+	}
+	return ""
+}
+
+// definition returns where the identifier at offset was declared. Builtins
+// are declared nowhere in the document.
+func (doc *document) definition(offset int) *Location {
+	ident := doc.identifierAt(offset)
+	if ident == nil {
+		return nil
+	}
+	sym := doc.result.SymbolOf(ident)
+	if sym.Line == 0 {
+		return nil
+	}
+	return &Location{URI: doc.uri, Range: doc.lines.rangeOf(sym.Span)}
+}
+
+// symbols lists the let bindings of the document. The lets of a function
+// body are children of the let that binds the function.
+func (doc *document) symbols() []DocumentSymbol {
+	return doc.letsIn(doc.program)
+}
+
+func (doc *document) letsIn(node ast.Node) []DocumentSymbol {
+	out := []DocumentSymbol{}
+	for _, child := range ast.Children(node) {
+		let, ok := child.(*ast.LetStatement)
+		if !ok {
+			out = append(out, doc.letsIn(child)...)
+			continue
+		}
+
+		sym := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           symbolVariable,
+			Range:          doc.lines.rangeOf(let.Span()),
+			SelectionRange: doc.lines.rangeOf(let.Name.Span()),
+			Children:       doc.letsIn(let),
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			sym.Kind = symbolFunction
+		}
+		if doc.result != nil {
+			if s := doc.result.SymbolOf(let.Name); s != nil {
+				sym.Detail = s.Type.String()
+			}
+		}
+		out = append(out, sym)
+	}
+	return out
+}
+
+// completions lists the names visible at offset and the builtins, sorted by
+// name. A document that does not parse offers only the builtins.
+func (doc *document) completions(offset int) []CompletionItem {
+	visible := map[string]typechecker.Type{}
+
+	builtins := typechecker.NewEnvironment()
+	for _, name := range builtins.Names() {
+		sym, _ := builtins.Get(name)
+		visible[name] = sym.Type
+	}
+	if doc.result != nil {
+		doc.collectVisible(doc.program, offset, visible)
+	}
+
+	items := []CompletionItem{}
+	for name, typ := range visible {
+		item := CompletionItem{Label: name, Kind: completionVariable}
+		if typ != nil {
+			item.Detail = typ.String()
+			if _, ok := typ.(*typechecker.FunctionType); ok {
+				item.Kind = completionFunction
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// collectVisible adds the bindings of node that are in scope at offset: the
+// lets declared before it in the program or in an enclosing function, and
+// the parameters of the enclosing functions. Blocks do not open scopes, so
+// only function bodies limit where a binding is seen. Inner bindings are
+// visited later and replace the ones they shadow.
+func (doc *document) collectVisible(node ast.Node, offset int, visible map[string]typechecker.Type) {
+	switch n := node.(type) {
+	case *ast.FunctionLiteral:
+		if !covers(n.Body.Span(), offset) {
+			return
+		}
+		for _, param := range n.Parameters {
+			visible[param.Name.Value] = doc.result.TypeOf(param.Name)
+		}
+
+	case *ast.LetStatement:
+		// A function can see its own name; any other value cannot.
+		_, isFunction := n.Value.(*ast.FunctionLiteral)
+		if n.Name.Span().End.Offset <= offset && (isFunction || n.Span().End.Offset <= offset) {
+			visible[n.Name.Value] = doc.result.TypeOf(n.Name)
+		}
+	}
+
+	for _, child := range ast.Children(node) {
+		if child.Span().Start.Offset > offset {
+			break
+		}
+		doc.collectVisible(child, offset, visible)
+	}
+}
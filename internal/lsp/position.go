@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"sigil/internal/lexer"
+	"sort"
+	"unicode/utf8"
+)
+
+// lineIndex converts between byte offsets into a document and LSP
+// positions, whose characters count UTF-16 code units.
+type lineIndex struct {
+	text  string
+	lines []int // offset at which each line starts
+}
+
+func newLineIndex(text string) *lineIndex {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &lineIndex{text: text, lines: lines}
+}
+
+// position returns the position of a byte offset.
+func (li *lineIndex) position(offset int) Position {
+	offset = max(0, min(offset, len(li.text)))
+	line := sort.SearchInts(li.lines, offset+1) - 1
+
+	character := 0
+	for _, r := range li.text[li.lines[line]:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset returns the byte offset of a position. Positions past the end of a
+// line are clamped to it.
+func (li *lineIndex) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(li.lines) {
+		return len(li.text)
+	}
+
+	offset := li.lines[pos.Line]
+	for character := 0; offset < len(li.text) && character < pos.Character; {
+		r, size := utf8.DecodeRuneInString(li.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func (li *lineIndex) rangeOf(span lexer.Span) Range {
+	return Range{Start: li.position(span.Start.Offset), End: li.position(span.End.Offset)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,224 @@
+// Package lsp implements a Language Server Protocol server for Sigil. It
+// reports diagnostics as documents change and answers hover, definition,
+// document symbol and completion requests from the type checker's results.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrExitWithoutShutdown is returned by Serve when the client sends exit
+// without asking the server to shut down first.
+var ErrExitWithoutShutdown = errors.New("exit received before shutdown")
+
+// Server answers the requests of one client.
+type Server struct {
+	conn        *conn
+	log         io.Writer
+	version     string
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer creates a server that reads messages from in and writes replies
+// to out. Problems with the connection itself are logged to log.
+func NewServer(in io.Reader, out io.Writer, log io.Writer, version string) *Server {
+	return &Server{
+		conn:      newConn(in, out),
+		log:       log,
+		version:   version,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles messages until the client sends exit or the input ends.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		var rerr *responseError
+		if errors.As(err, &rerr) {
+			// The body was not JSON, so the ID of the request it held is
+			// unknown and the error is reported with a null one.
+			null := json.RawMessage("null")
+			if err := s.conn.reply(&null, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	switch {
+	case msg.Method == "":
+		return nil // a response to a request of ours; the server sends none
+	case msg.ID == nil:
+		s.notification(msg.Method, msg.Params)
+		return nil
+	}
+
+	result, rerr := s.request(msg.Method, msg.Params)
+	return s.conn.reply(msg.ID, result, rerr)
+}
+
+func (s *Server) request(method string, params json.RawMessage) (any, *responseError) {
+	if method == "initialize" {
+		s.initialized = true
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       syncFull,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+				CompletionProvider:     &CompletionOptions{},
+			},
+			ServerInfo: ServerInfo{Name: "sigil", Version: s.version},
+		}, nil
+	}
+	if !s.initialized {
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/hover":
+		doc, offset, rerr := s.position(params)
+		if doc == nil {
+			return nil, rerr
+		}
+		if h := doc.hover(offset); h != nil {
+			return h, nil
+		}
+		return nil, nil
+
+	case "textDocument/definition":
+		doc, offset, rerr := s.position(params)
+		if doc == nil {
+			return nil, rerr
+		}
+		if loc := doc.definition(offset); loc != nil {
+			return loc, nil
+		}
+		return nil, nil
+
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if rerr := decode(params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, ok := s.documents[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.symbols(), nil
+
+	case "textDocument/completion":
+		doc, offset, rerr := s.position(params)
+		if doc == nil {
+			return nil, rerr
+		}
+		return doc.completions(offset), nil
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+}
+
+// position decodes the parameters of a request about a place in a document.
+// It returns a nil document if the document is not open.
+func (s *Server) position(params json.RawMessage) (*document, int, *responseError) {
+	var p TextDocumentPositionParams
+	if rerr := decode(params, &p); rerr != nil {
+		return nil, 0, rerr
+	}
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, 0, nil
+	}
+	return doc, doc.lines.offset(p.Position), nil
+}
+
+func (s *Server) notification(method string, params json.RawMessage) {
+	if !s.initialized {
+		return
+	}
+
+	switch method {
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if s.decode(method, params, &p) {
+			s.update(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+		}
+
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if s.decode(method, params, &p) && len(p.ContentChanges) > 0 {
+			// With full synchronization the last change holds the whole text.
+			text := p.ContentChanges[len(p.ContentChanges)-1].Text
+			s.update(p.TextDocument.URI, p.TextDocument.Version, text)
+		}
+
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if s.decode(method, params, &p) {
+			delete(s.documents, p.TextDocument.URI)
+			s.publish(&PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	}
+}
+
+// update checks the new text of a document and publishes its diagnostics.
+func (s *Server) update(uri string, version int, text string) {
+	doc := analyze(uri, version, text)
+	s.documents[uri] = doc
+	s.publish(&PublishDiagnosticsParams{URI: uri, Version: &doc.version, Diagnostics: doc.diagnostics})
+}
+
+func (s *Server) publish(params *PublishDiagnosticsParams) {
+	if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+		fmt.Fprintf(s.log, "sigil lsp: publishing diagnostics: %s\n", err)
+	}
+}
+
+// decode unpacks the parameters of a notification, logging them if they are
+// malformed since a notification cannot be answered.
+func (s *Server) decode(method string, params json.RawMessage, v any) bool {
+	if rerr := decode(params, v); rerr != nil {
+		fmt.Fprintf(s.log, "sigil lsp: %s: %s\n", method, rerr.Message)
+		return false
+	}
+	return true
+}
+
+func decode(params json.RawMessage, v any) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a server running in the same process over a pair of pipes,
+// speaking the same framing an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	done   chan error
+
+	notifications []*message // received while waiting for a response
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	server := NewServer(serverIn, serverOut, io.Discard, "test")
+
+	c := &client{t: t, conn: newConn(clientIn, clientOut), done: make(chan error, 1)}
+	go func() {
+		err := server.Serve()
+		serverOut.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		clientIn.Close()
+	})
+	return c
+}
+
+// initialize performs the handshake every session starts with.
+func (c *client) initialize() *InitializeResult {
+	c.t.Helper()
+	result := &InitializeResult{}
+	c.call("initialize", map[string]any{"processId": nil, "capabilities": map[string]any{}}, result)
+	c.notify("initialized", map[string]any{})
+	return result
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *client) call(method string, params any, result any) *responseError {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: data}); err != nil {
+		c.t.Fatalf("sending %s: %v", method, err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.Method != "" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: got a response to request %s", method, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: decoding %s: %v", method, msg.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("sending %s: %v", method, err)
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+
+	type read struct {
+		msg *message
+		err error
+	}
+	ch := make(chan read, 1)
+	go func() {
+		msg, err := c.conn.read()
+		ch <- read{msg, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			c.t.Fatalf("reading from server: %v", r.err)
+		}
+		return r.msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+		return nil
+	}
+}
+
+// diagnostics waits for the next diagnostics published for uri.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+
+	for {
+		var msg *message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri, text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "sigil", Version: 1, Text: text},
+	})
+	return c.diagnostics(uri)
+}
+
+// at returns the position of the n-th occurrence (from 1) of needle in text,
+// plus offset bytes.
+func at(t *testing.T, text, needle string, n, offset int) Position {
+	t.Helper()
+	start := 0
+	for i := 0; i < n; i++ {
+		idx := strings.Index(text[start:], needle)
+		if idx < 0 {
+			t.Fatalf("%q occurs fewer than %d times", needle, n)
+		}
+		start += idx
+		if i < n-1 {
+			start += len(needle)
+		}
+	}
+	return newLineIndex(text).position(start + offset)
+}
+
+func positionParams(uri string, pos Position) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: pos}
+}
+
+const program = `let limit: Number = 10;
+let scale = fun(x: Number, by: Number): Number {
+    let scaled = x * by;
+    scaled
+};
+let big = scale(limit, 2) > 15;
+println(string(big));
+`
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+
+	if err := c.call("textDocument/hover", positionParams("file:///a.sgl", Position{}), nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("expected requests before initialize to fail, got %v", err)
+	}
+
+	caps := c.initialize().Capabilities
+	if caps.TextDocumentSync != syncFull || !caps.HoverProvider || !caps.DefinitionProvider ||
+		!caps.DocumentSymbolProvider || caps.CompletionProvider == nil {
+		t.Errorf("unexpected capabilities %+v", caps)
+	}
+
+	if err := c.call("workspace/symbol", map[string]any{"query": ""}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected an unknown method to fail with %d, got %v", codeMethodNotFound, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///diag.sgl"
+
+	if diags := c.open(uri, program); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", diags)
+	}
+
+	change := func(version int, text string) []Diagnostic {
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: version},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		return c.diagnostics(uri)
+	}
+
+	text := "let x = 1;\nlet y = x + true;\ny"
+	diags := change(2, text)
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got %+v", diags)
+	}
+	d := diags[0]
+	want := Range{Start: Position{Line: 1, Character: 8}, End: Position{Line: 1, Character: 16}}
+	if d.Code != "E0203" || d.Severity != severityError || d.Source != "sigil" || d.Range != want {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	diags = change(3, "let x = ;")
+	if len(diags) == 0 || diags[0].Code != "E0102" {
+		t.Errorf("expected a parse error, got %+v", diags)
+	}
+
+	diags = change(4, "let f = fun(unused: Number): Number { 1 };\nf(1)")
+	if len(diags) != 1 || diags[0].Severity != severityWarning || diags[0].Code != "W0001" ||
+		len(diags[0].Tags) != 1 || diags[0].Tags[0] != tagUnnecessary {
+		t.Errorf("expected an unused parameter warning, got %+v", diags)
+	}
+	if !strings.Contains(diags[0].Message, "note: if this is intentional") {
+		t.Errorf("expected the note in the message, got %q", diags[0].Message)
+	}
+
+	diags = change(5, "let a = 1;\nlet g = fun(): Number { let a = 2; a };\ng()")
+	if len(diags) != 1 || len(diags[0].RelatedInformation) != 1 ||
+		diags[0].RelatedInformation[0].Location.Range.Start != (Position{Line: 0, Character: 4}) {
+		t.Errorf("expected a shadowing warning pointing at the outer a, got %+v", diags)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("expected closing to clear diagnostics, got %+v", diags)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///hover.sgl"
+	c.open(uri, program)
+
+	tests := []struct {
+		pos  Position
+		want string
+	}{
+		{at(t, program, "limit", 2, 2), "limit: Number"},
+		{at(t, program, "scale", 1, 0), "scale: (Number, Number) -> Number"},
+		{at(t, program, "scaled", 2, 0), "scaled: Number"},
+		{at(t, program, "by", 2, 1), "by: Number"},
+		{at(t, program, "x * by", 1, 1), "x: Number"}, // just past x
+		{at(t, program, "* by", 1, 0), "Number"},
+		{at(t, program, "> 15", 1, 0), "Boolean"},
+		{at(t, program, "println", 1, 3), "println: (String) -> Void"},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		c.call("textDocument/hover", positionParams(uri, tt.pos), &hover)
+		if hover == nil {
+			t.Errorf("%+v: no hover", tt.pos)
+			continue
+		}
+		if want := "```sigil\n" + tt.want + "\n```"; hover.Contents.Value != want {
+			t.Errorf("%+v: got %q, want %q", tt.pos, hover.Contents.Value, want)
+		}
+	}
+
+	var hover *Hover
+	c.call("textDocument/hover", positionParams(uri, Position{Line: 7, Character: 0}), &hover)
+	if hover != nil {
+		t.Errorf("expected no hover past the end, got %+v", hover)
+	}
+}
+
+func TestHoverDocComment(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///doc.sgl"
+	text := "/// Doubles n.\nlet double = fun(n: Number): Number {\n    /// Twice n.\n    let twice = n * 2;\n    twice\n};\ndouble(1);\n"
+	c.open(uri, text)
+
+	tests := []struct {
+		pos  Position
+		want string
+	}{
+		{at(t, text, "double", 2, 0), "```sigil\ndouble: (Number) -> Number\n```\n\nDoubles n."},
+		{at(t, text, "twice", 2, 0), "```sigil\ntwice: Number\n```\n\nTwice n."},
+		{at(t, text, "n * 2", 1, 0), "```sigil\nn: Number\n```"},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		c.call("textDocument/hover", positionParams(uri, tt.pos), &hover)
+		if hover == nil || hover.Contents.Value != tt.want {
+			t.Errorf("%+v: got %+v, want %q", tt.pos, hover, tt.want)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///def.sgl"
+	c.open(uri, program)
+
+	tests := []struct {
+		pos  Position
+		want Position // start of the declaration
+	}{
+		{at(t, program, "limit", 2, 1), at(t, program, "limit", 1, 0)},
+		{at(t, program, "scale(", 1, 0), at(t, program, "scale", 1, 0)},
+		{at(t, program, "scaled", 2, 3), at(t, program, "scaled", 1, 0)},
+		{at(t, program, "by;", 1, 0), at(t, program, "by", 1, 0)},
+		{at(t, program, "big", 2, 3), at(t, program, "big", 1, 0)},
+		{at(t, program, "big)", 1, 3), at(t, program, "big", 1, 0)},
+	}
+
+	for _, tt := range tests {
+		var loc *Location
+		c.call("textDocument/definition", positionParams(uri, tt.pos), &loc)
+		if loc == nil {
+			t.Errorf("%+v: no definition", tt.pos)
+			continue
+		}
+		if loc.URI != uri || loc.Range.Start != tt.want {
+			t.Errorf("%+v: got %+v, want a location starting at %+v", tt.pos, loc, tt.want)
+		}
+	}
+
+	var loc *Location
+	c.call("textDocument/definition", positionParams(uri, at(t, program, "string", 1, 0)), &loc)
+	if loc != nil {
+		t.Errorf("expected builtins to have no definition, got %+v", loc)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///symbols.sgl"
+	c.open(uri, program)
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+
+	var describe func([]DocumentSymbol) string
+	describe = func(symbols []DocumentSymbol) string {
+		parts := []string{}
+		for _, s := range symbols {
+			part := fmt.Sprintf("%s(%d %s)", s.Name, s.Kind, s.Detail)
+			if len(s.Children) > 0 {
+				part += "{" + describe(s.Children) + "}"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	want := "limit(13 Number) scale(12 (Number, Number) -> Number){scaled(13 Number)} big(13 Boolean)"
+	if got := describe(symbols); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if len(symbols) > 0 && symbols[0].SelectionRange != (Range{Start: Position{0, 4}, End: Position{0, 9}}) {
+		t.Errorf("unexpected selection range %+v", symbols[0].SelectionRange)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///complete.sgl"
+	c.open(uri, program)
+
+	labels := func(pos Position) map[string]CompletionItem {
+		var items []CompletionItem
+		c.call("textDocument/completion", positionParams(uri, pos), &items)
+		out := map[string]CompletionItem{}
+		for _, item := range items {
+			out[item.Label] = item
+		}
+		return out
+	}
+
+	inside := labels(at(t, program, "scaled\n", 1, 0))
+	for _, name := range []string{"limit", "scale", "x", "by", "scaled", "println", "len"} {
+		if _, ok := inside[name]; !ok {
+			t.Errorf("expected %s to be offered inside scale, got %v", name, inside)
+		}
+	}
+	if _, ok := inside["big"]; ok {
+		t.Error("big is declared after scale and should not be offered inside it")
+	}
+	if item := inside["scale"]; item.Kind != completionFunction || item.Detail != "(Number, Number) -> Number" {
+		t.Errorf("unexpected item for scale: %+v", item)
+	}
+	if item := inside["x"]; item.Kind != completionVariable || item.Detail != "Number" {
+		t.Errorf("unexpected item for x: %+v", item)
+	}
+
+	outside := labels(at(t, program, "println", 1, 0))
+	for _, name := range []string{"x", "by", "scaled"} {
+		if _, ok := outside[name]; ok {
+			t.Errorf("%s is local to scale and should not be offered outside it", name)
+		}
+	}
+	if _, ok := outside["big"]; !ok {
+		t.Error("expected big to be offered after its let")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := c.call("textDocument/hover", positionParams("file:///a.sgl", Position{}), nil); err == nil {
+		t.Error("expected requests after shutdown to fail")
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("expected a clean exit, got %v", err)
+	}
+
+	c = newClient(t)
+	c.initialize()
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected %v, got %v", ErrExitWithoutShutdown, err)
+	}
+}
+
+func TestLineIndex(t *testing.T) {
+	text := "ab\n\"é😀\" + x\n"
+	li := newLineIndex(text)
+
+	x := strings.Index(text, "x")
+	if got := li.position(x); got != (Position{Line: 1, Character: 8}) {
+		t.Errorf("position of x: got %+v", got)
+	}
+	if got := li.offset(Position{Line: 1, Character: 8}); got != x {
+		t.Errorf("offset of x: got %d, want %d", got, x)
+	}
+	if got := li.offset(Position{Line: 0, Character: 99}); got != 2 {
+		t.Errorf("expected positions past the end of a line to clamp, got %d", got)
+	}
+	if got := li.position(len(text)); got != (Position{Line: 2, Character: 0}) {
+		t.Errorf("position of the end: got %+v", got)
+	}
+}
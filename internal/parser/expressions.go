@@ -7,12 +7,18 @@ import (
 
 // Pratt Parsing Core
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.trace("parseExpression")()
+
+	start := p.curToken.Span.Start
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
 	leftExp := prefix()
+	if leftExp == nil {
+		return nil
+	}
 
 	for !p.peekTokenIs(lexer.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -22,23 +28,33 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 		p.nextToken()
 
+		p.operandStart = start
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return nil
+		}
 	}
 
 	return leftExp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	defer p.trace("parseIdentifier")()
+
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Range: p.curToken.Span}
 	if p.peekTokenIs(lexer.ASSIGN) {
 		assignToken := p.peekToken
 		p.nextToken() // consume ASSIGN
 		p.nextToken() // move to expression
 		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
 		return &ast.AssignmentExpression{
 			Token: assignToken,
 			Name:  ident,
 			Value: value,
+			Range: p.spanFrom(ident.Range.Start),
 		}
 	}
 
@@ -46,8 +62,13 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.trace("parseGroupedExpression")()
+
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
 	if !p.expectPeek(lexer.RIGHT_PAREN) {
 		return nil
 	}
@@ -55,6 +76,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.trace("parsePrefixExpression")()
+
 	expr := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -62,11 +85,17 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 	p.nextToken()
 	expr.Right = p.parseExpression(PREFIX)
+	if expr.Right == nil {
+		return nil
+	}
+	expr.Range = p.spanFrom(expr.Token.Span.Start)
 
 	return expr
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
+	defer p.trace("parseIfExpression")()
+
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(lexer.LEFT_PAREN) {
@@ -75,6 +104,9 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
+	if expression.Condition == nil {
+		return nil
+	}
 
 	if !p.expectPeek(lexer.RIGHT_PAREN) {
 		return nil
@@ -96,11 +128,15 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		expression.Alternative = p.parseBlockStatement()
 	}
 
+	expression.Range = p.spanFrom(expression.Token.Span.Start)
 	return expression
 }
 
 // Infix functions
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.trace("parseInfixExpression")()
+
+	start := p.operandStart
 	expr := &ast.InfixExpression{
 		Token:    p.curToken,
 		Left:     left,
@@ -110,17 +146,27 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expr.Right = p.parseExpression(precedence)
+	if expr.Right == nil {
+		return nil
+	}
+	expr.Range = p.spanFrom(start)
 
 	return expr
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.trace("parseCallExpression")()
+
+	start := p.operandStart
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
+	exp.Range = p.spanFrom(start)
 	return exp
 }
 
 func (p *Parser) parseCallArguments() []ast.Expression {
+	defer p.trace("parseCallArguments")()
+
 	args := []ast.Expression{}
 	if p.peekTokenIs(lexer.RIGHT_PAREN) {
 		p.nextToken()
@@ -1,37 +1,43 @@
 package parser
 
 import (
-	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
 	"sigil/internal/lexer"
 	"strconv"
 )
 
 func (p *Parser) parseNumberLiteral() ast.Expression {
+	defer p.trace("parseNumberLiteral")()
+
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Error parsing Number: %s", err)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, diagnostic.CodeInvalidNumber, "Error parsing Number: %s", err)
 	}
-	return &ast.NumberLiteral{Token: p.curToken, Value: value}
+	return &ast.NumberLiteral{Token: p.curToken, Value: value, Range: p.curToken.Span}
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	defer p.trace("parseStringLiteral")()
+
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal, Range: p.curToken.Span}
 }
 
 func (p *Parser) parseBooleanLiteral() ast.Expression {
-	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(lexer.TRUE)}
+	defer p.trace("parseBooleanLiteral")()
+
+	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(lexer.TRUE), Range: p.curToken.Span}
 }
 
 func (p *Parser) parseType() ast.Type {
+	defer p.trace("parseType")()
+
 	switch p.curToken.Type {
 	case lexer.IDENT:
-		t := &ast.SimpleType{Token: p.curToken, Name: p.curToken.Literal}
-		// Remove this line: p.nextToken()
-		return t
+		return &ast.SimpleType{Token: p.curToken, Name: p.curToken.Literal, Range: p.curToken.Span}
 
 	case lexer.LEFT_PAREN:
+		start := p.curToken.Span.Start
 		p.nextToken() // consume '('
 		paramTypes := []ast.Type{}
 
@@ -44,14 +50,14 @@ func (p *Parser) parseType() ast.Type {
 		}
 
 		if !p.curTokenIs(lexer.RIGHT_PAREN) {
-			p.errors = append(p.errors, "expected RIGHT_PAREN at end of function type parameter list")
+			p.addError(p.curToken, diagnostic.CodeInvalidType, "expected RIGHT_PAREN at end of function type parameter list")
 			return nil
 		}
 
 		p.nextToken() // consume RIGHT_PAREN
 
 		if !p.curTokenIs(lexer.ARROW) {
-			p.errors = append(p.errors, "expected ARROW after function type parameter list")
+			p.addError(p.curToken, diagnostic.CodeInvalidType, "expected ARROW after function type parameter list")
 			return nil
 		}
 
@@ -65,14 +71,28 @@ func (p *Parser) parseType() ast.Type {
 		return &ast.FunctionType{
 			ParamTypes: paramTypes,
 			ReturnType: returnType,
+			Range:      p.spanFrom(start),
 		}
 	}
 
-	p.errors = append(p.errors, fmt.Sprintf("unexpected token in type: %s", p.curToken.Literal))
+	p.addError(p.curToken, diagnostic.CodeInvalidType, "unexpected token in type: %s", p.curToken.Literal)
 	return nil
 }
 
+// ParseType parses the whole input as a type annotation such as
+// "(Number, String) -> Boolean".
+func (p *Parser) ParseType() ast.Type {
+	t := p.parseType()
+	if t != nil && !p.peekTokenIs(lexer.EOF) {
+		p.addError(p.peekToken, diagnostic.CodeInvalidType, "unexpected token after type: %s", p.peekToken.Literal)
+		return nil
+	}
+	return t
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.trace("parseFunctionLiteral")()
+
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(lexer.LEFT_PAREN) {
@@ -80,9 +100,12 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
 
 	if !p.curTokenIs(lexer.COLON) {
-		p.errors = append(p.errors, "expected ':' before return type")
+		p.addError(p.curToken, diagnostic.CodeInvalidFunction, "expected ':' before return type")
 		return nil
 	}
 	p.nextToken() // move to the return type token
@@ -96,52 +119,50 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	p.nextToken()
 
 	if !p.curTokenIs(lexer.LEFT_BRACE) {
-		p.errors = append(p.errors, fmt.Sprintf("expected '{' after function literal, got %s", p.curToken.Literal))
+		p.addError(p.curToken, diagnostic.CodeInvalidFunction, "expected '{' after function literal, got %s", p.curToken.Literal)
 		return nil
 	}
 
 	lit.Body = p.parseBlockStatement()
+	lit.Range = p.spanFrom(lit.Token.Span.Start)
 	return lit
 }
 
 func (p *Parser) parseFunctionParameters() []*ast.FunctionParameter {
-	fmt.Printf("[parseFunctionParameters] curToken=%s, peekToken=%s\n", p.curToken.Literal, p.peekToken.Literal)
+	defer p.trace("parseFunctionParameters")()
+
 	parameters := []*ast.FunctionParameter{}
 
 	// Empty parameter shortcut: fun()
 	if p.peekTokenIs(lexer.RIGHT_PAREN) {
 		p.nextToken() // move to ')'
 		p.nextToken() // consume ')'
-		fmt.Println("[parseFunctionParameters] empty parameter list")
 		return parameters
 	}
 
 	// Move into first parameter
 	p.nextToken() // advance to first parameter
 	for {
-		fmt.Printf("[parseFunctionParameters] parsing parameter: curToken=%s\n", p.curToken.Literal)
-		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Range: p.curToken.Span}
 
 		if !p.expectPeek(lexer.COLON) {
-			fmt.Printf("[parseFunctionParameters] expected ':', got %s\n", p.peekToken.Literal)
 			return nil
 		}
 
 		p.nextToken() // move to type
 		typeHint := p.parseType()
 		if typeHint == nil {
-			fmt.Printf("[parseFunctionParameters] failed to parse type for %s\n", name.Value)
 			return nil
 		}
 
-		// NEW: Advance past the type token since parseType() no longer does this
-		p.nextToken()
-
 		parameters = append(parameters, &ast.FunctionParameter{
 			Name:     name,
 			TypeHint: typeHint,
+			Range:    p.spanFrom(name.Range.Start),
 		})
-		fmt.Printf("[parseFunctionParameters] added parameter: %s : %+v\n", name.Value, typeHint)
+
+		// NEW: Advance past the type token since parseType() no longer does this
+		p.nextToken()
 
 		// Move to next parameter or finish
 		if p.curTokenIs(lexer.COMMA) {
@@ -154,12 +175,10 @@ func (p *Parser) parseFunctionParameters() []*ast.FunctionParameter {
 		}
 
 		// Syntax error if unexpected token
-		p.errors = append(p.errors, fmt.Sprintf("expected ',' or ')', got %s", p.curToken.Literal))
-		fmt.Printf("[parseFunctionParameters] unexpected token %s\n", p.curToken.Literal)
+		p.addError(p.curToken, diagnostic.CodeInvalidFunction, "expected ',' or ')', got %s", p.curToken.Literal)
 		return nil
 	}
 
 	p.nextToken() // consume RIGHT_PAREN
-	fmt.Println("[parseFunctionParameters] finished parsing parameters")
 	return parameters
 }
@@ -3,7 +3,9 @@ package parser
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
 	"sigil/internal/lexer"
+	"strings"
 )
 
 // Precedences
@@ -39,19 +41,41 @@ type (
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*ParseError
 
 	curToken  lexer.Token
 	peekToken lexer.Token
 
+	// curDoc and peekDoc are the doc comments written before curToken and
+	// peekToken.
+	curDoc  string
+	peekDoc string
+
+	// synced is the number of errors already recovered from by synchronize.
+	synced int
+	// resume is set when recovery stopped on a token that starts the next
+	// statement or closes the enclosing block, which must not be skipped.
+	resume bool
+
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
+
+	// operandStart is where the left operand handed to an infix parse
+	// function began, including any parentheses around it.
+	operandStart lexer.Position
+
+	tracer Tracer // nil unless tracing was requested
+	depth  int    // nesting depth of traced parse functions
 }
 
-func New(l *lexer.Lexer) *Parser {
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	// Read the first two tokens to preload the data in the parser
@@ -87,24 +111,86 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// nextToken advances by one token, passing over whitespace and comments if
+// the lexer returns them.
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curDoc = p.peekDoc
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.IsTrivia() {
+		p.peekToken = p.l.NextToken()
+	}
+	p.peekDoc = p.l.DocComment()
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
+	defer p.trace("ParseProgram")()
+
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
+	start := lexer.Position{Offset: 0, Line: 1, Column: 1}
 
 	for p.curToken.Type != lexer.EOF {
-		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
+		if p.resume {
+			p.resume = false
+			continue
+		}
 		p.nextToken()
 	}
 
+	program.Range = lexer.Span{Start: start, End: p.curToken.Span.End}
 	return program
 }
 
+// synchronize skips tokens after a syntax error until the next statement
+// boundary, so that parsing can resume and report further errors. It stops on
+// a ';' or just before a '}', 'let', 'test' or 'fun' outside of any braces it
+// skipped, leaving the boundary for the caller's next call to nextToken. If
+// the error was found on a '}', 'let' or test declaration other than the one
+// the failed statement started at, that token is kept as the place to resume
+// from.
+func (p *Parser) synchronize(start lexer.Token) {
+	p.synced = len(p.errors)
+
+	if p.curToken != start && (p.curTokenIs(lexer.RIGHT_BRACE) || p.curTokenIs(lexer.LET) || p.atTestDeclaration()) {
+		p.resume = true
+		return
+	}
+
+	depth := 0
+	for !p.curTokenIs(lexer.EOF) {
+		switch p.curToken.Type {
+		case lexer.LEFT_BRACE:
+			depth++
+		case lexer.RIGHT_BRACE:
+			if depth > 0 {
+				depth--
+			}
+		case lexer.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch p.peekToken.Type {
+			case lexer.RIGHT_BRACE, lexer.LET, lexer.FUNCTION, lexer.EOF:
+				return
+			case lexer.IDENT:
+				// The token after it is not known yet, so any test is taken
+				// to start a declaration; parseStatement sorts them out.
+				if p.peekToken.Literal == "test" {
+					return
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
 // Precedence helpers
 func (p *Parser) peekPrecedence() int {
 	if prec, ok := precedences[p.peekToken.Type]; ok {
@@ -130,6 +216,13 @@ func (p *Parser) registerInfix(tokenType lexer.TokenType, fn infixParseFn) {
 }
 
 // Helpers
+
+// spanFrom returns the span from start to the end of the current token, which
+// is the last token of the node being parsed.
+func (p *Parser) spanFrom(start lexer.Position) lexer.Span {
+	return lexer.Span{Start: start, End: p.curToken.Span.End}
+}
+
 func (p *Parser) curTokenIs(t lexer.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -148,17 +241,60 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// addError records a syntax error with the given code at the given token.
+func (p *Parser) addError(tok lexer.Token, code, format string, args ...any) *ParseError {
+	err := &ParseError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Token:   tok,
+	}
+	p.errors = append(p.errors, err)
+	return err
+}
+
 func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, diagnostic.CodeUnexpectedToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) noPrefixParseFnError(t lexer.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == lexer.ILLEGAL {
+		p.illegalTokenError()
+		return
+	}
+	p.addError(p.curToken, diagnostic.CodeExpectedExpression, "no prefix parse function for %s found", t)
+}
+
+// illegalTokenError reports a token the lexer could not make sense of.
+func (p *Parser) illegalTokenError() {
+	if strings.HasPrefix(p.curToken.Literal, `"`) {
+		p.addError(p.curToken, diagnostic.CodeUnterminatedString, "unterminated string literal")
+		return
+	}
+	p.addError(p.curToken, diagnostic.CodeUnexpectedCharacter, "unexpected character %q", p.curToken.Literal)
+}
+
+// ParseError is a syntax error together with the token it was found at.
+type ParseError struct {
+	Code    string // see diagnostic.Explain
+	Message string
+	Token   lexer.Token
+	Related []diagnostic.Label
+}
+
+func (pe *ParseError) Error() string {
+	return fmt.Sprintf("Parse error at line %d, column %d: %s", pe.Token.Line, pe.Token.Column, pe.Message)
+}
+
+func (pe *ParseError) Diagnostic() *diagnostic.Diagnostic {
+	return &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     pe.Code,
+		Message:  pe.Message,
+		Span:     pe.Token.Span,
+		Related:  pe.Related,
+	}
 }
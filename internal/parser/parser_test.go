@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"sigil/internal/ast"
 	"sigil/internal/lexer"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -84,6 +86,21 @@ func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 	return true
 }
 
+func testSimpleType(t *testing.T, typ ast.Type, name string) bool {
+	st, ok := typ.(*ast.SimpleType)
+	if !ok {
+		t.Errorf("type not *ast.SimpleType, got=%T", typ)
+		return false
+	}
+
+	if st.Name != name {
+		t.Errorf("st.Name not %s, got=%s", name, st.Name)
+		return false
+	}
+
+	return true
+}
+
 func testBoolean(t *testing.T, exp ast.Expression, value bool) bool {
 	b, ok := exp.(*ast.BooleanLiteral)
 	if !ok {
@@ -179,6 +196,44 @@ let foobar: Number = 123123;
 	}
 }
 
+func TestDocComments(t *testing.T) {
+	input := `/// Adds two numbers.
+///
+/// Both must be numbers.
+let add = fun(a: Number, b: Number): Number {
+	/// The sum.
+	let sum = a + b;
+	sum
+};
+
+// Not documented.
+let x = 1;
+/// Not attached to an expression.
+add(x, x);
+`
+
+	for _, l := range []*lexer.Lexer{lexer.New(input), lexer.NewWithTrivia(input)} {
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 3 {
+			t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+		}
+		add := program.Statements[0].(*ast.LetStatement)
+		if want := "Adds two numbers.\n\nBoth must be numbers."; add.Doc != want {
+			t.Errorf("add: got doc %q, want %q", add.Doc, want)
+		}
+		sum := add.Value.(*ast.FunctionLiteral).Body.Statements[0].(*ast.LetStatement)
+		if sum.Doc != "The sum." {
+			t.Errorf("sum: got doc %q, want %q", sum.Doc, "The sum.")
+		}
+		if x := program.Statements[1].(*ast.LetStatement); x.Doc != "" {
+			t.Errorf("x: got doc %q, want none", x.Doc)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	input := `
 return 5;
@@ -208,6 +263,62 @@ return 1234;
 	}
 }
 
+func TestTestStatements(t *testing.T) {
+	input := `
+test "adds numbers" {
+    assert_eq(3, 1 + 2);
+}
+test "empty" {}
+`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	tests := []struct {
+		name       string
+		statements int
+	}{
+		{"adds numbers", 1},
+		{"empty", 0},
+	}
+	for i, tt := range tests {
+		stmt, ok := program.Statements[i].(*ast.TestStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.TestStatement. got=%T", program.Statements[i])
+		}
+		if stmt.Name.Value != tt.name {
+			t.Errorf("stmt.Name.Value not %q. got=%q", tt.name, stmt.Name.Value)
+		}
+		if len(stmt.Body.Statements) != tt.statements {
+			t.Errorf("test %q has %d statements, want %d", tt.name, len(stmt.Body.Statements), tt.statements)
+		}
+	}
+}
+
+func TestTestAsName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let test = 1; test + 1", "let test = 1;(test + 1)"},
+		{"test(1); test", "test(1)test"},
+		{`let test = "a"; test "b" { test }`, `let test = a;test "b" { test }`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := `foobar;`
 
@@ -532,10 +643,10 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	}
 
 	testIdentifier(t, function.Parameters[0].Name, "x")
-	testIdentifier(t, function.Parameters[0].TypeHint, "Number")
+	testSimpleType(t, function.Parameters[0].TypeHint, "Number")
 	testIdentifier(t, function.Parameters[1].Name, "y")
-	testIdentifier(t, function.Parameters[1].TypeHint, "Number")
-	testIdentifier(t, function.ReturnType, "Number")
+	testSimpleType(t, function.Parameters[1].TypeHint, "Number")
+	testSimpleType(t, function.ReturnType, "Number")
 
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements does not have %d statements, got=%d", 1, len(function.Body.Statements))
@@ -582,3 +693,215 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, expr.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, expr.Arguments[2], 4, "+", 5)
 }
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5;", "Parse error at line 1, column 5: expected next token to be IDENT, got ASSIGN instead"},
+		{"let x: Number = ;", "Parse error at line 1, column 17: no prefix parse function for SEMICOLON found"},
+		{"add(1,\n  2", "Parse error at line 2, column 4: expected next token to be RIGHT_PAREN, got EOF instead"},
+		{"if (true) { 1", "Parse error at line 1, column 14: expected RIGHT_BRACE to close the block opened at line 1, column 11"},
+		{"test adds {}", "Parse error at line 1, column 11: no prefix parse function for LEFT_BRACE found"},
+		{`test "adds" assert(true)`, "Parse error at line 1, column 13: expected next token to be LEFT_BRACE, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected a parse error", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors int
+		expected       string
+	}{
+		// Every broken statement is reported and the valid ones are kept.
+		{"let = 5; let y = 2; let z: Number = ; z", 2, "let y = 2;z"},
+		{"1 + ; 2 * ; 3", 2, "3"},
+		// Recovery resumes at a let or fun keyword even without a semicolon.
+		{"let x = (1 + \nlet y = 2;", 1, "let y = 2;"},
+		{"let x = (1 + \ntest \"t\" { x }", 1, `test "t" { x }`},
+		// A test that is only a name does not start a declaration.
+		{"let x = (1 + \ntest; test", 1, "test"},
+		// Errors inside a block are recovered there, keeping the enclosing statement.
+		{"let f = fun(): Number { 1 + ; 2 }; f()", 1, "let f = fun(): Number 2;f()"},
+		{"if (true) { 1 + } else { 2 }", 1, "iftrue else 2"},
+		// Braces skipped during recovery are balanced.
+		{"let f = fun(x Number): Number { x }; f(1)", 1, "f(1)"},
+		{"}; 1", 1, "1"},
+		{"1 + }; 2", 1, "2"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("input %q: expected %d errors, got %d: %v", tt.input, tt.expectedErrors, len(p.Errors()), p.Errors())
+		}
+
+		for i, stmt := range program.Statements {
+			if stmt == nil {
+				t.Fatalf("input %q: statement %d is nil", tt.input, i)
+			}
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+type recordingTracer struct {
+	events []string
+	depth  int
+	maxDep int
+}
+
+func (rt *recordingTracer) Enter(event TraceEvent) {
+	rt.events = append(rt.events, fmt.Sprintf("enter %s %d", event.Function, event.Depth))
+	rt.depth++
+	if event.Depth > rt.maxDep {
+		rt.maxDep = event.Depth
+	}
+}
+
+func (rt *recordingTracer) Exit(event TraceEvent) {
+	rt.events = append(rt.events, fmt.Sprintf("exit %s %d", event.Function, event.Depth))
+	rt.depth--
+}
+
+func TestParserTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	p := New(lexer.New("let x: Number = -1;"), WithTracer(tracer))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if tracer.depth != 0 {
+		t.Errorf("enter and exit events are unbalanced, depth=%d", tracer.depth)
+	}
+
+	expected := []string{
+		"enter ParseProgram 0",
+		"enter parseStatement 1",
+		"enter parseLetStatement 2",
+		"enter parseType 3",
+		"exit parseType 3",
+		"enter parseExpression 3",
+		"enter parsePrefixExpression 4",
+		"enter parseExpression 5",
+		"enter parseNumberLiteral 6",
+		"exit parseNumberLiteral 6",
+		"exit parseExpression 5",
+		"exit parsePrefixExpression 4",
+		"exit parseExpression 3",
+		"exit parseLetStatement 2",
+		"exit parseStatement 1",
+		"exit ParseProgram 0",
+	}
+
+	if strings.Join(tracer.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace.\nexpected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(tracer.events, "\n"))
+	}
+}
+
+func TestParserTraceWriter(t *testing.T) {
+	var out bytes.Buffer
+	p := New(lexer.New("x"), WithTraceWriter(&out))
+	p.ParseProgram()
+
+	expected := `enter ParseProgram at 1:1 IDENT "x"
+  enter parseStatement at 1:1 IDENT "x"
+    enter parseExpressionStatement at 1:1 IDENT "x"
+      enter parseExpression at 1:1 IDENT "x"
+        enter parseIdentifier at 1:1 IDENT "x"
+        exit  parseIdentifier at 1:1 IDENT "x"
+      exit  parseExpression at 1:1 IDENT "x"
+    exit  parseExpressionStatement at 1:1 IDENT "x"
+  exit  parseStatement at 1:1 IDENT "x"
+exit  ParseProgram at 1:2 EOF ""
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestParserDoesNotTraceByDefault(t *testing.T) {
+	p := New(lexer.New("let x = 1;"))
+	p.ParseProgram()
+
+	if p.tracer != nil || p.depth != 0 {
+		t.Errorf("expected tracing to be off by default")
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fun(a: Number, b: Number): Number {
+	return (a + b) * 2;
+};
+add(1, -2)`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	text := func(node interface{ Span() lexer.Span }) string {
+		span := node.Span()
+		return input[span.Start.Offset:span.End.Offset]
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	ret := fn.Body.Statements[0].(*ast.ReturnStatement)
+	product := ret.ReturnValue.(*ast.InfixExpression)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	tests := []struct {
+		name     string
+		node     interface{ Span() lexer.Span }
+		expected string
+	}{
+		{"program", program, input},
+		{"let", let, input[:strings.Index(input, "\nadd")]},
+		{"let name", let.Name, "add"},
+		{"function literal", fn, input[strings.Index(input, "fun"):strings.LastIndex(input, ";")]},
+		{"parameter", fn.Parameters[1], "b: Number"},
+		{"return type", fn.ReturnType, "Number"},
+		{"body", fn.Body, "{\n\treturn (a + b) * 2;\n}"},
+		{"return", ret, "return (a + b) * 2;"},
+		{"product", product, "(a + b) * 2"},
+		{"sum", product.Left, "a + b"},
+		{"call", call, "add(1, -2)"},
+		{"prefix", call.Arguments[1], "-2"},
+	}
+
+	for _, tt := range tests {
+		if got := text(tt.node); got != tt.expected {
+			t.Errorf("%s: wrong span text. expected=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+
+	typ := New(lexer.New(" (Number, String) -> Boolean")).ParseType()
+	if got := typ.Span(); got.Start.Offset != 1 || got.End.Offset != 28 {
+		t.Errorf("wrong span for function type: %+v", got)
+	}
+
+	span := ret.Span()
+	if span.Start.Line != 2 || span.Start.Column != 2 || span.End.Line != 2 || span.End.Column != 21 {
+		t.Errorf("wrong line/column span for return statement: %+v", span)
+	}
+}
@@ -1,202 +1,91 @@
 package parser
 
 import (
-	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
 	"sigil/internal/lexer"
 )
 
+// parseStatement parses one statement. A statement containing a syntax error
+// is dropped and the parser skips to the next statement boundary, so the AST
+// never holds a partially parsed node. Errors already recovered from inside
+// nested blocks do not cause the enclosing statement to be dropped.
 func (p *Parser) parseStatement() ast.Statement {
+	defer p.trace("parseStatement")()
+
+	start := p.curToken
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case lexer.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case lexer.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
 	default:
-		return p.parseExpressionStatement()
+		if p.atTestDeclaration() {
+			stmt = p.parseTestStatement()
+		} else {
+			stmt = p.parseExpressionStatement()
+		}
+	}
+
+	if len(p.errors) > p.synced {
+		p.synchronize(start)
+		return nil
 	}
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() ast.Statement {
-	fmt.Printf("[parseLetStatement] start: curToken=%s, peekToken=%s\n", p.curToken.Literal, p.peekToken.Literal)
-	stmt := &ast.LetStatement{Token: p.curToken}
+	defer p.trace("parseLetStatement")()
+
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.curDoc}
 
 	// Expect identifier after 'let'
 	if !p.expectPeek(lexer.IDENT) {
-		fmt.Println("[parseLetStatement] expected IDENT after 'let'")
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	fmt.Printf("[parseLetStatement] parsed name: %s\n", stmt.Name.Value)
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Range: p.curToken.Span}
 
 	// Optional type annotation
 	if p.peekTokenIs(lexer.COLON) {
-		fmt.Println("[parseLetStatement] detected type hint")
 		p.nextToken() // consume ':'
 
 		if !p.expectPeek(lexer.IDENT) {
-			fmt.Println("[parseLetStatement] expected type name after ':'")
 			return nil
 		}
 
 		stmt.TypeHint = p.parseType()
 		if stmt.TypeHint == nil {
-			fmt.Println("[parseLetStatement] failed to parse type hint")
 			return nil
 		}
-		fmt.Printf("[parseLetStatement] parsed type hint: %+v\n", stmt.TypeHint)
-
 	}
 
 	// After optional type, expect '='
 	if !p.expectPeek(lexer.ASSIGN) {
-		fmt.Printf("[parseLetStatement] expected '=', got %s\n", p.peekToken.Literal)
 		return nil
 	}
 	p.nextToken() // move to expression
-	fmt.Printf("[parseLetStatement] parsing value expression at token: %s\n", p.curToken.Literal)
 
 	stmt.Value = p.parseExpression(LOWEST)
 	if stmt.Value == nil {
-		fmt.Println("[parseLetStatement] failed to parse value expression")
 		return nil
 	}
 
 	// Optional semicolon
 	if p.peekTokenIs(lexer.SEMICOLON) {
 		p.nextToken()
-		fmt.Println("[parseLetStatement] consumed semicolon")
 	}
 
-	fmt.Printf("[parseLetStatement] finished: curToken=%s, peekToken=%s\n", p.curToken.Literal, p.peekToken.Literal)
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
 	return stmt
 }
 
-// func (p *Parser) parseLetStatement() ast.Statement {
-// 	fmt.Printf("[parseLetStatement] curToken=%s, peekToken=%s\n", p.curToken.Literal, p.peekToken.Literal)
-// 	stmt := &ast.LetStatement{Token: p.curToken}
-
-// 	// Expect an identifier
-// 	if !p.expectPeek(lexer.IDENT) {
-// 		fmt.Println("[parseLetStatement] expected IDENT after 'let'")
-// 		return nil
-// 	}
-
-// 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-// 	fmt.Printf("[parseLetStatement] parsed name: %s\n", stmt.Name.Value)
-
-// 	// Optional type annotation
-// 	if p.peekTokenIs(lexer.COLON) {
-// 		fmt.Println("[parseLetStatement] detected type hint")
-// 		p.nextToken() // consume ':'
-// 		p.nextToken() // move to type token
-// 		stmt.TypeHint = p.parseType()
-// 		if stmt.TypeHint == nil {
-// 			fmt.Println("[parseLetStatement] failed to parse type hint")
-// 			return nil
-// 		}
-// 		fmt.Printf("[parseLetStatement] parsed type hint: %+v\n", stmt.TypeHint)
-// 	}
-
-// 	// After optional type, expect '='
-// 	if !p.expectPeek(lexer.ASSIGN) {
-// 		fmt.Printf("[parseLetStatement] expected '=', got %s\n", p.peekToken.Literal)
-// 		return nil
-// 	}
-
-// 	p.nextToken() // move to expression
-// 	fmt.Printf("[parseLetStatement] parsing value expression at token: %s\n", p.curToken.Literal)
-// 	stmt.Value = p.parseExpression(LOWEST)
-// 	if stmt.Value == nil {
-// 		fmt.Println("[parseLetStatement] failed to parse value expression")
-// 		return nil
-// 	}
-
-// 	if p.peekTokenIs(lexer.SEMICOLON) {
-// 		p.nextToken() // consume ';'
-// 		fmt.Println("[parseLetStatement] consumed semicolon")
-// 	}
-
-// 	return stmt
-// }
-
-// func (p *Parser) parseLetStatement() ast.Statement {
-// 	stmt := &ast.LetStatement{Token: p.curToken}
-
-// 	if !p.expectPeek(lexer.IDENT) {
-// 		return nil
-// 	}
-
-// 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-
-// 	// Optional type annotation
-// 	if p.peekTokenIs(lexer.COLON) {
-// 		p.nextToken() // consume ':'
-// 		p.nextToken() // move to type token
-// 		stmt.TypeHint = p.parseType()
-// 		if stmt.TypeHint == nil {
-// 			return nil
-// 		}
-// 	}
-
-// 	// After optional type, advance to '='
-// 	if !p.expectPeek(lexer.ASSIGN) {
-// 		return nil
-// 	}
-// 	p.nextToken() // advance to start of value expression
-
-// 	stmt.Value = p.parseExpression(LOWEST)
-// 	if stmt.Value == nil {
-// 		return nil
-// 	}
-
-// 	if p.peekTokenIs(lexer.SEMICOLON) {
-// 		p.nextToken() // consume ';'
-// 	}
-
-// 	return stmt
-// }
-
-// func (p *Parser) parseLetStatement() *ast.LetStatement {
-// 	stmt := &ast.LetStatement{Token: p.curToken}
-
-// 	if !p.expectPeek(lexer.IDENT) {
-// 		return nil
-// 	}
-
-// 	name := p.curToken.Literal
-// 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: name}
-
-// 	// Optional type hint
-// 	if p.peekTokenIs(lexer.COLON) {
-// 		p.nextToken() // consume ':'
-// 		if !p.expectPeek(lexer.IDENT) {
-// 			return nil
-// 		}
-// 		stmt.TypeHint = p.parseType()
-// 	}
-
-// 	if !p.expectPeek(lexer.ASSIGN) {
-// 		return nil
-// 	}
-
-// 	p.nextToken() // move to expression
-// 	stmt.Value = p.parseExpression(LOWEST)
-
-// 	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
-// 		fn.Name = name
-// 		stmt.Value = fn
-// 	}
-
-// 	if p.peekTokenIs(lexer.SEMICOLON) {
-// 		p.nextToken()
-// 	}
-
-// 	return stmt
-// }
-
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	defer p.trace("parseReturnStatement")()
+
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	p.nextToken() // move past 'return'
@@ -207,10 +96,41 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 		p.nextToken()
 	}
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
+	return stmt
+}
+
+// atTestDeclaration reports whether the current token starts a test
+// declaration. test is only a keyword there, so that it stays usable as a
+// name everywhere else.
+func (p *Parser) atTestDeclaration() bool {
+	return p.curTokenIs(lexer.IDENT) && p.curToken.Literal == "test" && p.peekTokenIs(lexer.STRING)
+}
+
+// parseTestStatement parses a test declaration, test "name" { ... }.
+func (p *Parser) parseTestStatement() ast.Statement {
+	defer p.trace("parseTestStatement")()
+
+	stmt := &ast.TestStatement{Token: p.curToken}
+	stmt.Token.Type = lexer.TEST
+
+	if !p.expectPeek(lexer.STRING) {
+		return nil
+	}
+	stmt.Name = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal, Range: p.curToken.Span}
+
+	if !p.expectPeek(lexer.LEFT_BRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
 	return stmt
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.trace("parseExpressionStatement")()
+
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -222,19 +142,34 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 		stmt.HasSemicolon = false
 	}
 
+	stmt.Range = p.spanFrom(stmt.Token.Span.Start)
 	return stmt
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.trace("parseBlockStatement")()
+
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 
 	for !p.curTokenIs(lexer.RIGHT_BRACE) && !p.curTokenIs(lexer.EOF) {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		if p.resume {
+			p.resume = false
+			continue
+		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(lexer.EOF) {
+		err := p.addError(p.curToken, diagnostic.CodeUnclosedBlock, "expected RIGHT_BRACE to close the block opened at line %d, column %d",
+			block.Token.Line, block.Token.Column)
+		err.Related = append(err.Related, diagnostic.Label{Span: block.Token.Span, Message: "block opened here"})
+	}
+
+	block.Range = p.spanFrom(block.Token.Span.Start)
 	return block
 }
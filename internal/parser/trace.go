@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"sigil/internal/lexer"
+	"strings"
+)
+
+// TraceEvent describes the parser entering or leaving a parse function.
+type TraceEvent struct {
+	Function string      // name of the parse function, e.g. "parseLetStatement"
+	Token    lexer.Token // the current token when the event fired
+	Depth    int         // nesting depth, 0 for the outermost call
+}
+
+// Tracer receives an event every time a parse function is entered and left.
+type Tracer interface {
+	Enter(event TraceEvent)
+	Exit(event TraceEvent)
+}
+
+// Option configures a Parser.
+type Option func(*Parser)
+
+// WithTracer reports every parse function call to t.
+func WithTracer(t Tracer) Option {
+	return func(p *Parser) { p.tracer = t }
+}
+
+// WithTraceWriter writes an indented line to w for every parse function call.
+func WithTraceWriter(w io.Writer) Option {
+	return WithTracer(&writerTracer{w: w})
+}
+
+type writerTracer struct {
+	w io.Writer
+}
+
+func (wt *writerTracer) Enter(event TraceEvent) { wt.write("enter", event) }
+func (wt *writerTracer) Exit(event TraceEvent)  { wt.write("exit ", event) }
+
+func (wt *writerTracer) write(kind string, event TraceEvent) {
+	fmt.Fprintf(wt.w, "%s%s %s at %d:%d %s %q\n",
+		strings.Repeat("  ", event.Depth), kind, event.Function,
+		event.Token.Line, event.Token.Column, event.Token.Type, event.Token.Literal)
+}
+
+func untrace() {}
+
+// trace reports entering the named parse function and returns the function
+// that reports leaving it, meant to be deferred:
+//
+//	defer p.trace("parseLetStatement")()
+func (p *Parser) trace(function string) func() {
+	if p.tracer == nil {
+		return untrace
+	}
+
+	p.tracer.Enter(TraceEvent{Function: function, Token: p.curToken, Depth: p.depth})
+	p.depth++
+
+	return func() {
+		p.depth--
+		p.tracer.Exit(TraceEvent{Function: function, Token: p.curToken, Depth: p.depth})
+	}
+}
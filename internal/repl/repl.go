@@ -0,0 +1,295 @@
+// Package repl implements the interactive Sigil prompt.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sigil/internal/compiler"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/pkg/sigil"
+	"strconv"
+	"strings"
+)
+
+const (
+	PROMPT       = ">> "
+	CONTINUATION = ".. "
+)
+
+// entryName is the file name diagnostics give to entries typed at the prompt.
+const entryName = "<repl>"
+
+const help = `Enter Sigil statements or expressions. Input continues until braces balance.
+Commands:
+  :type <expr>   show the type of an expression without running it
+  :ast <expr>    show the syntax tree of the input
+  :env           list global bindings and their types
+  :history       list the entries of this and earlier sessions
+  :load <file>   run a file in the current session
+  :reset         discard all bindings
+  :help          show this message
+  :quit          leave the prompt
+`
+
+// REPL reads input, evaluates it against one persistent engine and prints
+// each result together with its type.
+type REPL struct {
+	in      *bufio.Scanner
+	out     io.Writer
+	history io.Writer // may be nil
+	entries []string  // entered so far, including those of LoadHistory
+	engine  *sigil.Engine
+}
+
+// New creates a prompt reading from in and writing results and program output
+// to out. Every complete entry is appended to history when it is not nil.
+func New(in io.Reader, out io.Writer, history io.Writer) *REPL {
+	return &REPL{
+		in:      bufio.NewScanner(in),
+		out:     out,
+		history: history,
+		engine:  sigil.New(sigil.WithStdout(out)),
+	}
+}
+
+// Start runs the prompt until the input ends or :quit is entered.
+func (r *REPL) Start() {
+	for {
+		entry, ok := r.readEntry()
+		if !ok {
+			return
+		}
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		r.entries = append(r.entries, entry)
+		if r.history != nil {
+			fmt.Fprintln(r.history, entry)
+		}
+
+		if strings.HasPrefix(entry, ":") {
+			if quit := r.command(entry); quit {
+				return
+			}
+			continue
+		}
+
+		r.eval(entryName, entry)
+	}
+}
+
+// LoadHistory reads the entries of earlier sessions from src, as they were
+// written to the history, so that :history lists them too.
+func (r *REPL) LoadHistory(src io.Reader) error {
+	var entry strings.Builder
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		entry.WriteString(scanner.Text())
+		entry.WriteString("\n")
+
+		if depth(entry.String()) <= 0 {
+			if text := strings.TrimSpace(entry.String()); text != "" {
+				r.entries = append(r.entries, text)
+			}
+			entry.Reset()
+		}
+	}
+	return scanner.Err()
+}
+
+// readEntry reads lines until every opened brace, parenthesis and bracket
+// has been closed.
+func (r *REPL) readEntry() (string, bool) {
+	var entry strings.Builder
+
+	fmt.Fprint(r.out, PROMPT)
+	for r.in.Scan() {
+		entry.WriteString(r.in.Text())
+		entry.WriteString("\n")
+
+		if depth(entry.String()) <= 0 {
+			return entry.String(), true
+		}
+		fmt.Fprint(r.out, CONTINUATION)
+	}
+
+	// Input ended part way through an entry, evaluate what we have so the
+	// user sees the syntax error.
+	if entry.Len() > 0 {
+		return entry.String(), true
+	}
+	return "", false
+}
+
+func (r *REPL) command(entry string) bool {
+	name, arg, _ := strings.Cut(entry, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q":
+		return true
+	case ":help":
+		fmt.Fprint(r.out, help)
+	case ":type":
+		program, err := r.engine.Compile(arg)
+		if err != nil {
+			r.printError(entryName, arg, err)
+			return false
+		}
+		fmt.Fprintln(r.out, program.Type())
+	case ":ast":
+		// The tree only needs the input to parse, not to type check.
+		result := compiler.Parse(arg)
+		if errs := result.Errors(); len(errs) > 0 {
+			printer := diagnostic.NewPrinter(r.out, diagnostic.NewFile(entryName, arg), false)
+			for _, err := range errs {
+				printer.Print(err.Diagnostic())
+			}
+			return false
+		}
+		fmt.Fprint(r.out, result.Program.TreeString("", false))
+	case ":env":
+		for _, global := range r.engine.Globals() {
+			fmt.Fprintf(r.out, "%s: %s\n", global.Name, global.Type)
+		}
+	case ":history":
+		for i, entry := range r.entries {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, entry)
+		}
+	case ":reset":
+		r.engine = sigil.New(sigil.WithStdout(r.out))
+		fmt.Fprintln(r.out, "environment reset")
+	case ":load":
+		source, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %s\n", err)
+			return false
+		}
+		r.eval(arg, string(source))
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, try :help\n", name)
+	}
+
+	return false
+}
+
+// eval compiles and runs source, an entry or a loaded file called name.
+func (r *REPL) eval(name, source string) {
+	program, err := r.engine.Compile(source)
+	if err != nil {
+		r.printError(name, source, err)
+		return
+	}
+
+	result, err := r.engine.Run(program)
+	if err != nil {
+		r.printError(name, source, err)
+		return
+	}
+
+	// A trailing semicolon discards the value, which the type checker
+	// reports as Void.
+	if program.Type() == "Void" || result == nil {
+		return
+	}
+
+	fmt.Fprintf(r.out, "%s : %s\n", formatValue(result), program.Type())
+}
+
+// printError renders err the way the sigil command does, against source, the
+// entry it was found in.
+func (r *REPL) printError(name, source string, err error) {
+	printer := diagnostic.NewPrinter(r.out, diagnostic.NewFile(name, source), false)
+	if list, ok := err.(sigil.ErrorList); ok {
+		for _, e := range list {
+			printer.Print(diagnosticOf(e))
+		}
+		return
+	}
+
+	var e *sigil.Error
+	if errors.As(err, &e) {
+		printer.Print(diagnosticOf(e))
+		return
+	}
+	fmt.Fprintln(r.out, err.Error())
+}
+
+// diagnosticOf turns e back into the report of the stage that found it.
+func diagnosticOf(e *sigil.Error) *diagnostic.Diagnostic {
+	d := &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     e.Code,
+		Message:  e.Message,
+		Span:     span(e.Line, e.Column, e.EndLine, e.EndColumn),
+		Label:    e.Label,
+		Notes:    e.Notes,
+	}
+	for _, related := range e.Related {
+		d.Related = append(d.Related, diagnostic.Label{
+			Span:    span(related.Line, related.Column, related.EndLine, related.EndColumn),
+			Message: related.Message,
+		})
+	}
+	return d
+}
+
+func span(line, column, endLine, endColumn int) lexer.Span {
+	return lexer.Span{
+		Start: lexer.Position{Line: line, Column: column},
+		End:   lexer.Position{Line: endLine, Column: endColumn},
+	}
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// depth returns how many braces, parentheses and brackets are left open in
+// source, ignoring string literals and comments.
+func depth(source string) int {
+	open := 0
+	inString := false
+
+	for i := 0; i < len(source); i++ {
+		ch := source[i]
+
+		if inString {
+			if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '/':
+			if i+1 < len(source) && source[i+1] == '/' {
+				for i < len(source) && source[i] != '\n' {
+					i++
+				}
+			}
+		case '{', '(', '[':
+			open++
+		case '}', ')', ']':
+			open--
+		}
+	}
+
+	return open
+}
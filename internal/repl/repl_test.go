@@ -0,0 +1,128 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, input string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	New(strings.NewReader(input), &out, nil).Start()
+
+	// Drop the prompts so tests only see results.
+	result := strings.ReplaceAll(out.String(), PROMPT, "")
+	return strings.ReplaceAll(result, CONTINUATION, "")
+}
+
+func TestREPLEvaluation(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 + 2\n", "3 : Number\n"},
+		{"1 + 2;\n", ""},
+		{`"a" + "b"` + "\n", "\"ab\" : String\n"},
+		{"let x: Number = 5;\nx * 2\n", "10 : Number\n"},
+		{"let x: Number = 5;\nx = x + 1;\nx\n", "6 : Number\n"},
+		{"let add = fun(a: Number, b: Number): Number {\n  a + b\n}\nadd(1, 2)\n", "3 : Number\n"},
+		{`println("hi")` + "\n", "hi\n"},
+		{"1 + true\n", "error[E0203]: cannot add Number and Boolean\n --> <repl>:1:1\n  |\n1 | 1 + true\n  | ^^^^^^^^\n  |\n\n"},
+		{"let x: String = 42;\n", "error[E0207]: type mismatch: declared String but got Number\n --> <repl>:1:17\n  |\n1 | let x: String = 42;\n  |                 ^^\n  |        ------ expected because of this annotation\n  |\n\n"},
+		{"let count = 1;\ncout\n", "error[E0201]: undefined variable: cout\n --> <repl>:1:1\n  |\n1 | cout\n  | ^^^^\n  |\n  = note: did you mean `count`?\n\n"},
+		{"let = 1;\n", "error[E0101]: expected next token to be IDENT, got ASSIGN instead\n --> <repl>:1:5\n  |\n1 | let = 1;\n  |     ^\n  |\n\n"},
+		{"assert_eq(1, 2)\n", "error[E0309]: assertion failed: expected 1, got 2\n --> <repl>:1:1\n  |\n1 | assert_eq(1, 2)\n  | ^^^^^^^^^^^^^^^\n  |\n\n"},
+	}
+
+	for _, tt := range tests {
+		got := run(t, tt.input)
+		if got != tt.want {
+			t.Errorf("input %q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "lib.sgl")
+	if err := os.WriteFile(file, []byte("let double = fun(x: Number): Number { x * 2 };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.sgl")
+	if err := os.WriteFile(broken, []byte("let x = 1;\nx + true"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{":type 1 < 2\n", "Boolean\n"},
+		{":type fun(x: Number): Number { x }\n", "(Number) -> Number\n"},
+		{":ast 1\n", "Program\n└── ExpressionStatement\n    └── NumberLiteral: 1\n"},
+		{":ast undefined\n", "Program\n└── ExpressionStatement\n    └── Identifier: undefined\n"},
+		{":ast let = 1;\n", "error[E0101]: expected next token to be IDENT, got ASSIGN instead\n --> <repl>:1:5\n  |\n1 | let = 1;\n  |     ^\n  |\n\n"},
+		{"let b: Boolean = true;\nlet a: Number = 1;\n:env\n", "a: Number\nb: Boolean\n"},
+		{"let a: Number = 1;\n:reset\na\n", "environment reset\nerror[E0201]: undefined variable: a\n --> <repl>:1:1\n  |\n1 | a\n  | ^\n  |\n\n"},
+		{":load " + file + "\ndouble(4)\n", "8 : Number\n"},
+		{":load " + broken + "\n", "error[E0203]: cannot add Number and Boolean\n --> " + broken + ":2:1\n  |\n2 | x + true\n  | ^^^^^^^^\n  |\n\n"},
+		{":type 1 +\n", "error[E0102]: no prefix parse function for EOF found\n --> <repl>:1:4\n  |\n1 | 1 +\n  |    ^\n  |\n\n"},
+		{":quit\n1 + 1\n", ""},
+		{":bogus\n", "unknown command :bogus, try :help\n"},
+	}
+
+	for _, tt := range tests {
+		got := run(t, tt.input)
+		if got != tt.want {
+			t.Errorf("input %q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestREPLHistory(t *testing.T) {
+	var out, history bytes.Buffer
+	New(strings.NewReader("let x: Number = 1;\n\nif (true) {\n  x\n} else {\n  2\n}\n"), &out, &history).Start()
+
+	want := "let x: Number = 1;\nif (true) {\n  x\n} else {\n  2\n}\n"
+	if history.String() != want {
+		t.Errorf("got history %q, want %q", history.String(), want)
+	}
+}
+
+func TestREPLLoadHistory(t *testing.T) {
+	var out bytes.Buffer
+	r := New(strings.NewReader("1 + 1\n:history\n"), &out, nil)
+	if err := r.LoadHistory(strings.NewReader("let x: Number = 1;\nif (true) {\n  x\n} else {\n  2\n}\n")); err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+
+	got := strings.ReplaceAll(out.String(), PROMPT, "")
+	want := "2 : Number\n   1  let x: Number = 1;\n   2  if (true) {\n  x\n} else {\n  2\n}\n   3  1 + 1\n   4  :history\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"fun(): Number {", 1},
+		{"fun(): Number { 1 }", 0},
+		{`"{"`, 0},
+		{"// {", 0},
+		{"f(\n", 1},
+	}
+
+	for _, tt := range tests {
+		if got := depth(tt.input); got != tt.want {
+			t.Errorf("depth(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package resolver works out where each binding of a Sigil program lives at
+// run time, so that backends can keep variables in slot arrays instead of
+// looking names up in a chain of maps.
+package resolver
+
+import "sigil/internal/ast"
+
+// Global is the Depth of a binding in the global scope.
+const Global = -1
+
+// Address locates a binding. A local binding is Depth function scopes out
+// from the identifier, at index Slot of that scope's frame. A global binding
+// has Depth Global and Slot indexes the global frame.
+type Address struct {
+	Depth int
+	Slot  int
+}
+
+// scope is the frame layout of the function being resolved. Blocks do not
+// open scopes of their own, so every let of a function body lands here.
+type scope struct {
+	slots map[string]int
+}
+
+// Table holds the addresses the resolver gave to the identifiers of one
+// program, and the frame sizes of its functions. It lives as long as the
+// program or the closures made from it, so that the resolver itself does not
+// hold on to every program it has seen.
+type Table struct {
+	addresses map[*ast.Identifier]Address
+	frames    map[*ast.FunctionLiteral]int
+}
+
+// Address returns the address of ident, or false if ident was not part of
+// the resolved program.
+func (t *Table) Address(ident *ast.Identifier) (Address, bool) {
+	addr, ok := t.addresses[ident]
+	return addr, ok
+}
+
+// FrameSize returns the number of slots a call of fn needs for its
+// parameters and lets.
+func (t *Table) FrameSize(fn *ast.FunctionLiteral) int {
+	return t.frames[fn]
+}
+
+// Resolver assigns an address to every identifier it is given. It keeps the
+// global scope from one program to the next, so that a program may use the
+// globals defined by the ones resolved before it.
+type Resolver struct {
+	globals map[string]int
+	scopes  []*scope // the enclosing functions, innermost last
+	table   *Table   // the table being filled in
+}
+
+func New() *Resolver {
+	return &Resolver{globals: map[string]int{}}
+}
+
+// Global returns the slot of a global name, reserving a new slot the first
+// time the name is seen. Backends use it for builtins and host functions.
+func (r *Resolver) Global(name string) int {
+	slot, ok := r.globals[name]
+	if !ok {
+		slot = len(r.globals)
+		r.globals[name] = slot
+	}
+	return slot
+}
+
+// Globals returns the number of global slots reserved so far.
+func (r *Resolver) Globals() int {
+	return len(r.globals)
+}
+
+// Resolve assigns addresses to the identifiers of a program or of a single
+// top-level statement, and returns them.
+//
+// Names resolve to the innermost binding declared before them, the way the
+// type checker sees them. A name declared nowhere is taken to be a global
+// that a later program or a host may still define; reading it before then is
+// a runtime error.
+func (r *Resolver) Resolve(node ast.Node) *Table {
+	r.table = &Table{
+		addresses: map[*ast.Identifier]Address{},
+		frames:    map[*ast.FunctionLiteral]int{},
+	}
+	r.resolve(node)
+	table := r.table
+	r.table = nil
+	return table
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, stmt := range n.Statements {
+			r.resolve(stmt)
+		}
+
+	case *ast.BlockStatement:
+		for _, stmt := range n.Statements {
+			r.resolve(stmt)
+		}
+
+	case *ast.LetStatement:
+		// A function may call itself, so its name is declared first. Any
+		// other value still sees the binding the name had before.
+		if _, ok := n.Value.(*ast.FunctionLiteral); ok {
+			r.declare(n.Name)
+			r.resolve(n.Value)
+		} else {
+			r.resolve(n.Value)
+			r.declare(n.Name)
+		}
+
+	case *ast.ReturnStatement:
+		r.resolve(n.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		r.resolve(n.Expression)
+
+	case *ast.Identifier:
+		r.lookup(n)
+
+	case *ast.AssignmentExpression:
+		r.resolve(n.Value)
+		r.lookup(n.Name)
+
+	case *ast.PrefixExpression:
+		r.resolve(n.Right)
+
+	case *ast.InfixExpression:
+		r.resolve(n.Left)
+		r.resolve(n.Right)
+
+	case *ast.IfExpression:
+		r.resolve(n.Condition)
+		r.resolve(n.Consequence)
+		if n.Alternative != nil {
+			r.resolve(n.Alternative)
+		}
+
+	case *ast.CallExpression:
+		r.resolve(n.Function)
+		for _, arg := range n.Arguments {
+			r.resolve(arg)
+		}
+
+	case *ast.FunctionLiteral:
+		fn := &scope{slots: map[string]int{}}
+		r.scopes = append(r.scopes, fn)
+		for _, param := range n.Parameters {
+			r.declare(param.Name)
+		}
+		r.resolve(n.Body)
+		r.scopes = r.scopes[:len(r.scopes)-1]
+		r.table.frames[n] = len(fn.slots)
+	}
+}
+
+// declare binds name in the innermost scope. Declaring a name again in the
+// same scope reuses its slot.
+func (r *Resolver) declare(name *ast.Identifier) {
+	if len(r.scopes) == 0 {
+		r.table.addresses[name] = Address{Depth: Global, Slot: r.Global(name.Value)}
+		return
+	}
+
+	fn := r.scopes[len(r.scopes)-1]
+	slot, ok := fn.slots[name.Value]
+	if !ok {
+		slot = len(fn.slots)
+		fn.slots[name.Value] = slot
+	}
+	r.table.addresses[name] = Address{Depth: 0, Slot: slot}
+}
+
+// lookup finds the binding ident refers to.
+func (r *Resolver) lookup(ident *ast.Identifier) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		fn := r.scopes[len(r.scopes)-1-depth]
+		if slot, ok := fn.slots[ident.Value]; ok {
+			r.table.addresses[ident] = Address{Depth: depth, Slot: slot}
+			return
+		}
+	}
+	r.table.addresses[ident] = Address{Depth: Global, Slot: r.Global(ident.Value)}
+}
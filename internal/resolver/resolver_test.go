@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"fmt"
+	"sigil/internal/ast"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return program
+}
+
+// addresses lists "name@depth:slot" for every resolved identifier of node in
+// source order, with "g" for the depth of globals.
+func addresses(table *Table, node ast.Node) []string {
+	var out []string
+	ident := func(id *ast.Identifier) {
+		addr, ok := table.Address(id)
+		if !ok {
+			out = append(out, id.Value+"@?")
+			return
+		}
+		depth := fmt.Sprint(addr.Depth)
+		if addr.Depth == Global {
+			depth = "g"
+		}
+		out = append(out, fmt.Sprintf("%s@%s:%d", id.Value, depth, addr.Slot))
+	}
+	ast.Inspect(node, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Identifier); ok {
+			ident(id)
+		}
+		return true
+	})
+	return out
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let a = 1; let b = a; a = b;", "[a@g:0 b@g:1 a@g:0 a@g:0 b@g:1]"},
+		{"let a = 1; let a = a + 1;", "[a@g:0 a@g:0 a@g:0]"},
+		{
+			"let add = fun(x: Number, y: Number): Number { let z = x + y; z };",
+			"[add@g:0 x@0:0 y@0:1 z@0:2 x@0:0 y@0:1 z@0:2]",
+		},
+		{
+			"let fib = fun(n: Number): Number { fib(n - 1) };",
+			"[fib@g:0 n@0:0 fib@g:0 n@0:0]",
+		},
+		{
+			// Closures reach out through the frames of their enclosing functions.
+			"let outer = fun(a: Number): Number { let inner = fun(b: Number): Number { a + b }; inner(a) };",
+			"[outer@g:0 a@0:0 inner@0:1 b@0:0 a@1:0 b@0:0 inner@0:1 a@0:0]",
+		},
+		{
+			// A local function can call itself.
+			"let f = fun(): Number { let g = fun(n: Number): Number { g(n) }; g(1) };",
+			"[f@g:0 g@0:0 n@0:0 g@1:0 n@0:0 g@0:0]",
+		},
+		{
+			// The value of a let still sees the binding it shadows.
+			"let x = 1; let f = fun(): Number { let x = x + 1; x };",
+			"[x@g:0 f@g:1 x@0:0 x@g:0 x@0:0]",
+		},
+		{
+			// Blocks do not open scopes.
+			"let f = fun(c: Boolean): Number { if (c) { let y = 1; } y };",
+			"[f@g:0 c@0:0 c@0:0 y@0:1 y@0:1]",
+		},
+		{
+			// Undeclared names become globals a later program may define.
+			"let f = fun(): Number { later() };",
+			"[f@g:0 later@g:1]",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		table := New().Resolve(program)
+
+		if got := fmt.Sprint(addresses(table, program)); got != tt.want {
+			t.Errorf("input %q:\n got %s\nwant %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFrameSize(t *testing.T) {
+	program := parse(t, "let f = fun(a: Number, b: Number): Number { let c = a; if (a < b) { let d = b; } let c = b; c };")
+	table := New().Resolve(program)
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if got := table.FrameSize(fn); got != 4 {
+		t.Errorf("expected a frame of 4 slots, got %d", got)
+	}
+}
+
+func TestGlobalsPersistBetweenPrograms(t *testing.T) {
+	r := New()
+	if slot := r.Global("print"); slot != 0 {
+		t.Fatalf("expected the first global in slot 0, got %d", slot)
+	}
+
+	first := parse(t, "let x = 1;")
+	r.Resolve(first)
+	second := parse(t, "let y = x; print;")
+	table := r.Resolve(second)
+
+	if got := fmt.Sprint(addresses(table, second)); got != "[y@g:2 x@g:1 print@g:0]" {
+		t.Errorf("got %s", got)
+	}
+	// Each program gets a table of its own, so that the resolver does not
+	// keep the programs it has resolved alive.
+	if got := fmt.Sprint(addresses(table, first)); got != "[x@?]" {
+		t.Errorf("the table of the second program holds %s", got)
+	}
+	if r.Globals() != 3 {
+		t.Errorf("expected 3 globals, got %d", r.Globals())
+	}
+}
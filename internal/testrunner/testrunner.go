@@ -0,0 +1,59 @@
+// Package testrunner runs the test declarations of Sigil programs,
+//
+//	test "name" { ... }
+//
+// each on a backend of its own, so that nothing one test does can be seen by
+// another. For that, every test runs the top-level statements of the program
+// again before its body: whatever they do, such as printing, happens once per
+// test rather than once per program.
+package testrunner
+
+import (
+	"regexp"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+)
+
+// Tests returns the tests program declares whose names match filter, in
+// source order. A nil filter matches every test.
+func Tests(program *ast.Program, filter *regexp.Regexp) []*ast.TestStatement {
+	var tests []*ast.TestStatement
+	for _, stmt := range program.Statements {
+		test, ok := stmt.(*ast.TestStatement)
+		if ok && (filter == nil || filter.MatchString(test.Name.Value)) {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
+// Program returns the program that runs one test of program: its top-level
+// statements other than the tests, then a call of a function whose body is
+// the test's. The test sees the top-level bindings, as the type checker
+// expects, while its own lets stay local to it and a return ends it.
+func Program(program *ast.Program, test *ast.TestStatement) *ast.Program {
+	out := &ast.Program{Range: program.Range}
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(*ast.TestStatement); !ok {
+			out.Statements = append(out.Statements, stmt)
+		}
+	}
+
+	fn := &ast.FunctionLiteral{Token: test.Token, Name: test.Name.Value, Body: test.Body, Range: test.Range}
+	call := &ast.CallExpression{Token: test.Token, Function: fn, Arguments: []ast.Expression{}, Range: test.Range}
+	out.Statements = append(out.Statements, &ast.ExpressionStatement{
+		Token:        test.Token,
+		Expression:   call,
+		HasSemicolon: true,
+		Range:        test.Range,
+	})
+	return out
+}
+
+// Run runs one test of a type checked program on a new backend made by
+// factory, after the top-level statements of the program. It returns the
+// error that failed the test, such as that of an assertion, or nil if the
+// test passed.
+func Run(program *ast.Program, test *ast.TestStatement, factory backends.Factory, cfg *backends.IOConfig) error {
+	return factory(cfg).Execute(Program(program, test), false)
+}
@@ -0,0 +1,144 @@
+package testrunner
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	_ "sigil/internal/backends/interpreter"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+	"strings"
+	"testing"
+)
+
+const source = `let count = 0;
+let limit = 10;
+let under_limit = fun(n: Number): Boolean { n < limit };
+
+test "counts" {
+    count = count + 1;
+    assert_eq(1, count);
+}
+
+test "starts from zero" {
+    assert_eq(0, count);
+}
+
+test "keeps lets local" {
+    let limit = 1;
+    assert(under_limit(5));
+    assert_eq(1, limit);
+}
+
+test "fails" {
+    assert_eq("ab", "a" + "c");
+}
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	if result := typechecker.New().CheckProgram(program); len(result.Errors) > 0 {
+		t.Fatalf("type checking errors: %v", result.Errors)
+	}
+	return program
+}
+
+func names(tests []*ast.TestStatement) string {
+	var out []string
+	for _, test := range tests {
+		out = append(out, test.Name.Value)
+	}
+	return strings.Join(out, ", ")
+}
+
+func TestTests(t *testing.T) {
+	program := parse(t, source)
+
+	if got := names(Tests(program, nil)); got != "counts, starts from zero, keeps lets local, fails" {
+		t.Errorf("got tests %s", got)
+	}
+	if got := names(Tests(program, regexp.MustCompile("^(counts|fails)$"))); got != "counts, fails" {
+		t.Errorf("got filtered tests %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	program := parse(t, source)
+	cfg := &backends.IOConfig{Stdin: strings.NewReader(""), Stdout: io.Discard, Stderr: io.Discard}
+
+	for _, name := range backends.Names() {
+		factory, _ := backends.Lookup(name)
+		for _, test := range Tests(program, nil) {
+			err := Run(program, test, factory, cfg)
+			if test.Name.Value != "fails" {
+				if err != nil {
+					t.Errorf("%s: test %q failed: %v", name, test.Name.Value, err)
+				}
+				continue
+			}
+
+			var runtimeErr *backends.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("%s: expected the test to fail with a runtime error, got %v", name, err)
+			}
+			if runtimeErr.Code != diagnostic.CodeAssertionFailed || runtimeErr.Message != `assertion failed: expected "ab", got "ac"` {
+				t.Errorf("%s: got error %s: %s", name, runtimeErr.Code, runtimeErr.Message)
+			}
+			if start := runtimeErr.Span.Start; start.Line != 21 || start.Column != 5 {
+				t.Errorf("%s: error at %d:%d, want 21:5", name, start.Line, start.Column)
+			}
+		}
+	}
+}
+
+func TestRunRepeatsTheTopLevel(t *testing.T) {
+	program := parse(t, `println("setup");
+
+test "returns early" {
+    if (true) { return 0; } else { 0 };
+    assert(false);
+}
+
+test "runs after" {
+    println("body");
+}
+`)
+
+	for _, name := range backends.Names() {
+		factory, _ := backends.Lookup(name)
+		var stdout strings.Builder
+		cfg := &backends.IOConfig{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: io.Discard}
+		for _, test := range Tests(program, nil) {
+			if err := Run(program, test, factory, cfg); err != nil {
+				t.Errorf("%s: test %q failed: %v", name, test.Name.Value, err)
+			}
+		}
+		if got := stdout.String(); got != "setup\nsetup\nbody\n" {
+			t.Errorf("%s: got output %q", name, got)
+		}
+	}
+}
+
+func TestProgramLeavesTheOriginalAlone(t *testing.T) {
+	program := parse(t, source)
+	before := program.String()
+
+	tests := Tests(program, nil)
+	run := Program(program, tests[0])
+	if len(run.Statements) != 4 {
+		t.Errorf("expected the three lets and the call of the test, got %d statements", len(run.Statements))
+	}
+	if program.String() != before {
+		t.Errorf("the program changed:\n%s", program.String())
+	}
+}
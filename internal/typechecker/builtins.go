@@ -16,6 +16,16 @@ var builtinTypes = map[string]*BuiltinTypeInfo{
 		ParamTypes: []Type{&StringType{}},
 		ReturnType: &VoidType{},
 	},
+	"assert": {
+		Arity:      1,
+		ParamTypes: []Type{&BoolType{}},
+		ReturnType: &VoidType{},
+	},
+	"assert_eq": {
+		Arity:      2,
+		ParamTypes: []Type{&UnknownType{}, &UnknownType{}},
+		ReturnType: &VoidType{},
+	},
 	"string": {
 		Arity:      1,
 		ParamTypes: []Type{&UnknownType{}}, // Unknown means we allow any
@@ -3,9 +3,15 @@ package typechecker
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
 )
 
 func (tc *TypeChecker) CheckExpression(expr ast.Expression) Type {
+	return tc.record(expr, tc.checkExpression(expr))
+}
+
+func (tc *TypeChecker) checkExpression(expr ast.Expression) Type {
 	switch e := expr.(type) {
 	case *ast.NumberLiteral:
 		return &NumberType{}
@@ -28,7 +34,7 @@ func (tc *TypeChecker) CheckExpression(expr ast.Expression) Type {
 	case *ast.AssignmentExpression:
 		return tc.CheckAssignmentExpression(e)
 	default:
-		tc.addError(fmt.Sprintf("unknown expression type: %T", expr), 0, 0)
+		tc.addError(diagnostic.CodeInternal, fmt.Sprintf("unknown expression type: %T", expr), lexer.Span{})
 		return &UnknownType{}
 	}
 }
@@ -36,9 +42,12 @@ func (tc *TypeChecker) CheckExpression(expr ast.Expression) Type {
 func (tc *TypeChecker) CheckIdentifier(ident *ast.Identifier) Type {
 	symbol, exists := tc.env.Get(ident.Value)
 	if !exists {
-		tc.addError(fmt.Sprintf("undefined variable: %s", ident.Value), ident.Token.Line, ident.Token.Column)
+		tc.addError(diagnostic.CodeUndefinedVariable, fmt.Sprintf("undefined variable: %s", ident.Value), ident.Span()).
+			suggest(ident.Value, tc.env.Names())
 		return &UnknownType{}
 	}
+	symbol.used = true
+	tc.resolve(ident, symbol)
 	return symbol.Type
 }
 
@@ -67,31 +76,29 @@ func (tc *TypeChecker) CheckInfixExpression(expr *ast.InfixExpression) Type {
 		}
 
 		// Anything else is an error
-		tc.addError(fmt.Sprintf("cannot add %s and %s", leftType.String(), rightType.String()), expr.Token.Line, expr.Token.Column)
+		tc.addError(diagnostic.CodeMismatchedOperands, fmt.Sprintf("cannot add %s and %s", leftType.String(), rightType.String()), expr.Span())
 		return &UnknownType{}
 
 	case "-", "*", "/":
 		// Arithmetic operators require numbers
 		if !leftType.Equals(&NumberType{}) {
-			tc.addError(
+			tc.addError(diagnostic.CodeInvalidOperand,
 				fmt.Sprintf(
 					"left operand of %s must be %s, got %s",
 					expr.Operator,
 					NUMBER,
 					leftType.String()),
-				expr.Token.Line,
-				expr.Token.Column)
+				expr.Left.Span())
 		}
 
 		if !rightType.Equals(&NumberType{}) {
-			tc.addError(
+			tc.addError(diagnostic.CodeInvalidOperand,
 				fmt.Sprintf(
 					"right operand of %s must be %s, got %s",
 					expr.Operator,
 					NUMBER,
 					rightType.String()),
-				expr.Token.Line,
-				expr.Token.Column)
+				expr.Right.Span())
 		}
 
 		return &NumberType{}
@@ -99,37 +106,38 @@ func (tc *TypeChecker) CheckInfixExpression(expr *ast.InfixExpression) Type {
 	case "==", "!=":
 		// Equality operators require same types
 		if !leftType.Equals(rightType) {
-			tc.addError(fmt.Sprintf("cannot compare %s with %s", leftType.String(), rightType.String()), expr.Token.Line, expr.Token.Column)
+			tc.addError(diagnostic.CodeMismatchedOperands, fmt.Sprintf("cannot compare %s with %s", leftType.String(), rightType.String()), expr.Span())
+		} else if _, ok := leftType.(*FunctionType); ok {
+			tc.addWarning(RuleFunctionComparison, fmt.Sprintf("functions compared with %s", expr.Operator), expr.Span()).Notes =
+				[]string{"this tells whether both sides are the same function value, not whether they behave alike"}
 		}
 		return &BoolType{}
 
 	case "<", ">", "<=", ">=":
 		// Comparison operators require numbers
 		if !leftType.Equals(&NumberType{}) {
-			tc.addError(
+			tc.addError(diagnostic.CodeInvalidOperand,
 				fmt.Sprintf(
 					"left operand of %s must be %s, got %s",
 					expr.Operator,
 					NUMBER,
 					leftType.String()),
-				expr.Token.Line,
-				expr.Token.Column)
+				expr.Left.Span())
 		}
 
 		if !rightType.Equals(&NumberType{}) {
-			tc.addError(
+			tc.addError(diagnostic.CodeInvalidOperand,
 				fmt.Sprintf(
 					"right operand of %s must be %s, got %s",
 					expr.Operator,
 					NUMBER,
 					rightType.String()),
-				expr.Token.Line,
-				expr.Token.Column)
+				expr.Right.Span())
 		}
 		return &BoolType{}
 
 	default:
-		tc.addError(fmt.Sprintf("unknown infix operator: %s", expr.Operator), expr.Token.Line, expr.Token.Column)
+		tc.addError(diagnostic.CodeInternal, fmt.Sprintf("unknown infix operator: %s", expr.Operator), expr.Span())
 		return &UnknownType{}
 	}
 }
@@ -145,18 +153,18 @@ func (tc *TypeChecker) CheckPrefixExpression(expr *ast.PrefixExpression) Type {
 	switch expr.Operator {
 	case "-":
 		if !operandType.Equals(&NumberType{}) {
-			tc.addError(fmt.Sprintf("unary minus requires %s, got %s", NUMBER, operandType.String()), expr.Token.Line, expr.Token.Column)
+			tc.addError(diagnostic.CodeInvalidOperand, fmt.Sprintf("unary minus requires %s, got %s", NUMBER, operandType.String()), expr.Right.Span())
 		}
 		return &NumberType{}
 
 	case "!":
 		if !operandType.Equals(&BoolType{}) {
-			tc.addError(fmt.Sprintf("logical not requires %s, got %s", BOOLEAN, operandType.String()), expr.Token.Line, expr.Token.Column)
+			tc.addError(diagnostic.CodeInvalidOperand, fmt.Sprintf("logical not requires %s, got %s", BOOLEAN, operandType.String()), expr.Right.Span())
 		}
 		return &BoolType{}
 
 	default:
-		tc.addError(fmt.Sprintf("unknown prefix operator: %s", expr.Operator), expr.Token.Line, expr.Token.Column)
+		tc.addError(diagnostic.CodeInternal, fmt.Sprintf("unknown prefix operator: %s", expr.Operator), expr.Span())
 		return &UnknownType{}
 	}
 }
@@ -166,7 +174,7 @@ func (tc *TypeChecker) CheckIfExpression(expr *ast.IfExpression) Type {
 
 	// Condition must be Boolean
 	if !condType.Equals(&BoolType{}) {
-		tc.addError(fmt.Sprintf("if condition must be %s, got %s", BOOLEAN, condType.String()), expr.Token.Line, expr.Token.Column)
+		tc.addError(diagnostic.CodeNonBooleanCondition, fmt.Sprintf("if condition must be %s, got %s", BOOLEAN, condType.String()), expr.Condition.Span())
 	}
 
 	// Check consequence block
@@ -178,13 +186,23 @@ func (tc *TypeChecker) CheckIfExpression(expr *ast.IfExpression) Type {
 		alternativeType = tc.CheckBlockStatement(expr.Alternative)
 	}
 
+	// A branch that always returns takes the type of the other one.
+	if expr.Alternative != nil {
+		if isNever(consequenceType) {
+			return alternativeType
+		}
+		if isNever(alternativeType) {
+			return consequenceType
+		}
+	}
+
 	// Both branches must have the same type if alternative exists
 	if expr.Alternative != nil && !consequenceType.Equals(alternativeType) {
-		tc.addError(fmt.Sprintf(
+		tc.addError(diagnostic.CodeMismatchedBranches, fmt.Sprintf(
 			"if branches must return same type, got %s and %s",
 			consequenceType.String(),
 			alternativeType.String(),
-		), expr.Token.Line, expr.Token.Column)
+		), expr.Span())
 		return &UnknownType{}
 	}
 
@@ -197,13 +215,15 @@ func (tc *TypeChecker) CheckIfExpression(expr *ast.IfExpression) Type {
 func (tc *TypeChecker) CheckAssignmentExpression(expr *ast.AssignmentExpression) Type {
 	sym, ok := tc.env.Get(expr.Name.Value)
 	if !ok {
-		tc.addError(fmt.Sprintf("variable with name %s not defined", expr.Name.Value), expr.Token.Line, expr.Token.Column)
+		tc.addError(diagnostic.CodeUndefinedVariable, fmt.Sprintf("variable with name %s not defined", expr.Name.Value), expr.Name.Span()).
+			suggest(expr.Name.Value, tc.env.Names())
 		return &UnknownType{}
 	}
+	tc.resolve(expr.Name, sym)
 	newType := tc.CheckExpression(expr.Value)
 
 	if !sym.Type.Equals(newType) {
-		tc.addError(fmt.Sprintf("assignment type mismatch, expected %s, got %s", sym.Type, newType), expr.Token.Line, expr.Token.Line)
+		tc.addError(diagnostic.CodeAssignmentMismatch, fmt.Sprintf("assignment type mismatch, expected %s, got %s", sym.Type, newType), expr.Value.Span()).declaredHere(sym)
 		return &UnknownType{}
 	}
 
@@ -217,44 +237,52 @@ func (tc *TypeChecker) CheckCallExpression(ce *ast.CallExpression) Type {
 		if info, exists := builtinTypes[ident.Value]; exists {
 			// check arity
 			if info.Arity != -1 && len(ce.Arguments) != info.Arity {
-				tc.addError(fmt.Sprintf("argument count mismatch: expected %d, got %d", info.Arity, len(ce.Arguments)), ce.Token.Line, ce.Token.Column)
+				tc.addError(diagnostic.CodeArgumentCount, fmt.Sprintf("argument count mismatch: expected %d, got %d", info.Arity, len(ce.Arguments)), ce.Span())
 				return &UnknownType{}
 			}
 
 			// check argument types
 			// If arity is -1, then we only expect a single param type and all values must be that param type.
+			argTypes := make([]Type, len(ce.Arguments))
 			for i, arg := range ce.Arguments {
 				argType := tc.CheckExpression(arg)
+				argTypes[i] = argType
 				if info.Arity == -1 && !info.ParamTypes[0].Equals(&UnknownType{}) && !argType.Equals(info.ParamTypes[0]) {
-					tc.addError(fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, info.ParamTypes[0], argType), ce.Token.Line, ce.Token.Column)
+					tc.addError(diagnostic.CodeArgumentType, fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, info.ParamTypes[0], argType), arg.Span())
 					return &UnknownType{}
 				}
 
 				if info.Arity != -1 && !info.ParamTypes[i].Equals(&UnknownType{}) && !argType.Equals(info.ParamTypes[i]) {
-					tc.addError(fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, info.ParamTypes[i], argType), ce.Token.Line, ce.Token.Column)
+					tc.addError(diagnostic.CodeArgumentType, fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, info.ParamTypes[i], argType), arg.Span())
 					return &UnknownType{}
 				}
 			}
 
+			// assert_eq takes values of any type, but both of the same one.
+			if ident.Value == "assert_eq" && !argTypes[0].Equals(&UnknownType{}) && !argTypes[1].Equals(&UnknownType{}) && !argTypes[1].Equals(argTypes[0]) {
+				tc.addError(diagnostic.CodeArgumentType, fmt.Sprintf("argument 2 type mismatch: expected %v, got %v", argTypes[0], argTypes[1]), ce.Arguments[1].Span())
+				return &UnknownType{}
+			}
+
 			return info.ReturnType
 		}
 	}
 
 	fn, ok := fnType.(*FunctionType)
 	if !ok {
-		tc.addError(fmt.Sprintf("attempted to call a non-function type: %v", fnType), ce.Token.Line, ce.Token.Column)
+		tc.addError(diagnostic.CodeNotCallable, fmt.Sprintf("attempted to call a non-function type: %v", fnType), ce.Function.Span()).declaredHere(tc.symbolFor(ce.Function))
 		return &UnknownType{}
 	}
 
 	if len(ce.Arguments) != len(fn.ParamTypes) {
-		tc.addError(fmt.Sprintf("argument count mismatch: expected %d, got %d", len(fn.ParamTypes), len(ce.Arguments)), ce.Token.Line, ce.Token.Column)
+		tc.addError(diagnostic.CodeArgumentCount, fmt.Sprintf("argument count mismatch: expected %d, got %d", len(fn.ParamTypes), len(ce.Arguments)), ce.Span()).declaredHere(tc.symbolFor(ce.Function))
 		return &UnknownType{}
 	}
 
 	for i, arg := range ce.Arguments {
 		argType := tc.CheckExpression(arg)
 		if !argType.Equals(fn.ParamTypes[i]) {
-			tc.addError(fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, fn.ParamTypes[i], argType), ce.Token.Line, ce.Token.Column)
+			tc.addError(diagnostic.CodeArgumentType, fmt.Sprintf("argument %d type mismatch: expected %v, got %v", i+1, fn.ParamTypes[i], argType), arg.Span()).declaredHere(tc.symbolFor(ce.Function))
 			return &UnknownType{}
 		}
 	}
@@ -0,0 +1,77 @@
+package typechecker
+
+import (
+	"sigil/internal/ast"
+	"sigil/internal/lexer"
+)
+
+// exit is a place where control can reach the end of a function body
+// without returning or producing a value.
+type exit struct {
+	span  lexer.Span
+	label string
+}
+
+// fallsThrough finds the first path through block that ends without a value,
+// following the branches of a trailing if.
+func fallsThrough(block *ast.BlockStatement) (exit, bool) {
+	if returns(block) {
+		return exit{}, false
+	}
+	if len(block.Statements) == 0 {
+		return exit{block.Span(), "this block is empty"}, true
+	}
+
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.LetStatement:
+		return exit{last.Span(), "a let statement has no value"}, true
+
+	case *ast.ExpressionStatement:
+		if ifExpr, ok := last.Expression.(*ast.IfExpression); ok {
+			if ifExpr.Alternative == nil {
+				return exit{ifExpr.Condition.Span(), "nothing is returned when this condition is false"}, true
+			}
+			if e, ok := fallsThrough(ifExpr.Consequence); ok {
+				return e, true
+			}
+			if e, ok := fallsThrough(ifExpr.Alternative); ok {
+				return e, true
+			}
+		}
+		if last.HasSemicolon {
+			return exit{last.Span(), "the semicolon discards this value"}, true
+		}
+	}
+	return exit{}, false
+}
+
+// returns reports whether every path through block ends in a return.
+func returns(block *ast.BlockStatement) bool {
+	for _, stmt := range block.Statements {
+		if alwaysReturns(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// alwaysReturns reports whether control never continues past stmt: it is a
+// return, or an if whose branches both return, on its own or as the value
+// of a let.
+func alwaysReturns(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		return branchesReturn(s.Expression)
+	case *ast.LetStatement:
+		return branchesReturn(s.Value)
+	}
+	return false
+}
+
+// branchesReturn reports whether expr is an if whose branches both return.
+func branchesReturn(expr ast.Expression) bool {
+	ifExpr, ok := expr.(*ast.IfExpression)
+	return ok && ifExpr.Alternative != nil && returns(ifExpr.Consequence) && returns(ifExpr.Alternative)
+}
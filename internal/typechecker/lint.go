@@ -0,0 +1,151 @@
+package typechecker
+
+import (
+	"fmt"
+	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sort"
+	"strings"
+)
+
+// Rule is a lint rule: a check for code that is valid but suspicious. The
+// type checker reports every rule as a warning; callers pick the ones they
+// want to show.
+type Rule struct {
+	Name    string
+	Code    string
+	Summary string
+}
+
+// Names of the lint rules.
+const (
+	RuleUnusedVariable     = "unused-variable"
+	RuleShadowing          = "shadowing"
+	RuleUnreachableCode    = "unreachable-code"
+	RuleDiscardedValue     = "discarded-value"
+	RuleFunctionComparison = "function-comparison"
+)
+
+// Rules lists every lint rule.
+var Rules = []Rule{
+	{RuleUnusedVariable, diagnostic.CodeUnusedVariable, "local variables and parameters that are never read"},
+	{RuleShadowing, diagnostic.CodeShadowing, "lets that hide a binding of an enclosing scope"},
+	{RuleUnreachableCode, diagnostic.CodeUnreachableCode, "statements after a return"},
+	{RuleDiscardedValue, diagnostic.CodeDiscardedValue, "expression statements whose value is thrown away"},
+	{RuleFunctionComparison, diagnostic.CodeFunctionComparison, "functions compared with == or !="},
+}
+
+// LookupRule finds a rule by its name or its code.
+func LookupRule(name string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.Name == name || rule.Code == strings.ToUpper(name) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Warning is a lint finding. Unlike a TypeError it does not stop a program
+// from running.
+type Warning struct {
+	Rule    string
+	Code    string
+	Message string
+	Span    lexer.Span
+	Related []diagnostic.Label
+	Notes   []string
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("Warning at line %d, column %d: %s", w.Span.Start.Line, w.Span.Start.Column, w.Message)
+}
+
+func (w *Warning) Diagnostic() *diagnostic.Diagnostic {
+	return &diagnostic.Diagnostic{
+		Severity: diagnostic.Warning,
+		Code:     w.Code,
+		Message:  w.Message,
+		Span:     w.Span,
+		Related:  w.Related,
+		Notes:    w.Notes,
+	}
+}
+
+func (tc *TypeChecker) addWarning(rule, message string, span lexer.Span) *Warning {
+	r, _ := LookupRule(rule)
+	w := &Warning{Rule: rule, Code: r.Code, Message: message, Span: span}
+	tc.warnings = append(tc.warnings, w)
+	return w
+}
+
+// Warnings returns the lint findings in source order.
+func (tc *TypeChecker) Warnings() []*Warning {
+	sort.SliceStable(tc.warnings, func(i, j int) bool {
+		return tc.warnings[i].Span.Start.Offset < tc.warnings[j].Span.Start.Offset
+	})
+	return tc.warnings
+}
+
+// checkUnused warns about the symbols of a function scope that were never
+// read. Names starting with an underscore are unused on purpose.
+func (tc *TypeChecker) checkUnused(env *Environment) {
+	for _, sym := range env.Symbols() {
+		if sym.used || strings.HasPrefix(sym.Name, "_") {
+			continue
+		}
+		kind := "variable"
+		if sym.param {
+			kind = "parameter"
+		}
+		tc.addWarning(RuleUnusedVariable, fmt.Sprintf("unused %s: %s", kind, sym.Name), sym.Span).Notes =
+			[]string{fmt.Sprintf("if this is intentional, name it `_%s`", sym.Name)}
+	}
+}
+
+// checkShadowing warns when a let inside a function hides a name of an
+// enclosing scope. Redeclaring a name in the same scope is not shadowing.
+func (tc *TypeChecker) checkShadowing(name *ast.Identifier) {
+	if tc.env.outer == nil {
+		return
+	}
+	if _, ok := tc.env.store[name.Value]; ok {
+		return
+	}
+	outer, ok := tc.env.outer.Get(name.Value)
+	if !ok || outer.Span.Start.Line == 0 {
+		return // builtins and host functions may be shadowed freely
+	}
+	w := tc.addWarning(RuleShadowing, fmt.Sprintf("%s shadows a binding of an enclosing scope", name.Value), name.Span())
+	w.Related = append(w.Related, diagnostic.Label{Span: outer.Span, Message: "shadowed binding declared here"})
+}
+
+// checkUnreachable warns about the statements that follow a return in the
+// same block, once per block.
+func (tc *TypeChecker) checkUnreachable(statements []ast.Statement) {
+	for i, stmt := range statements {
+		if !alwaysReturns(stmt) || i == len(statements)-1 {
+			continue
+		}
+		rest := statements[i+1:]
+		span := lexer.Span{Start: rest[0].Span().Start, End: rest[len(rest)-1].Span().End}
+		w := tc.addWarning(RuleUnreachableCode, "unreachable code", span)
+		w.Related = append(w.Related, diagnostic.Label{Span: stmt.Span(), Message: "any code following this is unreachable"})
+		return
+	}
+}
+
+// checkDiscarded warns when stmt computes a value that nothing uses: it is
+// not the last statement of its block, or it ends in a semicolon. Calls,
+// assignments and if expressions are run for their effects and not flagged.
+func (tc *TypeChecker) checkDiscarded(stmt ast.Statement, last bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok || (last && !es.HasSemicolon) {
+		return
+	}
+	switch es.Expression.(type) {
+	case *ast.Identifier, *ast.NumberLiteral, *ast.StringLiteral, *ast.BooleanLiteral,
+		*ast.InfixExpression, *ast.PrefixExpression, *ast.FunctionLiteral:
+		tc.addWarning(RuleDiscardedValue, "value of this expression is discarded", es.Expression.Span())
+	}
+}
@@ -3,6 +3,7 @@ package typechecker
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
 )
 
 func (tc *TypeChecker) CheckFunctionLiteral(fn *ast.FunctionLiteral) Type {
@@ -26,27 +27,41 @@ func (tc *TypeChecker) CheckFunctionLiteral(fn *ast.FunctionLiteral) Type {
 
 	// Add parameters to environment
 	for i, param := range fn.Parameters {
-		tc.env.Set(param.Name.Value, &Symbol{
-			Name:   param.Name.Value,
-			Type:   paramTypes[i],
-			Line:   param.Name.Token.Line,
-			Column: param.Name.Token.Column,
-		})
+		sym := tc.declare(param.Name, paramTypes[i])
+		sym.param = true
 	}
 
 	// Check body
 	bodyType := tc.CheckBlockStatement(fn.Body)
+	tc.checkUnused(tc.env)
 
 	// Restore old environment
 	tc.env = oldEnv
 	tc.currentReturn = oldReturn
 
-	// Ensure body type matches declared return type
-	if !returnType.Equals(bodyType) {
-		tc.addError(fmt.Sprintf(
+	// Every path through the body must return or end in a value of the
+	// declared type. A body that always returns is fine whatever it is.
+	exit, missing := fallsThrough(fn.Body)
+	switch {
+	case isNever(bodyType), bodyType.Equals(&UnknownType{}):
+		// Always returns, or an error inside the body was reported already.
+	case missing && !returnType.Equals(&VoidType{}) && !returnType.Equals(&UnknownType{}):
+		err := tc.addError(diagnostic.CodeMissingReturn, "missing return on some paths", exit.span)
+		err.Related = append(err.Related, diagnostic.Label{Span: fn.ReturnType.Span(), Message: "expected " + returnType.String() + " because of this return type"})
+		err.Notes = append(err.Notes, exit.label)
+	case !returnType.Equals(bodyType):
+		// Point at the statement that produced the body's value.
+		span := fn.Body.Span()
+		if n := len(fn.Body.Statements); n > 0 {
+			span = fn.Body.Statements[n-1].Span()
+		}
+		err := tc.addError(diagnostic.CodeReturnMismatch, fmt.Sprintf(
 			"function body type mismatch: expected %s, got %s",
 			returnType.String(), bodyType.String(),
-		), fn.Token.Line, fn.Token.Column)
+		), span)
+		if fn.ReturnType != nil {
+			err.Related = append(err.Related, diagnostic.Label{Span: fn.ReturnType.Span(), Message: "return type declared here"})
+		}
 	}
 
 	return &FunctionType{
@@ -0,0 +1,51 @@
+package typechecker
+
+import "sigil/internal/ast"
+
+// Result is what checking a program found out about it, kept so that later
+// stages can use the static types without checking the program again.
+type Result struct {
+	// Type is the type of the program, that of its last statement.
+	Type Type
+	// Types holds the type of every expression, statement, block and type
+	// annotation that was checked. Statements without a value are Void.
+	Types map[ast.Node]Type
+	// Symbols maps every identifier that declares or refers to a binding to
+	// its symbol: let names, parameters, uses and assignment targets.
+	// Builtins resolve to symbols with a zero Span.
+	Symbols map[*ast.Identifier]*Symbol
+
+	Errors   []*TypeError
+	Warnings []*Warning
+}
+
+func newResult() *Result {
+	return &Result{
+		Types:   map[ast.Node]Type{},
+		Symbols: map[*ast.Identifier]*Symbol{},
+	}
+}
+
+// TypeOf returns the type recorded for node, or nil if it was not checked.
+func (r *Result) TypeOf(node ast.Node) Type {
+	return r.Types[node]
+}
+
+// SymbolOf returns the symbol ident refers to, or nil if it is undefined.
+func (r *Result) SymbolOf(ident *ast.Identifier) *Symbol {
+	return r.Symbols[ident]
+}
+
+// record notes the type of node and passes it through.
+func (tc *TypeChecker) record(node ast.Node, t Type) Type {
+	if node != nil {
+		tc.result.Types[node] = t
+	}
+	return t
+}
+
+// resolve notes the symbol ident refers to.
+func (tc *TypeChecker) resolve(ident *ast.Identifier, sym *Symbol) {
+	tc.result.Symbols[ident] = sym
+	tc.record(ident, sym.Type)
+}
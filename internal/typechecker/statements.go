@@ -3,19 +3,34 @@ package typechecker
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
 )
 
-// Main entry point for type checking
-func (tc *TypeChecker) CheckProgram(program *ast.Program) Type {
+// Main entry point for type checking. The result also covers whatever this
+// type checker checked before, such as earlier programs sharing its scope.
+func (tc *TypeChecker) CheckProgram(program *ast.Program) *Result {
 	var last Type = &VoidType{}
 
-	for _, stmt := range program.Statements {
+	for i, stmt := range program.Statements {
 		last = tc.CheckStatement(stmt)
+		// The last statement is the value of the program, even with a semicolon.
+		if i < len(program.Statements)-1 {
+			tc.checkDiscarded(stmt, false)
+		}
 	}
-	return last
+
+	tc.result.Type = tc.record(program, last)
+	tc.result.Errors = tc.Errors()
+	tc.result.Warnings = tc.Warnings()
+	return tc.result
 }
 
 func (tc *TypeChecker) CheckStatement(stmt ast.Statement) Type {
+	return tc.record(stmt, tc.checkStatement(stmt))
+}
+
+func (tc *TypeChecker) checkStatement(stmt ast.Statement) Type {
 	switch s := stmt.(type) {
 	case *ast.LetStatement:
 		return tc.CheckLetStatement(s)
@@ -23,8 +38,10 @@ func (tc *TypeChecker) CheckStatement(stmt ast.Statement) Type {
 		return tc.CheckReturnStatement(s)
 	case *ast.ExpressionStatement:
 		return tc.CheckExpressionStatement(s)
+	case *ast.TestStatement:
+		return tc.CheckTestStatement(s)
 	default:
-		tc.addError(fmt.Sprintf("unknown statement type: %T", stmt), 0, 0)
+		tc.addError(diagnostic.CodeInternal, fmt.Sprintf("unknown statement type: %T", stmt), lexer.Span{})
 		return &UnknownType{}
 	}
 }
@@ -35,6 +52,8 @@ func (tc *TypeChecker) CheckLetStatement(stmt *ast.LetStatement) Type {
 
 	// Special handling for function literals to enable recursion
 	if fnLit, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		tc.checkShadowing(stmt.Name)
+
 		// For function assignments, predeclare the variable first
 		if stmt.TypeHint != nil {
 			declaredType = tc.parseTypeFromAstType(stmt.TypeHint)
@@ -55,12 +74,7 @@ func (tc *TypeChecker) CheckLetStatement(stmt *ast.LetStatement) Type {
 		}
 
 		// Predeclare the function in the environment
-		tc.env.Set(stmt.Name.Value, &Symbol{
-			Name:   stmt.Name.Value,
-			Type:   declaredType,
-			Line:   stmt.Name.Token.Line,
-			Column: stmt.Name.Token.Column,
-		})
+		tc.declare(stmt.Name, declaredType)
 
 		// Now check the function literal (it can see itself)
 		valueType = tc.CheckExpression(stmt.Value)
@@ -74,54 +88,102 @@ func (tc *TypeChecker) CheckLetStatement(stmt *ast.LetStatement) Type {
 		}
 
 		// Store in environment
-		tc.env.Set(stmt.Name.Value, &Symbol{
-			Name:   stmt.Name.Value,
-			Type:   declaredType,
-			Line:   stmt.Name.Token.Line,
-			Column: stmt.Name.Token.Column,
-		})
+		tc.checkShadowing(stmt.Name)
+		tc.declare(stmt.Name, declaredType)
+	}
+
+	// A value that always returns never reaches the binding, and neither
+	// does control reach the statement after the let.
+	if isNever(valueType) {
+		return &NeverType{}
 	}
 
 	// Type compatibility check
 	if !declaredType.Equals(valueType) {
-		tc.addError(
+		err := tc.addError(diagnostic.CodeAnnotationMismatch,
 			fmt.Sprintf("type mismatch: declared %s but got %s",
 				declaredType.String(), valueType.String()),
-			stmt.Token.Line, stmt.Token.Column,
+			stmt.Value.Span(),
 		)
+		if stmt.TypeHint != nil {
+			err.Related = append(err.Related, diagnostic.Label{Span: stmt.TypeHint.Span(), Message: "expected because of this annotation"})
+		}
 	}
 
 	return &VoidType{}
 }
 
+// declare binds the name of a let or a parameter in the current scope.
+func (tc *TypeChecker) declare(name *ast.Identifier, t Type) *Symbol {
+	sym := &Symbol{
+		Name:   name.Value,
+		Type:   t,
+		Line:   name.Token.Line,
+		Column: name.Token.Column,
+		Span:   name.Span(),
+	}
+	tc.env.Set(name.Value, sym)
+	tc.resolve(name, sym)
+	return sym
+}
+
 func (tc *TypeChecker) CheckReturnStatement(stmt *ast.ReturnStatement) Type {
+	if tc.currentReturn == nil && tc.inTest {
+		// A return ends the test early. Tests have no value, so any will do.
+		tc.CheckExpression(stmt.ReturnValue)
+		return &NeverType{}
+	}
 	if tc.currentReturn == nil {
-		tc.addError("return statement outside of function", stmt.Token.Line, stmt.Token.Column)
+		tc.addError(diagnostic.CodeReturnOutsideFunction, "return statement outside of function", stmt.Span())
 		return &UnknownType{}
 	}
 
 	exprType := tc.CheckExpression(stmt.ReturnValue)
 
 	if !tc.currentReturn.Equals(exprType) {
-		tc.addError(
+		tc.addError(diagnostic.CodeReturnMismatch,
 			fmt.Sprintf("return type mismatch: expected %s, got %s", tc.currentReturn.String(), exprType.String()),
-			stmt.Token.Line,
-			stmt.Token.Column,
+			stmt.Span(),
 		)
 	}
 
-	return exprType // <- Return the type of the returned expression
+	// Control never continues past a return, so it has no value of its own.
+	return &NeverType{}
+}
+
+// CheckTestStatement checks the body of a test in a scope of its own, as it
+// runs apart from the other tests. Tests belong at the top level, and may
+// return to end early.
+func (tc *TypeChecker) CheckTestStatement(stmt *ast.TestStatement) Type {
+	if tc.blocks > 0 {
+		tc.addError(diagnostic.CodeNestedTest, "test declarations must be at the top level", stmt.Token.Span)
+	}
+	tc.CheckExpression(stmt.Name)
+
+	oldEnv := tc.env
+	oldReturn := tc.currentReturn
+	tc.env = NewEnclosedEnvironment(oldEnv)
+	tc.currentReturn = nil
+	tc.inTest = true
+
+	tc.CheckBlockStatement(stmt.Body)
+	tc.checkUnused(tc.env)
+
+	tc.env = oldEnv
+	tc.currentReturn = oldReturn
+	tc.inTest = false
+	return &VoidType{}
 }
 
 func (tc *TypeChecker) CheckExpressionStatement(stmt *ast.ExpressionStatement) Type {
 	if stmt.Expression == nil {
-		tc.addError("empty expression statement", stmt.Token.Line, stmt.Token.Column)
+		tc.addError(diagnostic.CodeInternal, "empty expression statement", stmt.Span())
 		return &UnknownType{}
 	}
 
 	exprType := tc.CheckExpression(stmt.Expression)
 
-	if stmt.HasSemicolon {
+	if stmt.HasSemicolon && !isNever(exprType) {
 		return &VoidType{} // semicolon → discard value
 	}
 
@@ -129,9 +191,25 @@ func (tc *TypeChecker) CheckExpressionStatement(stmt *ast.ExpressionStatement) T
 }
 
 func (tc *TypeChecker) CheckBlockStatement(block *ast.BlockStatement) Type {
+	return tc.record(block, tc.checkBlockStatement(block))
+}
+
+func (tc *TypeChecker) checkBlockStatement(block *ast.BlockStatement) Type {
+	tc.blocks++
+	defer func() { tc.blocks-- }()
+
 	var lastType Type = &VoidType{}
-	for _, stmt := range block.Statements {
+	returns := false
+	for i, stmt := range block.Statements {
 		lastType = tc.CheckStatement(stmt)
+		returns = returns || isNever(lastType)
+		tc.checkDiscarded(stmt, i == len(block.Statements)-1)
+	}
+	tc.checkUnreachable(block.Statements)
+
+	// A block that returns part way through never reaches its last statement.
+	if returns {
+		return &NeverType{}
 	}
 	return lastType
 }
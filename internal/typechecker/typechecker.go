@@ -3,6 +3,9 @@ package typechecker
 import (
 	"fmt"
 	"sigil/internal/ast"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sort"
 )
 
 // Symbol represents a variable in the symbol table
@@ -11,6 +14,10 @@ type Symbol struct {
 	Type   Type
 	Line   int
 	Column int
+	Span   lexer.Span // the name in its declaration, zero for builtins
+
+	used  bool // read somewhere, for the unused-variable rule
+	param bool
 }
 
 // Environment for symbol table with scope support
@@ -65,26 +72,83 @@ func (e *Environment) Set(name string, symbol *Symbol) {
 	e.store[name] = symbol
 }
 
+// Names returns every name visible from this scope, including the ones
+// declared in enclosing scopes and the builtins.
+func (e *Environment) Names() []string {
+	names := []string{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			names = append(names, name)
+		}
+	}
+	for name := range builtinTypes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Symbols returns the symbols declared directly in this scope, sorted by name.
+func (e *Environment) Symbols() []*Symbol {
+	symbols := make([]*Symbol, 0, len(e.store))
+	for _, symbol := range e.store {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+	return symbols
+}
+
 // Type Checker
 type TypeChecker struct {
 	env           *Environment
 	errors        []*TypeError
-	currentReturn Type // The expected return type of the enclosing function
+	warnings      []*Warning
+	result        *Result // types and symbols recorded while checking
+	currentReturn Type    // The expected return type of the enclosing function
+	inTest        bool    // whether a return ends a test rather than a function
+	blocks        int     // the number of blocks around the statement being checked
 }
 
 func New() *TypeChecker {
+	return NewWithEnvironment(NewEnvironment())
+}
+
+// NewWithEnvironment creates a type checker that declares top level bindings
+// in env, so that several programs can be checked against shared globals.
+func NewWithEnvironment(env *Environment) *TypeChecker {
 	return &TypeChecker{
-		env:    NewEnvironment(),
+		env:    env,
 		errors: []*TypeError{},
+		result: newResult(),
 	}
 }
 
-func (tc *TypeChecker) addError(message string, line, column int) {
-	tc.errors = append(tc.errors, &TypeError{
+// Environment returns the scope top level bindings are declared in.
+func (tc *TypeChecker) Environment() *Environment {
+	return tc.env
+}
+
+func (tc *TypeChecker) addError(code, message string, span lexer.Span) *TypeError {
+	err := &TypeError{
+		Code:    code,
 		Message: message,
-		Line:    line,
-		Column:  column,
-	})
+		Line:    span.Start.Line,
+		Column:  span.Start.Column,
+		Span:    span,
+	}
+	tc.errors = append(tc.errors, err)
+	return err
+}
+
+// symbolFor returns the symbol expr refers to when it is a plain name.
+func (tc *TypeChecker) symbolFor(expr ast.Expression) *Symbol {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	symbol, _ := tc.env.Get(ident.Value)
+	return symbol
 }
 
 func (tc *TypeChecker) Errors() []*TypeError {
@@ -95,7 +159,17 @@ func (tc *TypeChecker) HasErrors() bool {
 	return len(tc.errors) > 0
 }
 
+// ResolveType converts a type annotation into its checked type. Unknown type
+// names are reported as errors and resolve to UnknownType.
+func (tc *TypeChecker) ResolveType(t ast.Type) Type {
+	return tc.parseTypeFromAstType(t)
+}
+
 func (tc *TypeChecker) parseTypeFromAstType(t ast.Type) Type {
+	return tc.record(t, tc.convertType(t))
+}
+
+func (tc *TypeChecker) convertType(t ast.Type) Type {
 	switch tt := t.(type) {
 	case *ast.SimpleType:
 		switch tt.Name {
@@ -108,7 +182,8 @@ func (tc *TypeChecker) parseTypeFromAstType(t ast.Type) Type {
 		case VOID:
 			return &VoidType{}
 		default:
-			tc.addError(fmt.Sprintf("unknown type: %s", tt.Name), tt.Token.Line, tt.Token.Column)
+			tc.addError(diagnostic.CodeUnknownType, fmt.Sprintf("unknown type: %s", tt.Name), tt.Span()).
+				suggest(tt.Name, []string{NUMBER, STRING, BOOLEAN, VOID})
 			return &UnknownType{}
 		}
 	case *ast.FunctionType:
@@ -1,8 +1,10 @@
 package typechecker
 
 import (
+	"sigil/internal/ast"
 	"sigil/internal/lexer"
 	"sigil/internal/parser"
+	"strings"
 	"testing"
 )
 
@@ -23,7 +25,7 @@ func evalType(t *testing.T, input string) Type {
 		t.Fatalf("type checking errors: %v", tc.Errors())
 	}
 
-	return tp
+	return tp.Type
 }
 
 func TestTypeCheckerBasics(t *testing.T) {
@@ -148,7 +150,7 @@ func TestTypeCheckerExtended(t *testing.T) {
 		p := parser.New(l)
 		program := p.ParseProgram()
 		tc := New()
-		got := tc.CheckProgram(program)
+		got := tc.CheckProgram(program).Type
 
 		if tt.shouldError && !tc.HasErrors() {
 			t.Errorf("expected errors for input %q, got none", tt.input)
@@ -162,3 +164,322 @@ func TestTypeCheckerExtended(t *testing.T) {
 		}
 	}
 }
+
+func TestTypeErrorLocations(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedText string // source covered by the error
+		relatedText  string // source covered by the first related label, if any
+	}{
+		{"let x = 1 + true;", "1 + true", ""},
+		{"let x: String = 1;", "1", "String"},
+		{"let f = fun(a: Number): Number { a };\nf(\"a\")", `"a"`, "f"},
+		{"let n = 1;\nn(2)", "n", "n"},
+		{"let n = 1;\nn = \"a\";", `"a"`, "n"},
+		{"fun(): Number { let a = 1;\n\"a\" }", `"a"`, "Number"},
+		{"-true", "true", ""},
+		{"missing", "missing", ""},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parse errors: %v", tt.input, p.Errors())
+		}
+
+		tc := New()
+		tc.CheckProgram(program)
+		if !tc.HasErrors() {
+			t.Fatalf("input %q: expected a type error", tt.input)
+		}
+
+		err := tc.Errors()[0]
+		if got := tt.input[err.Span.Start.Offset:err.Span.End.Offset]; got != tt.expectedText {
+			t.Errorf("input %q: error covers %q, want %q", tt.input, got, tt.expectedText)
+		}
+		if err.Line != err.Span.Start.Line || err.Column != err.Span.Start.Column {
+			t.Errorf("input %q: Line/Column %d:%d disagree with span %+v", tt.input, err.Line, err.Column, err.Span)
+		}
+
+		if tt.relatedText == "" {
+			if len(err.Related) != 0 {
+				t.Errorf("input %q: unexpected related labels %+v", tt.input, err.Related)
+			}
+			continue
+		}
+		if len(err.Related) == 0 {
+			t.Errorf("input %q: expected a related label at %q", tt.input, tt.relatedText)
+			continue
+		}
+		related := err.Related[0].Span
+		if got := tt.input[related.Start.Offset:related.End.Offset]; got != tt.relatedText {
+			t.Errorf("input %q: related label covers %q, want %q", tt.input, got, tt.relatedText)
+		}
+	}
+}
+
+func TestTypeErrorSuggestions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the note on the first error, empty for none
+	}{
+		{"let count = 1;\ncont + 1", "did you mean `count`?"},
+		{"let total = 0;\nfun(n: Number): Number { totl + n }", "did you mean `total`?"},
+		{"fun(value: Number): Number { valeu }", "did you mean `value`?"},
+		{`prnt("hi")`, "did you mean `print`?"},
+		{"let counter = 1;\nconter = 2;", "did you mean `counter`?"},
+		{"let x: Numbr = 1;", "did you mean `Number`?"},
+		{"let x: string = \"a\";", "did you mean `String`?"},
+		{"let x = 1;\nzebra", ""},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parse errors: %v", tt.input, p.Errors())
+		}
+
+		tc := New()
+		tc.CheckProgram(program)
+		if !tc.HasErrors() {
+			t.Fatalf("input %q: expected a type error", tt.input)
+		}
+
+		notes := tc.Errors()[0].Notes
+		if tt.expected == "" {
+			if len(notes) != 0 {
+				t.Errorf("input %q: expected no notes, got %v", tt.input, notes)
+			}
+			continue
+		}
+		if len(notes) != 1 || notes[0] != tt.expected {
+			t.Errorf("input %q: expected note %q, got %v", tt.input, tt.expected, notes)
+		}
+	}
+}
+
+func TestLintWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // "rule: covered source" for each warning, in order
+	}{
+		{"let f = fun(a: Number, b: Number): Number { a };", []string{"unused-variable: b"}},
+		{"let f = fun(a: Number): Number { let tmp = 1; a };", []string{"unused-variable: tmp"}},
+		{"let f = fun(_a: Number): Number { let _tmp = 1; 2 };", nil},
+		{"let f = fun(a: Number): Number { let b = 1; b = 2; a };", []string{"unused-variable: b"}},
+		{"let f = fun(n: Number): Number { if (n < 1) { 1 } else { n * f(n - 1) } };", nil},
+		{"let x = 1;\nlet f = fun(n: Number): Number { let x = x + n; x };", []string{"shadowing: x"}},
+		{"let f = fun(n: Number): Number { let len = n; len };", nil},
+		{"let f = fun(n: Number): Number { return n; n + 1 };", []string{"unreachable-code: n + 1"}},
+		{"let f = fun(n: Number): Number { return n; };", nil},
+		{"let f = fun(n: Number): Number { if (n < 0) { return 0; } else { return n; } n };", []string{"unreachable-code: n"}},
+		{"let f = fun(n: Number): Number { if (n < 0) { return 0; } n };", nil},
+		{"let f = fun(n: Number): Number { n + 1; n };", []string{"discarded-value: n + 1"}},
+		{"let f = fun(n: Number): Void { n; };", []string{"discarded-value: n"}},
+		{"let f = fun(n: Number): Number { println(\"x\"); n };", nil},
+		{"1 + 1;\n2", []string{"discarded-value: 1 + 1"}},
+		{"1 + 1;", nil},
+		{"let f = fun(): Number { 1 };\nf == f", []string{"function-comparison: f == f"}},
+		{"1 == 1", nil},
+		{"test \"t\" { let unused = 1; }", []string{"unused-variable: unused"}},
+		{"let x = 1;\ntest \"t\" { let x = 2; assert_eq(2, x); }", []string{"shadowing: x"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parse errors: %v", tt.input, p.Errors())
+		}
+
+		tc := New()
+		tc.CheckProgram(program)
+		if tc.HasErrors() {
+			t.Fatalf("input %q: type errors: %v", tt.input, tc.Errors())
+		}
+
+		got := []string{}
+		for _, w := range tc.Warnings() {
+			got = append(got, w.Rule+": "+tt.input[w.Span.Start.Offset:w.Span.End.Offset])
+			if rule, ok := LookupRule(w.Rule); !ok || rule.Code != w.Code {
+				t.Errorf("input %q: warning %v has code %q", tt.input, w, w.Code)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("input %q: expected warnings %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTestStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string // of the first error, "" for none
+	}{
+		{`let n = 1; test "t" { assert_eq(1, n); assert(n > 0); }`, ""},
+		{`test "t" { let local = 1; assert(local == 1); }`, ""},
+		{`test "t" { let local = 1; } local`, "E0201"},
+		{`test "t" { assert(1); }`, "E0211"},
+		{`test "t" { assert_eq(1); }`, "E0210"},
+		{`test "t" { assert_eq(1, "a"); }`, "E0211"},
+		{`test "t" { assert_eq("a", "a"); }`, ""},
+		{`test "t" { return 1; }`, ""},
+		{`test "t" { if (true) { return 1; } else { 0 }; assert(false); }`, ""},
+		{`test "t" { let f = fun(): Number { return "a"; }; f(); }`, "E0213"},
+		{`test "t" {} return 1;`, "E0212"},
+		{`if (true) { test "t" {} }`, "E0215"},
+		{`let f = fun(): Void { test "t" {} };`, "E0215"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parse errors: %v", tt.input, p.Errors())
+		}
+
+		result := New().CheckProgram(program)
+		got := ""
+		if len(result.Errors) > 0 {
+			got = result.Errors[0].Code
+		}
+		if got != tt.code {
+			t.Errorf("input %q: got error %q, want %q (%v)", tt.input, got, tt.code, result.Errors)
+		}
+	}
+}
+
+func TestDefiniteReturn(t *testing.T) {
+	tests := []struct {
+		input   string
+		missing string // source the missing return points at, empty when the body is fine
+	}{
+		{"fun(n: Number): Number { if (n < 0) { return 0; } return n; }", ""},
+		{"fun(n: Number): Number { if (n < 0) { return 0; } else { return n; } }", ""},
+		{"fun(n: Number): Number { if (n < 0) { return 0; } else { n } }", ""},
+		{"fun(n: Number): Number { if (n < 0) { 0 } else { return n; } }", ""},
+		{"fun(n: Number): Number { if (n < 0) { return 0; } else { return n; }; }", ""},
+		{"fun(n: Number): Number { return n; }", ""},
+		{"fun(n: Number): Number { let m = n; return m; }", ""},
+		{"fun(n: Number): Number { let y = if (n < 0) { return 0; } else { return 1; }; }", ""},
+		{"fun(n: Number): Number { let y: Number = if (n < 0) { return 0; } else { return 1; }; }", ""},
+		{"fun(n: Number): Number { let y = if (n < 0) { return 0; } else { 1 }; }", "let y = if (n < 0) { return 0; } else { 1 };"},
+		{"fun(n: Number): Void { if (n < 0) { return println(\"neg\"); } }", ""},
+		{"fun(n: Number): Number { if (n < 0) { return 0; } }", "n < 0"},
+		{"fun(n: Number): Number { if (n < 0) { return 0; } else { n + 1; } }", "n + 1;"},
+		{"fun(n: Number): Number { if (n < 0) { n; } else { return 0; } }", "n;"},
+		{"fun(n: Number): Number { let m = n; }", "let m = n;"},
+		{"fun(n: Number): Number { n; }", "n;"},
+		{"fun(n: Number): Number { }", "{ }"},
+		{"fun(n: Number): Number { if (n < 0) { if (n < 5) { return 1; } } else { return 2; } }", "n < 5"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("input %q: parse errors: %v", tt.input, p.Errors())
+		}
+
+		tc := New()
+		tc.CheckProgram(program)
+
+		if tt.missing == "" {
+			if tc.HasErrors() {
+				t.Errorf("input %q: unexpected errors %v", tt.input, tc.Errors())
+			}
+			continue
+		}
+		if len(tc.Errors()) != 1 {
+			t.Errorf("input %q: expected one error, got %v", tt.input, tc.Errors())
+			continue
+		}
+		err := tc.Errors()[0]
+		if err.Code != "E0214" || err.Message != "missing return on some paths" {
+			t.Errorf("input %q: expected a missing return, got %v", tt.input, err)
+		}
+		if got := tt.input[err.Span.Start.Offset:err.Span.End.Offset]; got != tt.missing {
+			t.Errorf("input %q: error covers %q, want %q", tt.input, got, tt.missing)
+		}
+	}
+}
+
+// collectNodes lists the statements and expressions below node, in source
+// order, and the type annotations they carry.
+func collectNodes(node ast.Node) []ast.Node {
+	var nodes []ast.Node
+	ast.Inspect(node, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionParameter); !ok && n != nil {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	return nodes
+}
+
+func TestResultRecordsTypesAndSymbols(t *testing.T) {
+	input := `let total: Number = 0;
+let add = fun(a: Number, b: Number): Number { return a + b; };
+total = add(total, len("ab"));
+if (total > 1) { println("big"); } else { println("small"); }`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	result := New().CheckProgram(program)
+	if len(result.Errors) > 0 {
+		t.Fatalf("type checking errors: %v", result.Errors)
+	}
+	if result.Type.String() != "Void" {
+		t.Errorf("program type is %s, want Void", result.Type)
+	}
+
+	declared := map[string]*Symbol{}
+	for _, node := range collectNodes(program) {
+		typ := result.TypeOf(node)
+		if typ == nil {
+			t.Errorf("no type recorded for %T %q", node, node.String())
+			continue
+		}
+
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			continue
+		}
+		sym := result.SymbolOf(ident)
+		if sym == nil {
+			t.Errorf("identifier %s at %d:%d was not resolved", ident.Value, ident.Token.Line, ident.Token.Column)
+			continue
+		}
+		if !sym.Type.Equals(typ) {
+			t.Errorf("identifier %s has type %s but its symbol %s", ident.Value, typ, sym.Type)
+		}
+		if sym.Span == ident.Span() {
+			declared[ident.Value] = sym
+		} else if d, ok := declared[ident.Value]; ok && d != sym {
+			t.Errorf("identifier %s at %d:%d resolved to another symbol than its declaration", ident.Value, ident.Token.Line, ident.Token.Column)
+		}
+	}
+
+	for _, name := range []string{"total", "add", "a", "b"} {
+		if declared[name] == nil {
+			t.Errorf("no declaration recorded for %s", name)
+		}
+	}
+
+	call := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.AssignmentExpression).Value.(*ast.CallExpression)
+	if got := result.TypeOf(call).String(); got != "Number" {
+		t.Errorf("add(...) has type %s, want Number", got)
+	}
+	if got := result.TypeOf(call.Function).String(); got != "(Number, Number) -> Number" {
+		t.Errorf("add has type %s", got)
+	}
+	builtin := result.SymbolOf(call.Arguments[1].(*ast.CallExpression).Function.(*ast.Identifier))
+	if builtin == nil || builtin.Span.Start.Line != 0 {
+		t.Errorf("len should resolve to a builtin symbol, got %+v", builtin)
+	}
+}
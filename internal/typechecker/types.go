@@ -2,6 +2,8 @@ package typechecker
 
 import (
 	"fmt"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
 	"strings"
 )
 
@@ -11,6 +13,7 @@ const (
 	STRING  = "String"
 	VOID    = "Void"
 	UNKNOWN = "Unknown"
+	NEVER   = "Never"
 )
 
 // Type represents a type in the language
@@ -31,6 +34,7 @@ type StringType struct{}
 type BoolType struct{}
 type VoidType struct{}    // for statements that don't return values
 type UnknownType struct{} // For errors during type checking
+type NeverType struct{}   // for code that always returns, so never produces a value
 
 func (nt *NumberType) String() string { return NUMBER }
 func (nt *NumberType) Equals(other Type) bool {
@@ -62,6 +66,18 @@ func (ut *UnknownType) Equals(other Type) bool {
 	return ok
 }
 
+func (nt *NeverType) String() string { return NEVER }
+func (nt *NeverType) Equals(other Type) bool {
+	_, ok := other.(*NeverType)
+	return ok
+}
+
+// isNever reports whether t is the type of code that always returns.
+func isNever(t Type) bool {
+	_, ok := t.(*NeverType)
+	return ok
+}
+
 // FunctionType represents a function with parameters and a return type
 type FunctionType struct {
 	ParamTypes []Type
@@ -94,11 +110,42 @@ func (ft *FunctionType) Equals(other Type) bool {
 
 // Type error with position information
 type TypeError struct {
+	Code    string // see diagnostic.Explain
 	Message string
 	Line    int
 	Column  int
+	Span    lexer.Span
+	Related []diagnostic.Label // secondary locations such as declarations
+	Notes   []string
 }
 
 func (te *TypeError) Error() string {
 	return fmt.Sprintf("Type error at line %d, column %d: %s", te.Line, te.Column, te.Message)
 }
+
+func (te *TypeError) Diagnostic() *diagnostic.Diagnostic {
+	return &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     te.Code,
+		Message:  te.Message,
+		Span:     te.Span,
+		Related:  te.Related,
+		Notes:    te.Notes,
+	}
+}
+
+// declaredHere points the error at the declaration of sym, when it has one.
+func (te *TypeError) declaredHere(sym *Symbol) *TypeError {
+	if sym != nil && sym.Span.Start.Line > 0 {
+		te.Related = append(te.Related, diagnostic.Label{Span: sym.Span, Message: sym.Name + " declared here"})
+	}
+	return te
+}
+
+// suggest adds a "did you mean" note when one of candidates is close to name.
+func (te *TypeError) suggest(name string, candidates []string) *TypeError {
+	if suggestion, ok := diagnostic.Suggest(name, candidates); ok {
+		te.Notes = append(te.Notes, diagnostic.DidYouMean(suggestion))
+	}
+	return te
+}
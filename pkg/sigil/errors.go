@@ -0,0 +1,82 @@
+package sigil
+
+import (
+	"fmt"
+	"sigil/internal/diagnostic"
+	"strings"
+)
+
+// ErrorKind tells which stage of the pipeline reported an error.
+type ErrorKind string
+
+const (
+	ParseError   ErrorKind = "parse"
+	TypeError    ErrorKind = "type"
+	RuntimeError ErrorKind = "runtime"
+)
+
+// Error is a problem reported while compiling or running a program. Line and
+// Column are zero when the position is unknown. Code identifies the kind of
+// problem, `sigil explain CODE` describes it.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Line    int
+	Column  int
+
+	// EndLine and EndColumn are just past the source the error is about, and
+	// zero when only its start is known.
+	EndLine   int
+	EndColumn int
+
+	Label   string    // what is wrong at the span, may be empty
+	Related []Related // other places that explain the error
+	Notes   []string  // further help, such as suggestions
+}
+
+// Related marks a place in the source that helps explain an error, such as
+// where a variable was declared.
+type Related struct {
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Message   string
+}
+
+// describe fills in the details of the error from the report of the stage
+// that found it.
+func (e *Error) describe(d *diagnostic.Diagnostic) {
+	e.EndLine = d.Span.End.Line
+	e.EndColumn = d.Span.End.Column
+	e.Label = d.Label
+	for _, label := range d.Related {
+		e.Related = append(e.Related, Related{
+			Line:      label.Span.Start.Line,
+			Column:    label.Span.Start.Column,
+			EndLine:   label.Span.End.Line,
+			EndColumn: label.Span.End.Column,
+			Message:   label.Message,
+		})
+	}
+	e.Notes = d.Notes
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s error: %s", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("%s error at line %d, column %d: %s", e.Kind, e.Line, e.Column, e.Message)
+}
+
+// ErrorList is returned when compilation reports one or more errors.
+type ErrorList []*Error
+
+func (el ErrorList) Error() string {
+	msgs := make([]string, len(el))
+	for i, err := range el {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,238 @@
+package sigil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sigil/internal/backends/interpreter"
+	"sigil/internal/diagnostic"
+	"sigil/internal/lexer"
+	"sigil/internal/parser"
+	"sigil/internal/typechecker"
+)
+
+// NativeFunc is a host function callable from Sigil. Arguments arrive as Go
+// values following the conversions documented on Engine.Eval, and the result
+// is converted back the same way. A non-nil error aborts the program with a
+// runtime error.
+type NativeFunc func(args ...any) (any, error)
+
+// RegisterNative exposes fn to programs under name with the given Sigil
+// signature, for example "(String, Number) -> Boolean". The type checker
+// verifies every call against the signature before the program runs.
+func (e *Engine) RegisterNative(name string, signature string, fn NativeFunc) error {
+	if fn == nil {
+		return fmt.Errorf("cannot register %s: expected a function, got nil", name)
+	}
+
+	fnType, err := parseSignature(signature)
+	if err != nil {
+		return fmt.Errorf("cannot register %s: %w", name, err)
+	}
+
+	e.register(name, fnType, fn)
+	return nil
+}
+
+// RegisterFunc exposes a Go function to programs under name, deriving its
+// Sigil signature by reflection. Parameters and the result may be bool,
+// string or any numeric type, which map to Boolean, String and Number. The
+// function may return nothing, a value, an error, or a value and an error;
+// a function without a value result has the Void return type. A call that
+// passes a fraction or an out of range number to an integer parameter fails
+// with a runtime error.
+func (e *Engine) RegisterFunc(name string, fn any) error {
+	fnValue := reflect.ValueOf(fn)
+	if fnValue.Kind() != reflect.Func {
+		return fmt.Errorf("cannot register %s: expected a function, got %T", name, fn)
+	}
+
+	fnType, err := signatureOf(fnValue.Type())
+	if err != nil {
+		return fmt.Errorf("cannot register %s: %w", name, err)
+	}
+
+	e.register(name, fnType, reflectNative(fnValue))
+	return nil
+}
+
+func (e *Engine) register(name string, fnType *typechecker.FunctionType, fn NativeFunc) {
+	e.types.Set(name, &typechecker.Symbol{Name: name, Type: fnType})
+	e.evaluator.Define(name, &interpreter.NativeFunction{
+		Name:  name,
+		Arity: len(fnType.ParamTypes),
+		Fn: func(args ...interpreter.Object) interpreter.Object {
+			goArgs := make([]any, len(args))
+			for i, arg := range args {
+				goArgs[i] = fromObject(arg)
+			}
+
+			result, err := fn(goArgs...)
+			if err != nil {
+				return &interpreter.Error{Code: diagnostic.CodeHostFunction, Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+
+			if _, ok := fnType.ReturnType.(*typechecker.VoidType); ok {
+				return interpreter.NULL
+			}
+
+			obj, typ, err := toObject(result)
+			if err != nil {
+				return &interpreter.Error{Code: diagnostic.CodeHostFunction, Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+			if !typ.Equals(fnType.ReturnType) {
+				return &interpreter.Error{Code: diagnostic.CodeHostFunction, Message: fmt.Sprintf(
+					"%s: returned %s, declared %s", name, typ, fnType.ReturnType)}
+			}
+			return obj
+		},
+	})
+}
+
+func parseSignature(signature string) (*typechecker.FunctionType, error) {
+	p := parser.New(lexer.New(signature))
+	astType := p.ParseType()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid signature %q: %s", signature, errs[0])
+	}
+
+	tc := typechecker.New()
+	typ := tc.ResolveType(astType)
+	if tc.HasErrors() {
+		return nil, fmt.Errorf("invalid signature %q: %s", signature, tc.Errors()[0].Message)
+	}
+
+	fnType, ok := typ.(*typechecker.FunctionType)
+	if !ok {
+		return nil, fmt.Errorf("signature %q is not a function type", signature)
+	}
+	return fnType, nil
+}
+
+var errorType = reflect.TypeFor[error]()
+
+func signatureOf(t reflect.Type) (*typechecker.FunctionType, error) {
+	if t.IsVariadic() {
+		return nil, errors.New("variadic functions are not supported")
+	}
+
+	fnType := &typechecker.FunctionType{ReturnType: &typechecker.VoidType{}}
+	for i := range t.NumIn() {
+		typ, err := typeOfKind(t.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+		}
+		fnType.ParamTypes = append(fnType.ParamTypes, typ)
+	}
+
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) != errorType {
+			typ, err := typeOfKind(t.Out(0))
+			if err != nil {
+				return nil, fmt.Errorf("result: %w", err)
+			}
+			fnType.ReturnType = typ
+		}
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, errors.New("second result must be an error")
+		}
+		typ, err := typeOfKind(t.Out(0))
+		if err != nil {
+			return nil, fmt.Errorf("result: %w", err)
+		}
+		fnType.ReturnType = typ
+	default:
+		return nil, errors.New("functions may return at most a value and an error")
+	}
+
+	return fnType, nil
+}
+
+func typeOfKind(t reflect.Type) (typechecker.Type, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &typechecker.BoolType{}, nil
+	case reflect.String:
+		return &typechecker.StringType{}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return &typechecker.NumberType{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// reflectNative adapts a Go function to a NativeFunc, converting Sigil
+// numbers to whichever numeric type each parameter declares.
+func reflectNative(fn reflect.Value) NativeFunc {
+	t := fn.Type()
+	return func(args ...any) (any, error) {
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			v, err := convertArg(reflect.ValueOf(arg), t.In(i))
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", i+1, err)
+			}
+			in[i] = v
+		}
+
+		out := fn.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return nil, nil
+		}
+
+		switch result := out[0]; result.Kind() {
+		case reflect.Bool:
+			return result.Bool(), nil
+		case reflect.String:
+			return result.String(), nil
+		default:
+			return result.Convert(reflect.TypeFor[float64]()).Float(), nil
+		}
+	}
+}
+
+// convertArg converts v to the type of a parameter. A number must be whole
+// to pass as an integer and must fit the parameter, rather than be truncated
+// or wrap around.
+func convertArg(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if v.Kind() != reflect.Float64 {
+		return v.Convert(t), nil
+	}
+
+	f := v.Float()
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f != math.Trunc(f) {
+			return out, fmt.Errorf("%v is not a whole number, as %s requires", f, t)
+		}
+		if f < math.MinInt64 || f >= math.MaxInt64 || out.OverflowInt(int64(f)) {
+			return out, fmt.Errorf("%v is out of range for %s", f, t)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f != math.Trunc(f) {
+			return out, fmt.Errorf("%v is not a whole number, as %s requires", f, t)
+		}
+		if f < 0 || f >= math.MaxUint64 || out.OverflowUint(uint64(f)) {
+			return out, fmt.Errorf("%v is out of range for %s", f, t)
+		}
+	case reflect.Float32:
+		if out.OverflowFloat(f) {
+			return out, fmt.Errorf("%v is out of range for %s", f, t)
+		}
+	}
+	return v.Convert(t), nil
+}
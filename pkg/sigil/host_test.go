@@ -0,0 +1,149 @@
+package sigil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	engine := New()
+
+	err := engine.RegisterFunc("startsWith", func(s, prefix string) bool {
+		return strings.HasPrefix(s, prefix)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = engine.RegisterFunc("repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{`startsWith("sigil", "si")`, true},
+		{`startsWith("sigil", "go")`, false},
+		{`repeat("ab", 3)`, "ababab"},
+		{`len(repeat("ab", 2))`, 4.0},
+	}
+
+	for _, tt := range tests {
+		got, err := engine.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("input %q: got %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	engine := New()
+	if err := engine.RegisterFunc("fail", func(n float64) (float64, error) {
+		return 0, errors.New("boom")
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := engine.Eval("fail(1)")
+	var sigilErr *Error
+	if !errors.As(err, &sigilErr) || sigilErr.Kind != RuntimeError {
+		t.Fatalf("expected runtime error, got %v", err)
+	}
+	if sigilErr.Message != "fail: boom" || sigilErr.Code != "E0308" {
+		t.Errorf("got message %q with code %q", sigilErr.Message, sigilErr.Code)
+	}
+	if sigilErr.Line != 1 || sigilErr.Column != 1 {
+		t.Errorf("got location %d:%d, want the call at 1:1", sigilErr.Line, sigilErr.Column)
+	}
+
+	if _, err := engine.Eval(`fail("one")`); err == nil {
+		t.Errorf("expected a type error for a String argument")
+	}
+
+	invalid := []any{
+		42,
+		func(xs []int) {},
+		func() (int, int) { return 0, 0 },
+		func(args ...string) {},
+	}
+	for _, fn := range invalid {
+		if err := engine.RegisterFunc("bad", fn); err == nil {
+			t.Errorf("expected an error registering %T", fn)
+		}
+	}
+}
+
+func TestRegisterNative(t *testing.T) {
+	engine := New()
+	flags := map[string]bool{"beta": true}
+
+	err := engine.RegisterNative("flag", "(String) -> Boolean", func(args ...any) (any, error) {
+		return flags[args[0].(string)], nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := engine.Eval(`if (flag("beta")) { "on" } else { "off" }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "on" {
+		t.Errorf("got %#v, want \"on\"", got)
+	}
+
+	if _, err := engine.Eval(`flag(1)`); err == nil {
+		t.Errorf("expected a type error for a Number argument")
+	}
+
+	noop := func(args ...any) (any, error) { return nil, nil }
+	for _, signature := range []string{"Number", "(Strng) -> Boolean", "(Number -> Number"} {
+		if err := engine.RegisterNative("bad", signature, noop); err == nil {
+			t.Errorf("expected an error for signature %q", signature)
+		}
+	}
+	if err := engine.RegisterNative("bad", "() -> Void", nil); err == nil {
+		t.Errorf("expected an error registering a nil function")
+	}
+}
+
+func TestRegisterFuncConvertsNumbers(t *testing.T) {
+	engine := New()
+	if err := engine.RegisterFunc("index", func(i int) int { return i }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := engine.RegisterFunc("size", func(n uint8) uint8 { return n }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for input, want := range map[string]float64{"index(-3)": -3, "size(255)": 255} {
+		got, err := engine.Eval(input)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", input, got, err, want)
+		}
+	}
+
+	failing := map[string]string{
+		"index(1.5)": "index: argument 1: 1.5 is not a whole number, as int requires",
+		"size(-1)":   "size: argument 1: -1 is out of range for uint8",
+		"size(256)":  "size: argument 1: 256 is out of range for uint8",
+	}
+	for input, want := range failing {
+		_, err := engine.Eval(input)
+		var sigilErr *Error
+		if !errors.As(err, &sigilErr) || sigilErr.Kind != RuntimeError || sigilErr.Message != want {
+			t.Errorf("%s: got %v, want runtime error %q", input, err, want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package sigil embeds the Sigil language in Go programs.
+//
+// An Engine compiles source code into type checked programs and runs them
+// against a persistent global environment:
+//
+//	engine := sigil.New(sigil.WithStdout(&out))
+//	if err := engine.Define("limit", 10); err != nil {
+//		return err
+//	}
+//	result, err := engine.Eval("limit * 2")
+package sigil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sigil/internal/ast"
+	"sigil/internal/backends"
+	"sigil/internal/backends/interpreter"
+	"sigil/internal/compiler"
+	"sigil/internal/typechecker"
+)
+
+// Engine compiles and runs Sigil programs. Top level bindings created by a
+// program, or defined from Go, stay visible to every later program run by
+// the same engine. An Engine is not safe for concurrent use.
+type Engine struct {
+	io        *backends.IOConfig
+	types     *typechecker.Environment
+	evaluator *interpreter.Evaluator
+}
+
+// Option configures an Engine.
+type Option func(*backends.IOConfig)
+
+// WithStdin sets the reader programs read input from.
+func WithStdin(r io.Reader) Option {
+	return func(cfg *backends.IOConfig) { cfg.Stdin = r }
+}
+
+// WithStdout sets the writer programs print to.
+func WithStdout(w io.Writer) Option {
+	return func(cfg *backends.IOConfig) { cfg.Stdout = w }
+}
+
+// WithStderr sets the writer programs report errors to.
+func WithStderr(w io.Writer) Option {
+	return func(cfg *backends.IOConfig) { cfg.Stderr = w }
+}
+
+// New creates an engine. Without options programs use the process streams.
+func New(opts ...Option) *Engine {
+	cfg := &backends.IOConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg = cfg.WithDefaults()
+
+	return &Engine{
+		io:        cfg,
+		types:     typechecker.NewEnvironment(),
+		evaluator: interpreter.NewEvaluator(cfg).(*interpreter.Evaluator),
+	}
+}
+
+// Program is a parsed and type checked Sigil program.
+type Program struct {
+	ast   *ast.Program
+	typ   typechecker.Type
+	scope *typechecker.Environment // top level declarations of the program
+}
+
+// Type returns the static type of the value the program produces.
+func (p *Program) Type() string { return p.typ.String() }
+
+// Tree returns the program's abstract syntax tree in a readable form.
+func (p *Program) Tree() string { return p.ast.TreeString("", false) }
+
+// Compile parses and type checks source against the engine's globals. Names
+// the program declares become visible to later compilations once it has run.
+func (e *Engine) Compile(source string) (*Program, error) {
+	scope := typechecker.NewEnclosedEnvironment(e.types)
+	compiled := compiler.Compile(source, compiler.WithGlobals(scope))
+
+	if errs := compiled.ParseErrors; len(errs) > 0 {
+		list := ErrorList{}
+		for _, err := range errs {
+			e := &Error{
+				Kind:    ParseError,
+				Code:    err.Code,
+				Message: err.Message,
+				Line:    err.Token.Line,
+				Column:  err.Token.Column,
+			}
+			e.describe(err.Diagnostic())
+			list = append(list, e)
+		}
+		return nil, list
+	}
+
+	result := compiled.Checked
+	if len(result.Errors) > 0 {
+		list := ErrorList{}
+		for _, err := range result.Errors {
+			e := &Error{
+				Kind:    TypeError,
+				Code:    err.Code,
+				Message: err.Message,
+				Line:    err.Line,
+				Column:  err.Column,
+			}
+			e.describe(err.Diagnostic())
+			list = append(list, e)
+		}
+		return nil, list
+	}
+
+	return &Program{ast: compiled.Program, typ: result.Type, scope: scope}, nil
+}
+
+// Run executes a compiled program and returns the value of its last
+// statement converted to a Go value (see Eval). The names the program
+// declares become visible to later programs only if it runs to completion.
+func (e *Engine) Run(program *Program) (any, error) {
+	result, err := e.evaluator.Run(program.ast)
+	if err != nil {
+		runtimeErr := &Error{Kind: RuntimeError, Message: err.Error()}
+		var located *backends.RuntimeError
+		if errors.As(err, &located) {
+			runtimeErr.Code = located.Code
+			runtimeErr.Line = located.Span.Start.Line
+			runtimeErr.Column = located.Span.Start.Column
+			runtimeErr.describe(located.Diagnostic())
+		}
+		return nil, runtimeErr
+	}
+
+	for _, symbol := range program.scope.Symbols() {
+		e.types.Set(symbol.Name, symbol)
+	}
+	return fromObject(result), nil
+}
+
+// Eval compiles and runs source in one step. Numbers are returned as float64,
+// strings as string, booleans as bool and functions as *Function. Statements
+// that produce no value return nil.
+func (e *Engine) Eval(source string) (any, error) {
+	program, err := e.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Run(program)
+}
+
+// Define binds a global name to a Go value. Supported values are bool,
+// string and any integer or floating point number.
+func (e *Engine) Define(name string, value any) error {
+	obj, typ, err := toObject(value)
+	if err != nil {
+		return fmt.Errorf("cannot define %s: %w", name, err)
+	}
+
+	e.types.Set(name, &typechecker.Symbol{Name: name, Type: typ})
+	e.evaluator.Define(name, obj)
+	return nil
+}
+
+// Global describes a name bound in the engine's global environment.
+type Global struct {
+	Name string
+	Type string
+}
+
+// Globals lists the names programs and the host have defined, sorted by name.
+// Builtin functions are not included.
+func (e *Engine) Globals() []Global {
+	globals := []Global{}
+	for _, symbol := range e.types.Symbols() {
+		globals = append(globals, Global{Name: symbol.Name, Type: symbol.Type.String()})
+	}
+	return globals
+}
+
+// Function is a Sigil function value returned to Go.
+type Function struct {
+	obj interpreter.Object
+}
+
+// String returns the function's source form.
+func (f *Function) String() string { return f.obj.Inspect() }
+
+func fromObject(obj interpreter.Object) any {
+	switch obj := obj.(type) {
+	case *interpreter.Number:
+		return obj.Value
+	case *interpreter.String:
+		return obj.Value
+	case *interpreter.Boolean:
+		return obj.Value
+	case *interpreter.Function, *interpreter.NativeFunction:
+		return &Function{obj: obj}
+	default:
+		return nil
+	}
+}
+
+var errUnsupportedValue = errors.New("unsupported Go value")
+
+func toObject(value any) (interpreter.Object, typechecker.Type, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return interpreter.TRUE, &typechecker.BoolType{}, nil
+		}
+		return interpreter.FALSE, &typechecker.BoolType{}, nil
+	case string:
+		return &interpreter.String{Value: v}, &typechecker.StringType{}, nil
+	case float64:
+		return &interpreter.Number{Value: v}, &typechecker.NumberType{}, nil
+	case float32:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case int:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case int8:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case int16:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case int32:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case int64:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case uint:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case uint8:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case uint16:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case uint32:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	case uint64:
+		return &interpreter.Number{Value: float64(v)}, &typechecker.NumberType{}, nil
+	default:
+		return nil, nil, fmt.Errorf("%w of type %T", errUnsupportedValue, value)
+	}
+}
@@ -0,0 +1,169 @@
+package sigil
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEngineEval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"1 + 2", 3.0},
+		{`"Hello" + " World!"`, "Hello World!"},
+		{"1 < 2", true},
+		{"let x: Number = 5;", nil},
+		{"let add = fun(x: Number, y: Number): Number { x + y }; add(2, 3)", 5.0},
+	}
+
+	for _, tt := range tests {
+		got, err := New().Eval(tt.input)
+		if err != nil {
+			t.Fatalf("input %q: unexpected error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("input %q: got %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEngineFunctionResult(t *testing.T) {
+	got, err := New().Eval("fun(x: Number): Number { x }")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := got.(*Function); !ok {
+		t.Fatalf("expected *Function, got %T", got)
+	}
+}
+
+func TestEnginePersistsGlobals(t *testing.T) {
+	engine := New()
+
+	if _, err := engine.Eval("let double = fun(x: Number): Number { x * 2 };"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := engine.Eval("double(21)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 42.0 {
+		t.Errorf("got %#v, want 42", got)
+	}
+}
+
+func TestEngineDefine(t *testing.T) {
+	engine := New()
+	if err := engine.Define("limit", 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := engine.Define("greeting", "hi"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := engine.Eval("limit * 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 20.0 {
+		t.Errorf("got %#v, want 20", got)
+	}
+
+	if _, err := engine.Eval("greeting + 1"); err == nil {
+		t.Errorf("expected a type error for String + Number")
+	}
+
+	if err := engine.Define("bad", []int{1}); err == nil {
+		t.Errorf("expected an error defining an unsupported value")
+	}
+}
+
+func TestEngineErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  ErrorKind
+	}{
+		{"let = 5;", ParseError},
+		{`let x: String = 42;`, TypeError},
+		{"fooo", TypeError},
+		{"5 + true", TypeError},
+	}
+
+	for _, tt := range tests {
+		_, err := New().Eval(tt.input)
+		if err == nil {
+			t.Fatalf("input %q: expected error, got none", tt.input)
+		}
+
+		var list ErrorList
+		if !errors.As(err, &list) || len(list) == 0 {
+			t.Fatalf("input %q: expected ErrorList, got %T", tt.input, err)
+		}
+		if list[0].Kind != tt.kind {
+			t.Errorf("input %q: got kind %s, want %s", tt.input, list[0].Kind, tt.kind)
+		}
+	}
+}
+
+func TestEngineErrorDiagnostics(t *testing.T) {
+	_, err := New().Eval("let x: String = 42;")
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T", err)
+	}
+	e := list[0]
+	if e.Code != "E0207" || e.Column != 17 || e.EndColumn != 19 || len(e.Related) != 1 || e.Related[0].Column != 8 {
+		t.Errorf("got error %+v", e)
+	}
+
+	_, err = New().Eval("assert(false)")
+	var runtimeErr *Error
+	if !errors.As(err, &runtimeErr) || runtimeErr.EndColumn != 14 {
+		t.Errorf("expected a located runtime error, got %v", err)
+	}
+}
+
+func TestEngineCompileDoesNotLeakFailedDeclarations(t *testing.T) {
+	engine := New()
+	if _, err := engine.Eval(`let x: String = 42;`); err == nil {
+		t.Fatalf("expected type error")
+	}
+
+	if _, err := engine.Eval("x"); err == nil {
+		t.Errorf("expected x to be undefined after failed compilation")
+	}
+}
+
+func TestEngineRunDoesNotLeakFailedDeclarations(t *testing.T) {
+	engine := New()
+	if _, err := engine.Eval(`let k = fun(): Number { assert(false); 1 }();`); err == nil {
+		t.Fatalf("expected runtime error")
+	}
+
+	_, err := engine.Eval("k")
+	var list ErrorList
+	if !errors.As(err, &list) || list[0].Kind != TypeError {
+		t.Errorf("expected k to be undefined after a failed run, got %v", err)
+	}
+	for _, global := range engine.Globals() {
+		if global.Name == "k" {
+			t.Errorf("k is listed as a global after a failed run")
+		}
+	}
+}
+
+func TestEngineStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	engine := New(WithStdout(&stdout))
+
+	if _, err := engine.Eval(`println("Hello,", "world!")`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stdout.String() != "Hello, world!\n" {
+		t.Errorf("got output %q", stdout.String())
+	}
+}